@@ -0,0 +1,193 @@
+package monarch
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// defaultAuditCollection is the default name of the collection storing audit records.
+const defaultAuditCollection = "monarch_audit"
+
+// AuditConfig configures the audit trail of document changes.
+type AuditConfig struct {
+	// Collection is the name of the collection audit records are written to.
+	// Defaults to "monarch_audit".
+	Collection string
+	// Collections lists the names of the audited collections.
+	Collections []string
+	// Diff, if true, records the changed fields of each write instead of the full
+	// documents before and after it.
+	Diff bool
+	// Transactional, if true, runs each audited write and its audit record in a
+	// transaction, starting one if the context does not carry one. Transactions
+	// require a replica set or sharded cluster.
+	Transactional bool
+}
+
+func (c AuditConfig) collection() string {
+	if c.Collection == "" {
+		return defaultAuditCollection
+	}
+	return c.Collection
+}
+
+// AuditRecord describes a change to a single document.
+type AuditRecord struct {
+	// ID identifies the record.
+	ID bson.ObjectID `bson:"_id"`
+	// Collection is the name of the collection of the changed document.
+	Collection string `bson:"collection"`
+	// DocumentID is the _id of the changed document.
+	DocumentID bson.RawValue `bson:"documentId"`
+	// Op is the name of the monarch operation, e.g. "UpdateOne".
+	Op string `bson:"op"`
+	// Tenant is the tenant of the document of a tenant-scoped collection.
+	Tenant bson.RawValue `bson:"tenant,omitempty"`
+	// Actor is the actor attached to the context with WithActor.
+	Actor string `bson:"actor,omitempty"`
+	// Filter is the filter of the operation. It is empty for inserts.
+	Filter bson.Raw `bson:"filter,omitempty"`
+	// Before is the document before the change. It is empty for inserts and when
+	// recording diffs.
+	Before bson.Raw `bson:"before,omitempty"`
+	// After is the document after the change. It is empty for deletes and when
+	// recording diffs.
+	After bson.Raw `bson:"after,omitempty"`
+	// Changes lists the changed fields when recording diffs.
+	Changes []FieldChange `bson:"changes,omitempty"`
+	// Timestamp is the time of the change.
+	Timestamp time.Time `bson:"timestamp"`
+}
+
+// FieldChange describes a changed field of a document.
+type FieldChange struct {
+	// Path is the dotted path of the field.
+	Path string `bson:"path"`
+	// Before is the value before the change. It is zero if the field was added.
+	Before bson.RawValue `bson:"before,omitempty"`
+	// After is the value after the change. It is zero if the field was removed.
+	After bson.RawValue `bson:"after,omitempty"`
+}
+
+type auditKey struct{}
+
+type actorKey struct{}
+
+// WithAudit returns a new context with auditing configured.
+//
+// InsertOne, UpdateOne, ReplaceOne, DeleteOne, FindOneAndUpdate, FindOneAndReplace,
+// FindOneAndDelete, Upsert and UpsertWith on audited collections then write an audit
+// record for the changed document, using the same context, so that the record is
+// part of the transaction of the write if any. Writes that change no document are
+// not recorded, and operations writing many documents are not audited.
+//
+// The documents before and after a write are read separately from the write. Outside
+// of a transaction, concurrent writes to the same document may be interleaved with
+// these reads; enable Transactional for exact snapshots.
+func WithAudit(ctx context.Context, config AuditConfig) context.Context {
+	return context.WithValue(ctx, auditKey{}, config)
+}
+
+// WithActor returns a new context with the actor attached, such as the ID of the
+// authenticated user. The actor is recorded in audit records.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// Actor returns the actor attached to the context with WithActor, or the empty string.
+func Actor(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// auditFor returns the audit configuration in ctx, or false if the collection is not audited.
+func auditFor(ctx context.Context, collection string) (AuditConfig, bool) {
+	config, ok := ctx.Value(auditKey{}).(AuditConfig)
+	if !ok || collection == config.collection() {
+		return config, false
+	}
+	return config, slices.Contains(config.Collections, collection)
+}
+
+// record writes an audit record for the change of the document with the given _id
+// of the given tenant, if any.
+func (c Collection[T]) record(ctx context.Context, config AuditConfig, op string, filter any, id, tenant bson.RawValue, before, after bson.Raw) error {
+	record := AuditRecord{
+		ID:         bson.NewObjectID(),
+		Collection: string(c),
+		DocumentID: id,
+		Op:         op,
+		Tenant:     tenant,
+		Actor:      Actor(ctx),
+		Timestamp:  time.Now(),
+	}
+	if filter != nil {
+		if raw, err := bson.Marshal(filter); err == nil {
+			record.Filter = raw
+		}
+	}
+	if config.Diff {
+		record.Changes = diffDocuments("", before, after)
+	} else {
+		record.Before, record.After = before, after
+	}
+
+	_, err := Collection[AuditRecord](config.collection()).InsertOne(ctx, record)
+	return err
+}
+
+// auditIndexes are the indexes of the audit collection used to query the history of a document.
+var auditIndexes = []mongo.IndexModel{
+	{Keys: bson.D{{Key: "collection", Value: 1}, {Key: "documentId", Value: 1}, {Key: "timestamp", Value: 1}}},
+}
+
+// AuditHistory returns the audit records of the document of c with the given _id, oldest first.
+//
+// The records are read from the audit collection configured in the context with WithAudit.
+// On tenant-scoped collections, only the records of the tenant in ctx are returned.
+func AuditHistory[T Document[ID], ID comparable](ctx context.Context, c Collection[T], id ID) ([]AuditRecord, error) {
+	filter, err := c.scopeRecords(ctx, bson.D{{Key: "collection", Value: string(c)}, {Key: "documentId", Value: id}})
+	if err != nil {
+		return nil, err
+	}
+	config, _ := ctx.Value(auditKey{}).(AuditConfig)
+	records := Collection[AuditRecord](config.collection())
+	if err := records.EnsureIndexes(ctx, auditIndexes); err != nil {
+		return nil, err
+	}
+	return records.Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}, {Key: "_id", Value: 1}}),
+	)
+}
+
+// diffDocuments returns the changes between the fields of before and after, recursing
+// into embedded documents present on both sides. Paths are prefixed with prefix.
+func diffDocuments(prefix string, before, after bson.Raw) []FieldChange {
+	var changes []FieldChange
+	beforeElems, _ := before.Elements()
+	afterElems, _ := after.Elements()
+
+	for _, b := range beforeElems {
+		path := prefix + b.Key()
+		a, err := after.LookupErr(b.Key())
+		switch {
+		case err != nil:
+			changes = append(changes, FieldChange{Path: path, Before: b.Value()})
+		case b.Value().Type == bson.TypeEmbeddedDocument && a.Type == bson.TypeEmbeddedDocument:
+			changes = append(changes, diffDocuments(path+".", b.Value().Document(), a.Document())...)
+		case !b.Value().Equal(a):
+			changes = append(changes, FieldChange{Path: path, Before: b.Value(), After: a})
+		}
+	}
+	for _, a := range afterElems {
+		if _, err := before.LookupErr(a.Key()); err != nil {
+			changes = append(changes, FieldChange{Path: prefix + a.Key(), After: a.Value()})
+		}
+	}
+	return changes
+}
@@ -0,0 +1,170 @@
+package monarch
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+func TestDiffDocuments(t *testing.T) {
+	before := mustMarshal(t, bson.D{
+		{Key: "_id", Value: "u1"},
+		{Key: "name", Value: "Alice"},
+		{Key: "address", Value: bson.D{{Key: "city", Value: "Lagos"}, {Key: "zip", Value: "100001"}}},
+		{Key: "nickname", Value: "Al"},
+	})
+	after := mustMarshal(t, bson.D{
+		{Key: "_id", Value: "u1"},
+		{Key: "name", Value: "Alice"},
+		{Key: "address", Value: bson.D{{Key: "city", Value: "Abuja"}, {Key: "zip", Value: "100001"}}},
+		{Key: "age", Value: 30},
+	})
+
+	changes := diffDocuments("", before, after)
+	got := make(map[string]FieldChange)
+	for _, change := range changes {
+		got[change.Path] = change
+	}
+	if len(changes) != 3 {
+		t.Fatalf("expected 3 changes, got %+v", changes)
+	}
+	if c := got["address.city"]; c.Before.StringValue() != "Lagos" || c.After.StringValue() != "Abuja" {
+		t.Errorf("expected address.city Lagos -> Abuja, got %+v", c)
+	}
+	if c := got["nickname"]; c.Before.StringValue() != "Al" || !c.After.IsZero() {
+		t.Errorf("expected nickname removed, got %+v", c)
+	}
+	if c := got["age"]; !c.Before.IsZero() || c.After.Int32() != 30 {
+		t.Errorf("expected age added, got %+v", c)
+	}
+
+	inserted := diffDocuments("", nil, after)
+	if len(inserted) != 4 {
+		t.Errorf("expected every field of an inserted document, got %+v", inserted)
+	}
+}
+
+func TestAuditFor(t *testing.T) {
+	ctx := WithAudit(context.Background(), AuditConfig{Collections: []string{"users", "monarch_audit"}})
+
+	if _, ok := auditFor(ctx, "users"); !ok {
+		t.Errorf("expected users to be audited")
+	}
+	if _, ok := auditFor(ctx, "orders"); ok {
+		t.Errorf("expected orders not to be audited")
+	}
+	if _, ok := auditFor(ctx, "monarch_audit"); ok {
+		t.Errorf("expected the audit collection never to be audited")
+	}
+	if _, ok := auditFor(context.Background(), "users"); ok {
+		t.Errorf("expected no auditing without configuration")
+	}
+}
+
+func TestAuditHistory(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := WithContext(context.Background(), db)
+	ctx = WithAudit(ctx, AuditConfig{Collections: []string{string(Users)}})
+
+	cleanupCollection(t, ctx, Users)
+
+	if _, err := Users.InsertOne(WithActor(ctx, "admin"), User{ID: "audit1", Name: "Alice", Age: 30}); err != nil {
+		t.Fatalf("InsertOne failed: %v", err)
+	}
+	if _, err := Users.UpdateOne(WithActor(ctx, "alice"), bson.M{"_id": "audit1"}, bson.M{"$set": bson.M{"age": 31}}); err != nil {
+		t.Fatalf("UpdateOne failed: %v", err)
+	}
+	// Writes that match nothing are not recorded.
+	if _, err := Users.UpdateOne(ctx, bson.M{"_id": "missing"}, bson.M{"$set": bson.M{"age": 1}}); err != nil {
+		t.Fatalf("UpdateOne failed: %v", err)
+	}
+	if _, err := Users.DeleteOne(ctx, bson.M{"_id": "audit1"}); err != nil {
+		t.Fatalf("DeleteOne failed: %v", err)
+	}
+
+	history, err := AuditHistory(ctx, Users, "audit1")
+	if err != nil {
+		t.Fatalf("AuditHistory failed: %v", err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(history))
+	}
+
+	insert, update, del := history[0], history[1], history[2]
+	if insert.Op != "InsertOne" || insert.Actor != "admin" || insert.Before != nil || insert.After.Lookup("name").StringValue() != "Alice" {
+		t.Errorf("unexpected insert record: %+v", insert)
+	}
+	if update.Op != "UpdateOne" || update.Actor != "alice" || update.Before.Lookup("age").AsInt64() != 30 || update.After.Lookup("age").AsInt64() != 31 {
+		t.Errorf("unexpected update record: %+v", update)
+	}
+	if update.Filter.Lookup("_id").StringValue() != "audit1" {
+		t.Errorf("expected update filter to be recorded, got %v", update.Filter)
+	}
+	if del.Op != "DeleteOne" || del.Before == nil || del.After != nil {
+		t.Errorf("unexpected delete record: %+v", del)
+	}
+}
+
+func TestAuditDiff(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := WithContext(context.Background(), db)
+	ctx = WithAudit(ctx, AuditConfig{Collections: []string{string(Users)}, Diff: true})
+
+	cleanupCollection(t, ctx, Users)
+
+	if _, _, err := Users.Upsert(ctx, bson.M{"_id": "audit2"}, User{ID: "audit2", Name: "Bob", Age: 40}); err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+	if _, err := Users.FindOneAndUpdate(ctx, bson.M{"_id": "audit2"}, bson.M{"$set": bson.M{"name": "Robert"}}); err != nil {
+		t.Fatalf("FindOneAndUpdate failed: %v", err)
+	}
+
+	history, err := AuditHistory(ctx, Users, "audit2")
+	if err != nil {
+		t.Fatalf("AuditHistory failed: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(history))
+	}
+	changes := history[1].Changes
+	if len(changes) != 1 || changes[0].Path != "name" || changes[0].After.StringValue() != "Robert" {
+		t.Errorf("expected a single name change, got %+v", changes)
+	}
+	if history[1].Before != nil || history[1].After != nil {
+		t.Errorf("expected no full documents when recording diffs")
+	}
+}
+
+func TestAuditSortedWrite(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := WithContext(context.Background(), db)
+	ctx = WithAudit(ctx, AuditConfig{Collections: []string{string(Users)}})
+
+	cleanupCollection(t, ctx, Users)
+
+	if _, err := Users.InsertMany(ctx, []User{{ID: "audit3", Name: "Young", Age: 20}, {ID: "audit4", Name: "Old", Age: 80}}); err != nil {
+		t.Fatalf("InsertMany failed: %v", err)
+	}
+	// The record belongs to the document chosen by the sort, not the first in natural order.
+	_, err := Users.FindOneAndUpdate(ctx, bson.M{"age": bson.M{"$gte": 18}}, bson.M{"$set": bson.M{"name": "Oldest"}},
+		options.FindOneAndUpdate().SetSort(bson.D{{Key: "age", Value: -1}}))
+	if err != nil {
+		t.Fatalf("FindOneAndUpdate failed: %v", err)
+	}
+
+	history, err := AuditHistory(ctx, Users, "audit4")
+	if err != nil {
+		t.Fatalf("AuditHistory failed: %v", err)
+	}
+	if len(history) != 1 || history[0].Before.Lookup("name").StringValue() != "Old" || history[0].After.Lookup("name").StringValue() != "Oldest" {
+		t.Errorf("expected the update of audit4 to be recorded, got %+v", history)
+	}
+	if history, _ := AuditHistory(ctx, Users, "audit3"); len(history) != 0 {
+		t.Errorf("expected no records of audit3, got %+v", history)
+	}
+}
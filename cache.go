@@ -0,0 +1,297 @@
+package monarch
+
+import (
+	"container/list"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// Cache stores encoded documents for the read-through cache configured with WithCache.
+//
+// Implementations must be safe for concurrent use. Failures to read or write an
+// entry should be treated as a miss rather than returned, since the cache is only
+// an optimization.
+type Cache interface {
+	// Get returns the value stored for key, if any and not expired.
+	Get(ctx context.Context, key string) ([]byte, bool)
+	// Set stores the value for key. A zero ttl means the entry does not expire.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration)
+	// Delete removes the value stored for key.
+	Delete(ctx context.Context, key string)
+}
+
+// CacheConfig configures the read-through cache of FindOne and FindByID.
+type CacheConfig struct {
+	// Cache stores the cached documents, e.g. an LRU created with NewLRU.
+	Cache Cache
+	// Collections maps the names of cached collections to the TTL of their entries.
+	// Collections not listed are not cached.
+	Collections map[string]time.Duration
+	// NegativeTTL is how long a lookup that found no document is cached.
+	// Zero disables negative caching.
+	NegativeTTL time.Duration
+}
+
+type cacheKey struct{}
+
+// WithCache returns a new context with the read-through cache attached.
+//
+// FindOne (and FindByID) on a cached collection return the cached document for the
+// same filter when available, and otherwise read from the database and cache the
+// result. Lookups with options or inside a transaction always read from the database.
+//
+// Writes performed through monarch with a context carrying the same configuration
+// invalidate the cached entries of the collection. Writes inside a transaction started
+// with Tx, TxDo or WithTransaction invalidate them again once the transaction ends,
+// since a lookup running before the commit caches the document as it was before the
+// transaction. To observe writes made by other processes or tools, run
+// InvalidateOnChange for the collection.
+func WithCache(ctx context.Context, config CacheConfig) context.Context {
+	return context.WithValue(ctx, cacheKey{}, config)
+}
+
+// cacheFor returns the cache configuration in ctx and the TTL of the collection,
+// or false if the collection is not cached.
+func cacheFor(ctx context.Context, collection string) (CacheConfig, time.Duration, bool) {
+	config, ok := ctx.Value(cacheKey{}).(CacheConfig)
+	if !ok || config.Cache == nil {
+		return config, 0, false
+	}
+	ttl, ok := config.Collections[collection]
+	return config, ttl, ok
+}
+
+// cachedFindOne returns the document matching filter from the cache, or calls find
+// and caches its result. An empty cached value records that no document matched.
+func (c Collection[T]) cachedFindOne(ctx context.Context, config CacheConfig, ttl time.Duration, filter any, find func() (T, error)) (T, error) {
+	var result T
+	key, err := c.cacheEntryKey(ctx, config.Cache, filter)
+	if err != nil {
+		return find()
+	}
+
+	if value, ok := config.Cache.Get(ctx, key); ok {
+		if len(value) == 0 {
+			return result, mongo.ErrNoDocuments
+		}
+		if err := decodeRaw(ctx, value, &result); err == nil {
+			return result, nil
+		}
+		config.Cache.Delete(ctx, key)
+	}
+
+	result, err = find()
+	switch {
+	case err == nil:
+		// Encrypted fields are cached encrypted, as they are stored in the database.
+		if doc, err := encryptDocument(ctx, result); err == nil {
+			if value, err := bson.Marshal(doc); err == nil {
+				config.Cache.Set(ctx, key, value, ttl)
+			}
+		}
+	case err == mongo.ErrNoDocuments && config.NegativeTTL > 0:
+		config.Cache.Set(ctx, key, []byte{}, config.NegativeTTL)
+	}
+	return result, err
+}
+
+// cacheEntryKey returns the cache key of filter, made of the collection name, the
+// current generation of the collection and a hash of the filter.
+func (c Collection[T]) cacheEntryKey(ctx context.Context, cache Cache, filter any) (string, error) {
+	raw, err := bson.Marshal(filter)
+	if err != nil {
+		return "", err
+	}
+	// Top-level filter conditions are ANDed, so their order does not change the
+	// result. Sorting them gives bson.M filters a stable key.
+	elems, err := bson.Raw(raw).Elements()
+	if err != nil {
+		return "", err
+	}
+	slices.SortFunc(elems, func(a, b bson.RawElement) int {
+		return strings.Compare(a.Key(), b.Key())
+	})
+	hash := sha256.New()
+	for _, elem := range elems {
+		hash.Write(elem)
+	}
+	return "monarch:" + string(c) + ":" + c.cacheGeneration(ctx, cache) + ":" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// cacheGeneration returns the current generation of the collection. Invalidating a
+// collection starts a new generation, which orphans the entries of the previous one.
+func (c Collection[T]) cacheGeneration(ctx context.Context, cache Cache) string {
+	key := "monarch:" + string(c) + ":generation"
+	if generation, ok := cache.Get(ctx, key); ok {
+		return string(generation)
+	}
+	// The generation is unknown, e.g. because it was evicted, so entries of any
+	// previous generation may be stale and a new generation is started.
+	return c.newCacheGeneration(ctx, cache)
+}
+
+func (c Collection[T]) newCacheGeneration(ctx context.Context, cache Cache) string {
+	generation := rand.Text()
+	cache.Set(ctx, "monarch:"+string(c)+":generation", []byte(generation), 0)
+	return generation
+}
+
+// Invalidate removes all cached lookups of the collection from the cache in ctx.
+//
+// Writes performed through monarch invalidate the cache automatically.
+// Invalidate is a no-op if the collection is not cached.
+func (c Collection[T]) Invalidate(ctx context.Context) {
+	if config, _, ok := cacheFor(ctx, string(c)); ok {
+		c.newCacheGeneration(ctx, config.Cache)
+	}
+}
+
+// invalidateWrite invalidates the collection after a write. Inside a transaction started
+// by WithTransaction, the collection is invalidated again when the transaction ends.
+func (c Collection[T]) invalidateWrite(ctx context.Context) {
+	if _, _, ok := cacheFor(ctx, string(c)); !ok {
+		return
+	}
+	c.Invalidate(ctx)
+	if pending, ok := ctx.Value(pendingInvalidationsKey{}).(*pendingInvalidations); ok && inTransaction(ctx) {
+		pending.add(string(c), func() { c.Invalidate(context.WithoutCancel(ctx)) })
+	}
+}
+
+type pendingInvalidationsKey struct{}
+
+// pendingInvalidations collects the invalidations of the collections written in a
+// transaction, to run them again once the transaction ends.
+type pendingInvalidations struct {
+	mu            sync.Mutex
+	invalidations map[string]func()
+}
+
+func (p *pendingInvalidations) add(collection string, invalidate func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.invalidations == nil {
+		p.invalidations = make(map[string]func())
+	}
+	p.invalidations[collection] = invalidate
+}
+
+func (p *pendingInvalidations) run() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, invalidate := range p.invalidations {
+		invalidate()
+	}
+	p.invalidations = nil
+}
+
+// InvalidateOnChange watches the collection with a change stream and invalidates its
+// cached lookups on every change, until the context is done or the change stream fails.
+//
+// Run it in its own goroutine with the context carrying the cache configuration.
+// Change streams require a replica set or sharded cluster.
+//
+// Cached lookups of every tenant are invalidated together, so the changes of every
+// tenant are watched even if ctx has a tenant. Events are reduced to their operation
+// type, so no document is exposed.
+func (c Collection[T]) InvalidateOnChange(ctx context.Context) error {
+	pipeline := bson.A{bson.D{{Key: "$project", Value: bson.D{{Key: "operationType", Value: 1}}}}}
+	for _, err := range c.Watch(CrossTenant(ctx), pipeline) {
+		if err != nil {
+			return err
+		}
+		c.Invalidate(ctx)
+	}
+	return ctx.Err()
+}
+
+// LRU is an in-process Cache that evicts the least recently used entries
+// once it holds its maximum number of entries.
+type LRU struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // of *lruEntry, most recently used first
+	entries map[string]*list.Element
+}
+
+type lruEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// NewLRU returns an LRU cache holding at most size entries.
+func NewLRU(size int) *LRU {
+	return &LRU{
+		size:    max(size, 1),
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Get returns the value stored for key, if any and not expired.
+func (l *LRU) Get(ctx context.Context, key string) ([]byte, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	elem, ok := l.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*lruEntry)
+	if !entry.expiresAt.IsZero() && !time.Now().Before(entry.expiresAt) {
+		l.order.Remove(elem)
+		delete(l.entries, key)
+		return nil, false
+	}
+	l.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+// Set stores the value for key, evicting the least recently used entry if the cache is full.
+func (l *LRU) Set(ctx context.Context, key string, value []byte, ttl time.Duration) {
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if elem, ok := l.entries[key]; ok {
+		entry := elem.Value.(*lruEntry)
+		entry.value, entry.expiresAt = value, expiresAt
+		l.order.MoveToFront(elem)
+		return
+	}
+	l.entries[key] = l.order.PushFront(&lruEntry{key: key, value: value, expiresAt: expiresAt})
+	if l.order.Len() > l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// Delete removes the value stored for key.
+func (l *LRU) Delete(ctx context.Context, key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if elem, ok := l.entries[key]; ok {
+		l.order.Remove(elem)
+		delete(l.entries, key)
+	}
+}
+
+// Len returns the number of entries in the cache, including expired entries not yet removed.
+func (l *LRU) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
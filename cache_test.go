@@ -0,0 +1,184 @@
+package monarch
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+	lru := NewLRU(2)
+
+	lru.Set(ctx, "a", []byte("1"), 0)
+	lru.Set(ctx, "b", []byte("2"), 0)
+	lru.Get(ctx, "a") // a is now the most recently used
+	lru.Set(ctx, "c", []byte("3"), 0)
+
+	if _, ok := lru.Get(ctx, "b"); ok {
+		t.Errorf("expected least recently used entry b to be evicted")
+	}
+	if v, ok := lru.Get(ctx, "a"); !ok || string(v) != "1" {
+		t.Errorf("expected a=1, got %q, %v", v, ok)
+	}
+
+	lru.Delete(ctx, "a")
+	if _, ok := lru.Get(ctx, "a"); ok {
+		t.Errorf("expected a to be deleted")
+	}
+
+	lru.Set(ctx, "d", []byte("4"), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := lru.Get(ctx, "d"); ok {
+		t.Errorf("expected d to be expired")
+	}
+	if lru.Len() != 1 {
+		t.Errorf("expected 1 entry, got %d", lru.Len())
+	}
+}
+
+func TestCacheEntryKey(t *testing.T) {
+	ctx := context.Background()
+	lru := NewLRU(10)
+
+	k1, err := Users.cacheEntryKey(ctx, lru, bson.D{{Key: "name", Value: "Alice"}, {Key: "age", Value: 30}})
+	if err != nil {
+		t.Fatalf("cacheEntryKey failed: %v", err)
+	}
+	k2, _ := Users.cacheEntryKey(ctx, lru, bson.D{{Key: "age", Value: 30}, {Key: "name", Value: "Alice"}})
+	k3, _ := Users.cacheEntryKey(ctx, lru, bson.D{{Key: "name", Value: "Bob"}, {Key: "age", Value: 30}})
+
+	if k1 != k2 {
+		t.Errorf("expected the order of top-level conditions not to change the key")
+	}
+	if k1 == k3 {
+		t.Errorf("expected different filters to have different keys")
+	}
+
+	Users.newCacheGeneration(ctx, lru)
+	k4, _ := Users.cacheEntryKey(ctx, lru, bson.D{{Key: "name", Value: "Alice"}, {Key: "age", Value: 30}})
+	if k1 == k4 {
+		t.Errorf("expected a new generation to change the key")
+	}
+}
+
+func TestCachedFindOne(t *testing.T) {
+	lru := NewLRU(10)
+	config := CacheConfig{Cache: lru, Collections: map[string]time.Duration{"users": time.Minute}, NegativeTTL: time.Minute}
+	ctx := WithCache(context.Background(), config)
+
+	calls := 0
+	stored := map[string]User{"u1": {ID: "u1", Name: "Alice"}}
+	find := func(id string) func() (User, error) {
+		return func() (User, error) {
+			calls++
+			user, ok := stored[id]
+			if !ok {
+				return User{}, mongo.ErrNoDocuments
+			}
+			return user, nil
+		}
+	}
+
+	for range 2 {
+		user, err := Users.cachedFindOne(ctx, config, time.Minute, bson.D{{Key: "_id", Value: "u1"}}, find("u1"))
+		if err != nil || user.Name != "Alice" {
+			t.Fatalf("expected Alice, got %+v, %v", user, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 database read, got %d", calls)
+	}
+
+	for range 2 {
+		_, err := Users.cachedFindOne(ctx, config, time.Minute, bson.D{{Key: "_id", Value: "u2"}}, find("u2"))
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			t.Fatalf("expected ErrNoDocuments, got %v", err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("expected missing document to be cached, got %d database reads", calls)
+	}
+
+	stored["u1"] = User{ID: "u1", Name: "Alicia"}
+	Users.Invalidate(ctx)
+	user, err := Users.cachedFindOne(ctx, config, time.Minute, bson.D{{Key: "_id", Value: "u1"}}, find("u1"))
+	if err != nil || user.Name != "Alicia" {
+		t.Errorf("expected Alicia after invalidation, got %+v, %v", user, err)
+	}
+	if calls != 3 {
+		t.Errorf("expected a database read after invalidation, got %d", calls)
+	}
+}
+
+func TestCacheIntegration(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := WithContext(context.Background(), db)
+	ctx = WithCache(ctx, CacheConfig{Cache: NewLRU(100), Collections: map[string]time.Duration{string(Users): time.Minute}})
+
+	cleanupCollection(t, ctx, Users)
+
+	if _, err := Users.InsertOne(ctx, User{ID: "cache1", Name: "Alice"}); err != nil {
+		t.Fatalf("InsertOne failed: %v", err)
+	}
+	user, err := FindByID(ctx, Users, "cache1")
+	if err != nil || user.Name != "Alice" {
+		t.Fatalf("expected Alice, got %+v, %v", user, err)
+	}
+
+	// A write outside monarch is not observed until the entry is invalidated.
+	_, err = db.Collection(string(Users)).UpdateOne(ctx, bson.M{"_id": "cache1"}, bson.M{"$set": bson.M{"name": "Direct"}})
+	if err != nil {
+		t.Fatalf("driver UpdateOne failed: %v", err)
+	}
+	if user, _ := FindByID(ctx, Users, "cache1"); user.Name != "Alice" {
+		t.Errorf("expected cached Alice, got %q", user.Name)
+	}
+
+	// A write through monarch invalidates the collection.
+	if _, err := UpdateByID(ctx, Users, "cache1", bson.M{"$set": bson.M{"name": "Alicia"}}); err != nil {
+		t.Fatalf("UpdateByID failed: %v", err)
+	}
+	if user, _ := FindByID(ctx, Users, "cache1"); user.Name != "Alicia" {
+		t.Errorf("expected Alicia after write, got %q", user.Name)
+	}
+}
+
+func TestCacheInvalidatedAfterCommit(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	requireReplicaSet(t, db)
+	ctx := WithContext(context.Background(), db)
+	ctx = WithCache(ctx, CacheConfig{Cache: NewLRU(100), Collections: map[string]time.Duration{string(Users): time.Minute}})
+
+	cleanupCollection(t, ctx, Users)
+
+	if _, err := Users.InsertOne(ctx, User{ID: "cache2", Name: "Alice"}); err != nil {
+		t.Fatalf("InsertOne failed: %v", err)
+	}
+	err := TxDo(ctx, func(txCtx context.Context) error {
+		if _, err := UpdateByID(txCtx, Users, "cache2", bson.M{"$set": bson.M{"name": "Alicia"}}); err != nil {
+			return err
+		}
+		// A concurrent reader caches the document as it was before the commit.
+		if user, err := FindByID(ctx, Users, "cache2"); err != nil || user.Name != "Alice" {
+			t.Errorf("expected uncommitted Alice, got %+v, %v", user, err)
+		}
+		// Reads inside the transaction bypass the cache.
+		if user, err := FindByID(txCtx, Users, "cache2"); err != nil || user.Name != "Alicia" {
+			t.Errorf("expected Alicia inside the transaction, got %+v, %v", user, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("TxDo failed: %v", err)
+	}
+	if user, _ := FindByID(ctx, Users, "cache2"); user.Name != "Alicia" {
+		t.Errorf("expected Alicia after commit, got %q", user.Name)
+	}
+}
@@ -0,0 +1,150 @@
+package monarch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Capped declares a capped collection, which has a fixed size and overwrites its
+// oldest documents once full. Capped collections preserve insertion order and can
+// be followed with Tail.
+//
+//	var LogFeed = monarch.Capped{Size: 64 << 20, MaxDocuments: 100_000}
+type Capped struct {
+	// Size is the maximum size of the collection in bytes. Required.
+	Size int64
+	// MaxDocuments, if positive, is the maximum number of documents of the collection.
+	MaxDocuments int64
+}
+
+// EnsureCapped creates the collection as a capped collection declared by capped.
+//
+// If the collection exists, it must be capped. Its size and maximum number of documents
+// are updated to match capped, which requires MongoDB 6.0 or later. Call it once on startup.
+func (c Collection[T]) EnsureCapped(ctx context.Context, capped Capped) error {
+	if capped.Size <= 0 {
+		return errors.New("monarch: capped collection requires a positive size")
+	}
+	return c.exec(ctx, "EnsureCapped", opWrite, func(collection *mongo.Collection) error {
+		db := collection.Database()
+		opts := options.CreateCollection().SetCapped(true).SetSizeInBytes(capped.Size)
+		if capped.MaxDocuments > 0 {
+			opts.SetMaxDocuments(capped.MaxDocuments)
+		}
+		err := db.CreateCollection(ctx, string(c), opts)
+		if !isNamespaceExists(err) {
+			return err
+		}
+		return c.updateCapped(ctx, db, capped)
+	})
+}
+
+// updateCapped checks that the existing collection is capped, and updates its size
+// and maximum number of documents.
+func (c Collection[T]) updateCapped(ctx context.Context, db *mongo.Database, capped Capped) error {
+	specs, err := db.ListCollectionSpecifications(ctx, bson.D{{Key: "name", Value: string(c)}})
+	if err != nil {
+		return err
+	}
+	if len(specs) == 0 || !specs[0].Options.Lookup("capped").Boolean() {
+		return fmt.Errorf("monarch: collection %q exists and is not capped", string(c))
+	}
+	existing := specs[0].Options
+
+	mod := bson.D{{Key: "collMod", Value: string(c)}}
+	if rawInt(existing.Lookup("size")) != capped.Size {
+		mod = append(mod, bson.E{Key: "cappedSize", Value: capped.Size})
+	}
+	// A max of 0 or less removes the limit on the number of documents.
+	if current := rawInt(existing.Lookup("max")); current != capped.MaxDocuments && (current > 0 || capped.MaxDocuments > 0) {
+		mod = append(mod, bson.E{Key: "cappedMax", Value: capped.MaxDocuments})
+	}
+	if len(mod) == 1 {
+		return nil
+	}
+	return db.RunCommand(ctx, mod).Err()
+}
+
+// tailReconnectDelay is the delay before reopening a dead tailable cursor.
+var tailReconnectDelay = 500 * time.Millisecond
+
+// Server error codes of tailable cursors that can be resumed by reopening the cursor.
+const (
+	codeCursorNotFound     = 43
+	codeCappedPositionLost = 136
+)
+
+// Tail follows a capped collection and returns an iterator over the documents matching
+// the filter, in insertion order, including documents inserted after Tail is called.
+//
+// Tail is built like FindSeq on a tailable await cursor, which waits on the server for
+// new documents instead of ending on an empty batch. When the cursor dies, because the
+// collection was empty, the server overwrote its position or the connection was lost,
+// Tail reopens it after the last document seen, assuming _id values increase in
+// insertion order as ObjectIDs do. The iterator blocks until the context is done or
+// iteration is stopped, and ends with the first error that cannot be resumed from,
+// such as tailing a collection that is not capped.
+//
+// See [options.TailableAwait] for more details.
+func (c Collection[T]) Tail(ctx context.Context, filter any) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		prepared, err := c.prepareFilter(ctx, filter)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		if prepared == nil {
+			prepared = bson.D{}
+		}
+
+		var last bson.RawValue
+		for {
+			query := prepared
+			if last.Type != 0 {
+				query = bson.D{{Key: "$and", Value: bson.A{prepared, bson.D{{Key: "_id", Value: bson.D{{Key: "$gt", Value: last}}}}}}}
+			}
+			var cursor *mongo.Cursor
+			err := c.exec(ctx, "Tail", opRead, func(collection *mongo.Collection) (err error) {
+				cursor, err = collection.Find(ctx, query, options.Find().SetCursorType(options.TailableAwait))
+				return err
+			})
+			if err == nil {
+				for cursor.Next(ctx) {
+					var result T
+					err := decodeCurrent(ctx, cursor, &result)
+					if id, lookupErr := cursor.Current.LookupErr("_id"); lookupErr == nil {
+						last = bson.RawValue{Type: id.Type, Value: slices.Clone(id.Value)}
+					}
+					if !yield(result, wrapError(string(c), "Tail", err)) {
+						cursor.Close(context.WithoutCancel(ctx))
+						return
+					}
+				}
+				err = wrapError(string(c), "Tail", cursor.Err())
+				cursor.Close(context.WithoutCancel(ctx))
+			}
+
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil && !IsRetryable(err) && !hasCode(err, codeCursorNotFound, codeCappedPositionLost) {
+				yield(zero, err)
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(tailReconnectDelay):
+			}
+		}
+	}
+}
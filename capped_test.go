@@ -0,0 +1,104 @@
+package monarch
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type LogEntry struct {
+	ID      bson.ObjectID `bson:"_id,omitempty"`
+	Level   string        `bson:"level"`
+	Message string        `bson:"message"`
+}
+
+var LogEntries Collection[LogEntry] = "log_entries"
+
+func TestTailWithoutDatabase(t *testing.T) {
+	for _, err := range LogEntries.Tail(context.Background(), bson.M{}) {
+		if !errors.Is(err, ErrNoDatabase) {
+			t.Errorf("expected ErrNoDatabase, got %v", err)
+		}
+	}
+	if err := LogEntries.EnsureCapped(context.Background(), Capped{}); err == nil {
+		t.Error("expected EnsureCapped without size to fail")
+	}
+}
+
+func TestCapped(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := WithContext(context.Background(), db)
+
+	db.Collection(string(LogEntries)).Drop(ctx)
+	defer func(delay time.Duration) { tailReconnectDelay = delay }(tailReconnectDelay)
+	tailReconnectDelay = 10 * time.Millisecond
+
+	if err := LogEntries.EnsureCapped(ctx, Capped{Size: 1 << 20, MaxDocuments: 3}); err != nil {
+		t.Fatalf("EnsureCapped failed: %v", err)
+	}
+	if err := LogEntries.EnsureCapped(ctx, Capped{Size: 1 << 20, MaxDocuments: 5}); err != nil {
+		t.Fatalf("EnsureCapped of existing collection failed: %v", err)
+	}
+
+	t.Run("tail follows inserts", func(t *testing.T) {
+		tailCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+
+		entries := make(chan LogEntry)
+		go func() {
+			defer close(entries)
+			for entry, err := range LogEntries.Tail(tailCtx, bson.M{"level": "error"}) {
+				if err != nil {
+					t.Errorf("Tail failed: %v", err)
+					return
+				}
+				entries <- entry
+			}
+		}()
+
+		// The collection is empty, so the first cursor dies and is reopened.
+		time.Sleep(50 * time.Millisecond)
+		for _, entry := range []LogEntry{
+			{Level: "error", Message: "first"},
+			{Level: "info", Message: "skipped"},
+			{Level: "error", Message: "second"},
+		} {
+			if _, err := LogEntries.InsertOne(ctx, entry); err != nil {
+				t.Fatalf("InsertOne failed: %v", err)
+			}
+		}
+
+		for _, want := range []string{"first", "second"} {
+			select {
+			case entry := <-entries:
+				if entry.Message != want {
+					t.Errorf("expected %q, got %q", want, entry.Message)
+				}
+			case <-tailCtx.Done():
+				t.Fatalf("timed out waiting for %q", want)
+			}
+		}
+		cancel()
+		for range entries {
+		}
+	})
+
+	t.Run("tail of collection not capped fails", func(t *testing.T) {
+		cleanupCollection(t, ctx, Users)
+		if _, err := Users.InsertOne(ctx, User{ID: "1", Name: "Alice"}); err != nil {
+			t.Fatalf("InsertOne failed: %v", err)
+		}
+		for _, err := range Users.Tail(ctx, bson.M{}) {
+			if err == nil {
+				t.Error("expected Tail of collection not capped to fail")
+			}
+		}
+		if err := Users.EnsureCapped(ctx, Capped{Size: 1 << 20}); err == nil {
+			t.Error("expected EnsureCapped of collection not capped to fail")
+		}
+	})
+}
@@ -0,0 +1,744 @@
+package monarch
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/x/bsonx/bsoncore"
+)
+
+// KeyProvider supplies the keys used to encrypt fields tagged `monarch:"encrypt"`.
+//
+// Keys must be 16, 24 or 32 bytes long to select AES-128, AES-192 or AES-256.
+// Encrypted values record the ID of their key, so keys can be rotated by changing
+// the current key while keeping previous keys available for decryption.
+type KeyProvider interface {
+	// CurrentKey returns the ID and value of the key used to encrypt new values.
+	CurrentKey(ctx context.Context) (id string, key []byte, err error)
+	// Key returns the value of the key with the given ID, used to decrypt values.
+	Key(ctx context.Context, id string) ([]byte, error)
+}
+
+// KeyLister is implemented by key providers that can list the IDs of their keys.
+//
+// A deterministic value encrypts to a different ciphertext under each key, so filters
+// on deterministic fields only match values encrypted with the current key, unless the
+// provider implements KeyLister, in which case they match values encrypted with any
+// listed key.
+type KeyLister interface {
+	// KeyIDs returns the IDs of the keys values may be encrypted with.
+	KeyIDs(ctx context.Context) ([]string, error)
+}
+
+// LocalKeys is a KeyProvider holding keys in memory, for tests and simple deployments.
+type LocalKeys struct {
+	// Current is the ID of the key used to encrypt new values.
+	Current string
+	// Keys maps key IDs to key values.
+	Keys map[string][]byte
+}
+
+// LocalKey returns a LocalKeys holding the single given key.
+func LocalKey(key []byte) LocalKeys {
+	return LocalKeys{Current: "local", Keys: map[string][]byte{"local": key}}
+}
+
+// CurrentKey returns the key with ID k.Current.
+func (k LocalKeys) CurrentKey(ctx context.Context) (string, []byte, error) {
+	key, err := k.Key(ctx, k.Current)
+	return k.Current, key, err
+}
+
+// KeyIDs returns the IDs of the keys in k.Keys, sorted.
+func (k LocalKeys) KeyIDs(ctx context.Context) ([]string, error) {
+	return slices.Sorted(maps.Keys(k.Keys)), nil
+}
+
+// Key returns the key with the given ID.
+func (k LocalKeys) Key(ctx context.Context, id string) ([]byte, error) {
+	key, ok := k.Keys[id]
+	if !ok {
+		return nil, fmt.Errorf("monarch: unknown encryption key %q", id)
+	}
+	return key, nil
+}
+
+type encryptionKey struct{}
+
+// WithEncryption returns a new context with the key provider attached.
+//
+// Fields tagged `monarch:"encrypt"` are encrypted with AES-GCM in documents written
+// by InsertOne, InsertMany, ReplaceOne, FindOneAndReplace, Upsert and UpsertMany, and
+// in $set and $setOnInsert of update documents. They are decrypted in documents read
+// by Find, FindSeq, FindOne, FindOneAnd*, Aggregate and the *As functions. Fields of
+// embedded structs may be encrypted; elements of arrays may not.
+//
+// Encryption is randomized by default, so equal values have different ciphertexts.
+// Fields tagged `monarch:"encrypt,deterministic"` always encrypt equal values with
+// the same key to the same ciphertext, so they can be queried by equality ($eq, $ne,
+// $in and $nin) in filters, which are encrypted accordingly. Filtering on randomized
+// fields, or by equality on documents holding encrypted fields, returns an error.
+// Deterministic encryption reveals which documents share a value, so use it only for
+// fields that need to be queried. After a key rotation, filters match values written
+// with previous keys only if the key provider implements KeyLister.
+//
+// Updates may only write encrypted fields with $set and $setOnInsert; other operators
+// and pipeline updates writing them return an error. Aggregation pipelines are not
+// encrypted, so $match stages must not compare encrypted fields: use Find for them.
+//
+// Encrypted values are stored as binary values. Values that are not encrypted, such as
+// documents written before a field was tagged, are read unchanged. Lookups cached with
+// WithCache are stored encrypted and decrypted when read from the cache.
+func WithEncryption(ctx context.Context, keys KeyProvider) context.Context {
+	return context.WithValue(ctx, encryptionKey{}, keys)
+}
+
+// encryptedField is a struct field tagged `monarch:"encrypt"`.
+type encryptedField struct {
+	// path is the dotted document path of the field.
+	path string
+	// name is the dotted path of the field within its document, authenticated with
+	// its values. It differs from path for documents embedded in wrapper types such
+	// as Version, whose document field is tagged `monarch:"document"`.
+	name string
+	// deterministic reports whether equal values encrypt to the same ciphertext.
+	deterministic bool
+}
+
+// encryptedFieldSet holds the encrypted fields of a type by path, and the paths of
+// the embedded documents containing them.
+type encryptedFieldSet struct {
+	fields  map[string]encryptedField
+	parents map[string]bool
+}
+
+var encryptedFieldsCache sync.Map // map[reflect.Type]*encryptedFieldSet
+
+// encryptedFields returns the encrypted fields of documents of type t, or nil if there are none.
+func encryptedFields(t reflect.Type) *encryptedFieldSet {
+	if cached, ok := encryptedFieldsCache.Load(t); ok {
+		return cached.(*encryptedFieldSet)
+	}
+	set := &encryptedFieldSet{fields: make(map[string]encryptedField), parents: make(map[string]bool)}
+	collectEncryptedFields(indirectType(t), "", "", set, map[reflect.Type]bool{})
+	if len(set.fields) == 0 {
+		set = nil
+	}
+	encryptedFieldsCache.Store(t, set)
+	return set
+}
+
+func collectEncryptedFields(t reflect.Type, prefix, namePrefix string, set *encryptedFieldSet, visiting map[reflect.Type]bool) {
+	if t.Kind() != reflect.Struct || visiting[t] {
+		return
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	for _, f := range structFields(t) {
+		path, name := prefix+f.name, namePrefix+f.name
+		if _, ok := f.tag["encrypt"]; ok {
+			_, deterministic := f.tag["deterministic"]
+			set.fields[path] = encryptedField{path: path, name: name, deterministic: deterministic}
+			parts := strings.Split(path, ".")
+			for i := 1; i < len(parts); i++ {
+				set.parents[strings.Join(parts[:i], ".")] = true
+			}
+			continue
+		}
+		if _, ok := f.tag["document"]; ok {
+			name = ""
+		} else {
+			name += "."
+		}
+		collectEncryptedFields(indirectType(f.field.Type), path+".", name, set, visiting)
+	}
+}
+
+// keyProvider returns the key provider in ctx, or nil.
+func keyProvider(ctx context.Context) KeyProvider {
+	keys, _ := ctx.Value(encryptionKey{}).(KeyProvider)
+	return keys
+}
+
+// Encrypted values are binary values of this user-defined subtype holding:
+// version (1 byte), mode (1 byte), key ID length (1 byte), key ID, nonce, ciphertext.
+const (
+	encryptedSubtype       = 0x80
+	encryptedVersion       = 1
+	modeRandomized    byte = 0
+	modeDeterministic byte = 1
+)
+
+// encryptValue encrypts the value of the field with the current key.
+// The field name is authenticated, so a value cannot be moved to another field.
+func encryptValue(ctx context.Context, keys KeyProvider, field encryptedField, v bson.RawValue) (bson.RawValue, error) {
+	keyID, key, err := keys.CurrentKey(ctx)
+	if err != nil {
+		return v, err
+	}
+	return encryptWithKey(keyID, key, field, v)
+}
+
+// encryptValues encrypts the value of the deterministic field with the current key
+// and, if keys is a KeyLister, with every listed key, so that the ciphertexts match
+// the value whichever of the keys it was written with.
+func encryptValues(ctx context.Context, keys KeyProvider, field encryptedField, v bson.RawValue) ([]bson.RawValue, error) {
+	current, err := encryptValue(ctx, keys, field, v)
+	if err != nil {
+		return nil, err
+	}
+	values := []bson.RawValue{current}
+	lister, ok := keys.(KeyLister)
+	if !ok {
+		return values, nil
+	}
+	currentID, _, err := keys.CurrentKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := lister.KeyIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if id == currentID {
+			continue
+		}
+		key, err := keys.Key(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		value, err := encryptWithKey(id, key, field, v)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// encryptWithKey encrypts the value of the field with the key with the given ID.
+func encryptWithKey(keyID string, key []byte, field encryptedField, v bson.RawValue) (bson.RawValue, error) {
+	if len(keyID) > 255 {
+		return v, fmt.Errorf("monarch: encryption key ID %q is too long", keyID)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return v, err
+	}
+
+	plaintext := append([]byte{byte(v.Type)}, v.Value...)
+	mode := modeRandomized
+	nonce := make([]byte, aead.NonceSize())
+	if field.deterministic {
+		// The nonce is derived from the plaintext, so equal values encrypt equally.
+		mode = modeDeterministic
+		mac := hmac.New(sha256.New, deriveKey(key, "monarch deterministic nonce"))
+		mac.Write([]byte(field.name))
+		mac.Write([]byte{0})
+		mac.Write(plaintext)
+		copy(nonce, mac.Sum(nil))
+	} else if _, err := rand.Read(nonce); err != nil {
+		return v, err
+	}
+
+	data := []byte{encryptedVersion, mode, byte(len(keyID))}
+	data = append(data, keyID...)
+	data = append(data, nonce...)
+	data = aead.Seal(data, nonce, plaintext, []byte(field.name))
+	return bson.RawValue{Type: bson.TypeBinary, Value: bsoncore.AppendBinary(nil, encryptedSubtype, data)}, nil
+}
+
+// decryptValue decrypts the value of the field. Values that are not encrypted are returned unchanged.
+func decryptValue(ctx context.Context, keys KeyProvider, field encryptedField, v bson.RawValue) (bson.RawValue, error) {
+	if v.Type != bson.TypeBinary {
+		return v, nil
+	}
+	subtype, data, ok := bsoncore.Value{Type: bsoncore.Type(v.Type), Data: v.Value}.BinaryOK()
+	if !ok || subtype != encryptedSubtype || len(data) < 3 || data[0] != encryptedVersion {
+		return v, nil
+	}
+	if keys == nil {
+		return v, fmt.Errorf("monarch: cannot decrypt field %q: no key provider in context", field.path)
+	}
+
+	idLen := int(data[2])
+	if len(data) < 3+idLen {
+		return v, fmt.Errorf("monarch: cannot decrypt field %q: malformed value", field.path)
+	}
+	key, err := keys.Key(ctx, string(data[3:3+idLen]))
+	if err != nil {
+		return v, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return v, err
+	}
+	rest := data[3+idLen:]
+	if len(rest) < aead.NonceSize() {
+		return v, fmt.Errorf("monarch: cannot decrypt field %q: malformed value", field.path)
+	}
+	plaintext, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], []byte(field.name))
+	if err != nil {
+		return v, fmt.Errorf("monarch: cannot decrypt field %q: %w", field.path, err)
+	}
+	if len(plaintext) == 0 {
+		return v, fmt.Errorf("monarch: cannot decrypt field %q: malformed value", field.path)
+	}
+	return bson.RawValue{Type: bson.Type(plaintext[0]), Value: plaintext[1:]}, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("monarch: invalid encryption key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// deriveKey derives a subkey of key for the given purpose.
+func deriveKey(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// transformFields returns a copy of doc with the values of the encrypted fields replaced by fn.
+// Keys of doc are matched as paths below prefix, so both nested documents and dotted keys
+// are supported. Null values are left unchanged.
+func (s *encryptedFieldSet) transformFields(doc bson.Raw, prefix string, fn func(encryptedField, bson.RawValue) (bson.RawValue, error)) (bson.Raw, error) {
+	elems, err := doc.Elements()
+	if err != nil {
+		return nil, err
+	}
+	idx, out := bsoncore.AppendDocumentStart(nil)
+	for _, elem := range elems {
+		key, value := elem.Key(), elem.Value()
+		path := prefix + key
+		if field, ok := s.fields[path]; ok && value.Type != bson.TypeNull {
+			if value, err = fn(field, value); err != nil {
+				return nil, err
+			}
+		} else if s.parents[path] && value.Type == bson.TypeEmbeddedDocument {
+			inner, err := s.transformFields(value.Document(), path+".", fn)
+			if err != nil {
+				return nil, err
+			}
+			value = bson.RawValue{Type: bson.TypeEmbeddedDocument, Value: inner}
+		}
+		out = bsoncore.AppendHeader(out, bsoncore.Type(value.Type), key)
+		out = append(out, value.Value...)
+	}
+	out, err = bsoncore.AppendDocumentEnd(out, idx)
+	return bson.Raw(out), err
+}
+
+// encryptDocument returns doc with its encrypted fields encrypted, or doc unchanged
+// if V has no encrypted fields or ctx has no key provider.
+func encryptDocument[V any](ctx context.Context, doc V) (any, error) {
+	set, keys := encryptedFields(reflect.TypeFor[V]()), keyProvider(ctx)
+	if set == nil || keys == nil {
+		return doc, nil
+	}
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return set.transformFields(raw, "", func(field encryptedField, v bson.RawValue) (bson.RawValue, error) {
+		return encryptValue(ctx, keys, field, v)
+	})
+}
+
+// encryptUpdate encrypts the values of encrypted fields of T set by the $set and
+// $setOnInsert operators of update. Returns an error if update writes an encrypted
+// field in any other way, such as with $inc, $push or $rename, or with a pipeline
+// stage other than $unset, since the written values cannot be encrypted.
+func encryptUpdate[T any](ctx context.Context, update any) (any, error) {
+	set, keys := encryptedFields(reflect.TypeFor[T]()), keyProvider(ctx)
+	if set == nil || keys == nil {
+		return update, nil
+	}
+	if isPipeline(update) {
+		return update, set.checkPipelineUpdate(update)
+	}
+	raw, err := bson.Marshal(update)
+	if err != nil {
+		return nil, err
+	}
+	elems, err := bson.Raw(raw).Elements()
+	if err != nil {
+		return nil, err
+	}
+	idx, out := bsoncore.AppendDocumentStart(nil)
+	for _, elem := range elems {
+		op, value := elem.Key(), elem.Value()
+		switch {
+		case (op == "$set" || op == "$setOnInsert") && value.Type == bson.TypeEmbeddedDocument:
+			doc, err := set.transformFields(value.Document(), "", func(field encryptedField, v bson.RawValue) (bson.RawValue, error) {
+				return encryptValue(ctx, keys, field, v)
+			})
+			if err != nil {
+				return nil, err
+			}
+			value = bson.RawValue{Type: bson.TypeEmbeddedDocument, Value: doc}
+		case op != "$unset" && value.Type == bson.TypeEmbeddedDocument:
+			if err := set.checkUpdateOperator(op, value.Document()); err != nil {
+				return nil, err
+			}
+		}
+		out = bsoncore.AppendHeader(out, bsoncore.Type(value.Type), op)
+		out = append(out, value.Value...)
+	}
+	out, err = bsoncore.AppendDocumentEnd(out, idx)
+	return bson.Raw(out), err
+}
+
+// checkUpdateOperator returns an error if the update operator op writes an encrypted
+// field. The targets of $rename are checked as well as its sources.
+func (s *encryptedFieldSet) checkUpdateOperator(op string, fields bson.Raw) error {
+	elems, err := fields.Elements()
+	if err != nil {
+		return err
+	}
+	for _, elem := range elems {
+		paths := []string{elem.Key()}
+		if target, ok := elem.Value().StringValueOK(); ok && op == "$rename" {
+			paths = append(paths, target)
+		}
+		for _, path := range paths {
+			if field, ok := s.overlaps(path); ok {
+				return fmt.Errorf("monarch: operator %s cannot write encrypted field %q, use $set", op, field)
+			}
+		}
+	}
+	return nil
+}
+
+// checkPipelineUpdate returns an error if a stage of the pipeline update writes an
+// encrypted field. Stages that may write any field, such as $replaceWith, are rejected.
+func (s *encryptedFieldSet) checkPipelineUpdate(update any) error {
+	stages, err := pipelineStages(update)
+	if err != nil {
+		return err
+	}
+	for _, stage := range stages {
+		raw, err := bson.Marshal(stage)
+		if err != nil {
+			return err
+		}
+		elems, err := bson.Raw(raw).Elements()
+		if err != nil {
+			return err
+		}
+		for _, elem := range elems {
+			switch op := elem.Key(); op {
+			case "$unset":
+			case "$set", "$addFields":
+				doc, ok := elem.Value().DocumentOK()
+				if !ok {
+					continue
+				}
+				if err := s.checkUpdateOperator(op, doc); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("monarch: pipeline stage %s cannot be used on documents with encrypted fields", op)
+			}
+		}
+	}
+	return nil
+}
+
+// overlaps returns the encrypted field written by writing path: the field itself, a
+// field nested within path, or the field containing path.
+func (s *encryptedFieldSet) overlaps(path string) (string, bool) {
+	if _, ok := s.fields[path]; ok {
+		return path, true
+	}
+	for field := range s.fields {
+		if pathsOverlap(path, field) {
+			return field, true
+		}
+	}
+	return "", false
+}
+
+// pathsOverlap reports whether the dotted paths a and b are equal or one contains the other.
+func pathsOverlap(a, b string) bool {
+	return a == b || strings.HasPrefix(a, b+".") || strings.HasPrefix(b, a+".")
+}
+
+// isPipeline reports whether update is an aggregation pipeline rather than an update document.
+func isPipeline(update any) bool {
+	switch update.(type) {
+	case bson.D, bson.Raw, []byte:
+		return false
+	}
+	kind := reflect.Indirect(reflect.ValueOf(update)).Kind()
+	return kind == reflect.Slice || kind == reflect.Array
+}
+
+// encryptFilter encrypts the values compared with deterministic encrypted fields of T
+// in filter, including within $and, $or and $nor. Returns an error if filter queries a
+// randomized encrypted field or uses an operator other than $eq, $ne, $in and $nin on
+// an encrypted field.
+func encryptFilter[T any](ctx context.Context, filter any) (any, error) {
+	set, keys := encryptedFields(reflect.TypeFor[T]()), keyProvider(ctx)
+	if set == nil || keys == nil || filter == nil {
+		return filter, nil
+	}
+	raw, err := bson.Marshal(filter)
+	if err != nil {
+		return nil, err
+	}
+	return set.encryptFilter(ctx, keys, raw)
+}
+
+func (s *encryptedFieldSet) encryptFilter(ctx context.Context, keys KeyProvider, filter bson.Raw) (bson.Raw, error) {
+	elems, err := filter.Elements()
+	if err != nil {
+		return nil, err
+	}
+	idx, out := bsoncore.AppendDocumentStart(nil)
+	for _, elem := range elems {
+		key, value := elem.Key(), elem.Value()
+		switch field, ok := s.fields[key]; {
+		case key == "$and" || key == "$or" || key == "$nor":
+			if value, err = s.mapArray(value, func(v bson.RawValue) (bson.RawValue, error) {
+				if v.Type != bson.TypeEmbeddedDocument {
+					return v, nil
+				}
+				doc, err := s.encryptFilter(ctx, keys, v.Document())
+				return bson.RawValue{Type: bson.TypeEmbeddedDocument, Value: doc}, err
+			}); err != nil {
+				return nil, err
+			}
+		case ok:
+			if !field.deterministic {
+				return nil, fmt.Errorf("monarch: cannot filter on randomized encrypted field %q, tag it `monarch:\"encrypt,deterministic\"`", key)
+			}
+			if value, err = s.encryptCondition(ctx, keys, field, value); err != nil {
+				return nil, err
+			}
+		case s.parents[key]:
+			if comparesDocument(value) {
+				return nil, fmt.Errorf("monarch: cannot compare document %q holding encrypted fields by equality, filter on its fields instead", key)
+			}
+		}
+		out = bsoncore.AppendHeader(out, bsoncore.Type(value.Type), key)
+		out = append(out, value.Value...)
+	}
+	out, err = bsoncore.AppendDocumentEnd(out, idx)
+	return bson.Raw(out), err
+}
+
+// encryptCondition encrypts the values of a filter condition on a deterministic field.
+// Values are compared with their ciphertexts under every key from encryptValues, so
+// equality conditions become $in and inequality conditions become $nin.
+func (s *encryptedFieldSet) encryptCondition(ctx context.Context, keys KeyProvider, field encryptedField, cond bson.RawValue) (bson.RawValue, error) {
+	encrypt := func(v bson.RawValue) ([]bson.RawValue, error) {
+		if v.Type == bson.TypeNull {
+			return []bson.RawValue{v}, nil
+		}
+		return encryptValues(ctx, keys, field, v)
+	}
+	encryptAll := func(arr bson.RawValue) ([]bson.RawValue, error) {
+		if arr.Type != bson.TypeArray {
+			return nil, fmt.Errorf("monarch: operator value on encrypted field %q must be an array", field.path)
+		}
+		values, err := arr.Array().Values()
+		if err != nil {
+			return nil, err
+		}
+		var all []bson.RawValue
+		for _, v := range values {
+			encrypted, err := encrypt(v)
+			if err != nil {
+				return nil, err
+			}
+			all = append(all, encrypted...)
+		}
+		return all, nil
+	}
+
+	if cond.Type == bson.TypeEmbeddedDocument {
+		ops, err := cond.Document().Elements()
+		if err != nil {
+			return cond, err
+		}
+		if len(ops) > 0 && strings.HasPrefix(ops[0].Key(), "$") {
+			var in, nin []bson.RawValue
+			var hasIn, hasNin bool
+			for _, op := range ops {
+				var values []bson.RawValue
+				switch op.Key() {
+				case "$eq", "$ne":
+					values, err = encrypt(op.Value())
+				case "$in", "$nin":
+					values, err = encryptAll(op.Value())
+				default:
+					err = fmt.Errorf("monarch: operator %s is not supported on encrypted field %q", op.Key(), field.path)
+				}
+				if err != nil {
+					return cond, err
+				}
+				if op.Key() == "$eq" || op.Key() == "$in" {
+					if hasIn {
+						return cond, fmt.Errorf("monarch: cannot combine $eq and $in on encrypted field %q", field.path)
+					}
+					in, hasIn = values, true
+				} else {
+					// Exclusions are conjunctive, so they are merged into a single $nin.
+					nin, hasNin = append(nin, values...), true
+				}
+			}
+			doc := bson.D{}
+			if hasIn {
+				doc = append(doc, bson.E{Key: "$in", Value: in})
+			}
+			if hasNin {
+				doc = append(doc, bson.E{Key: "$nin", Value: nin})
+			}
+			return marshalValue(doc)
+		}
+		// Otherwise an embedded document compared by equality.
+	}
+
+	values, err := encrypt(cond)
+	if err != nil {
+		return cond, err
+	}
+	if len(values) == 1 {
+		return values[0], nil
+	}
+	return marshalValue(bson.D{{Key: "$in", Value: values}})
+}
+
+// comparesDocument reports whether the filter condition compares a field by equality
+// with a document, directly or with $eq, $ne, $in or $nin.
+func comparesDocument(cond bson.RawValue) bool {
+	if cond.Type != bson.TypeEmbeddedDocument {
+		return false
+	}
+	ops, err := cond.Document().Elements()
+	if err != nil {
+		return false
+	}
+	if len(ops) == 0 || !strings.HasPrefix(ops[0].Key(), "$") {
+		return true
+	}
+	for _, op := range ops {
+		value := op.Value()
+		switch op.Key() {
+		case "$eq", "$ne":
+			if value.Type == bson.TypeEmbeddedDocument {
+				return true
+			}
+		case "$in", "$nin":
+			if value.Type != bson.TypeArray {
+				continue
+			}
+			values, _ := value.Array().Values()
+			for _, v := range values {
+				if v.Type == bson.TypeEmbeddedDocument {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// marshalValue returns v as a raw value.
+func marshalValue(v any) (bson.RawValue, error) {
+	typ, data, err := bson.MarshalValue(v)
+	return bson.RawValue{Type: typ, Value: data}, err
+}
+
+// mapArray returns the array value with fn applied to each element.
+func (s *encryptedFieldSet) mapArray(arr bson.RawValue, fn func(bson.RawValue) (bson.RawValue, error)) (bson.RawValue, error) {
+	if arr.Type != bson.TypeArray {
+		return arr, nil
+	}
+	values, err := arr.Array().Values()
+	if err != nil {
+		return arr, err
+	}
+	idx, out := bsoncore.AppendArrayStart(nil)
+	for i, v := range values {
+		if v, err = fn(v); err != nil {
+			return arr, err
+		}
+		out = bsoncore.AppendHeader(out, bsoncore.Type(v.Type), fmt.Sprint(i))
+		out = append(out, v.Value...)
+	}
+	out, err = bsoncore.AppendArrayEnd(out, idx)
+	return bson.RawValue{Type: bson.TypeArray, Value: out}, err
+}
+
+// decodeRaw decodes raw into the pointer v, decrypting the encrypted fields of the
+// type v points to.
+func decodeRaw(ctx context.Context, raw bson.Raw, v any) error {
+	if set := encryptedFields(reflect.TypeOf(v).Elem()); set != nil {
+		keys := keyProvider(ctx)
+		var err error
+		raw, err = set.transformFields(raw, "", func(field encryptedField, value bson.RawValue) (bson.RawValue, error) {
+			return decryptValue(ctx, keys, field, value)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return bson.Unmarshal(raw, v)
+}
+
+// decodeSingle decodes the document of result into v, decrypting the encrypted fields of V.
+func decodeSingle[V any](ctx context.Context, result *mongo.SingleResult, v *V) error {
+	if encryptedFields(reflect.TypeFor[V]()) == nil {
+		return result.Decode(v)
+	}
+	raw, err := result.Raw()
+	if err != nil {
+		return err
+	}
+	return decodeRaw(ctx, raw, v)
+}
+
+// decodeCurrent decodes the current document of cursor into v, decrypting the encrypted fields of V.
+func decodeCurrent[V any](ctx context.Context, cursor *mongo.Cursor, v *V) error {
+	if encryptedFields(reflect.TypeFor[V]()) == nil {
+		return cursor.Decode(v)
+	}
+	return decodeRaw(ctx, cursor.Current, v)
+}
+
+// decodeAll decodes all documents of cursor into results and closes the cursor,
+// decrypting the encrypted fields of V.
+func decodeAll[V any](ctx context.Context, cursor *mongo.Cursor, results *[]V) error {
+	if encryptedFields(reflect.TypeFor[V]()) == nil {
+		return cursor.All(ctx, results)
+	}
+	defer cursor.Close(ctx)
+	*results = make([]V, 0)
+	for cursor.Next(ctx) {
+		var v V
+		if err := decodeRaw(ctx, cursor.Current, &v); err != nil {
+			return err
+		}
+		*results = append(*results, v)
+	}
+	return cursor.Err()
+}
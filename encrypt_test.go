@@ -0,0 +1,361 @@
+package monarch
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type Patient struct {
+	ID      string  `bson:"_id"`
+	Name    string  `bson:"name"`
+	SSN     string  `bson:"ssn" monarch:"encrypt,deterministic"`
+	Notes   string  `bson:"notes,omitempty" monarch:"encrypt"`
+	Contact Contact `bson:"contact"`
+}
+
+type Contact struct {
+	Phone string `bson:"phone" monarch:"encrypt"`
+	City  string `bson:"city"`
+}
+
+var Patients Collection[Patient] = "patients"
+
+func testKeys() LocalKeys {
+	return LocalKey(bytes.Repeat([]byte{1}, 32))
+}
+
+func TestEncryptedFields(t *testing.T) {
+	set := encryptedFields(reflect.TypeFor[Patient]())
+	if set == nil {
+		t.Fatal("expected encrypted fields")
+	}
+	for path, deterministic := range map[string]bool{"ssn": true, "notes": false, "contact.phone": false} {
+		field, ok := set.fields[path]
+		if !ok || field.deterministic != deterministic {
+			t.Errorf("unexpected field %q: %+v, %v", path, field, ok)
+		}
+	}
+	if !set.parents["contact"] {
+		t.Errorf("expected contact to be a parent of encrypted fields")
+	}
+	if encryptedFields(reflect.TypeFor[User]()) != nil {
+		t.Errorf("expected no encrypted fields for User")
+	}
+}
+
+func TestEncryptDocument(t *testing.T) {
+	ctx := WithEncryption(context.Background(), testKeys())
+	patient := Patient{ID: "p1", Name: "Ada", SSN: "123-45-6789", Notes: "private", Contact: Contact{Phone: "555", City: "London"}}
+
+	doc, err := encryptDocument(ctx, patient)
+	if err != nil {
+		t.Fatalf("encryptDocument failed: %v", err)
+	}
+	raw := doc.(bson.Raw)
+	for _, path := range [][]string{{"ssn"}, {"notes"}, {"contact", "phone"}} {
+		if v := raw.Lookup(path...); v.Type != bson.TypeBinary {
+			t.Errorf("expected %v to be encrypted, got %v", path, v)
+		}
+	}
+	if name := raw.Lookup("name").StringValue(); name != "Ada" {
+		t.Errorf("expected name to be unencrypted, got %q", name)
+	}
+	if city := raw.Lookup("contact", "city").StringValue(); city != "London" {
+		t.Errorf("expected city to be unencrypted, got %q", city)
+	}
+
+	var decoded Patient
+	if err := decodeRaw(ctx, raw, &decoded); err != nil {
+		t.Fatalf("decodeRaw failed: %v", err)
+	}
+	if decoded != patient {
+		t.Errorf("expected %+v, got %+v", patient, decoded)
+	}
+
+	// Documents embedded in wrapper types decrypt as in their collection.
+	var version Version[Patient]
+	if err := decodeRaw(ctx, mustMarshal(t, bson.D{{Key: "document", Value: raw}}), &version); err != nil {
+		t.Fatalf("decodeRaw of version failed: %v", err)
+	}
+	if version.Document != patient {
+		t.Errorf("expected version document %+v, got %+v", patient, version.Document)
+	}
+
+	// Deterministic fields encrypt equally, randomized fields do not.
+	again, err := encryptDocument(ctx, patient)
+	if err != nil {
+		t.Fatalf("encryptDocument failed: %v", err)
+	}
+	if !again.(bson.Raw).Lookup("ssn").Equal(raw.Lookup("ssn")) {
+		t.Errorf("expected deterministic ssn ciphertexts to be equal")
+	}
+	if again.(bson.Raw).Lookup("notes").Equal(raw.Lookup("notes")) {
+		t.Errorf("expected randomized notes ciphertexts to differ")
+	}
+
+	// Without a key provider, documents are written and read unchanged.
+	if doc, _ := encryptDocument(context.Background(), patient); doc != any(patient) {
+		t.Errorf("expected document to be unchanged without a key provider")
+	}
+	if err := decodeRaw(context.Background(), raw, &decoded); err == nil {
+		t.Errorf("expected decrypting without a key provider to fail")
+	}
+}
+
+func TestDecryptValue(t *testing.T) {
+	ctx := context.Background()
+	keys := testKeys()
+	field := encryptedField{path: "notes", name: "notes"}
+	value := bson.RawValue{Type: bson.TypeString, Value: mustMarshal(t, bson.D{{Key: "v", Value: "secret"}}).Lookup("v").Value}
+
+	encrypted, err := encryptValue(ctx, keys, field, value)
+	if err != nil {
+		t.Fatalf("encryptValue failed: %v", err)
+	}
+	decrypted, err := decryptValue(ctx, keys, field, encrypted)
+	if err != nil || !decrypted.Equal(value) {
+		t.Errorf("expected %v, got %v, %v", value, decrypted, err)
+	}
+
+	// The field path is authenticated.
+	if _, err := decryptValue(ctx, keys, encryptedField{path: "other", name: "other"}, encrypted); err == nil {
+		t.Errorf("expected decrypting under another path to fail")
+	}
+
+	// Rotated keys remain available for decryption.
+	rotated := LocalKeys{Current: "new", Keys: map[string][]byte{"local": keys.Keys["local"], "new": bytes.Repeat([]byte{2}, 16)}}
+	if decrypted, err := decryptValue(ctx, rotated, field, encrypted); err != nil || !decrypted.Equal(value) {
+		t.Errorf("expected decryption with a previous key, got %v, %v", decrypted, err)
+	}
+	if _, err := decryptValue(ctx, LocalKey(bytes.Repeat([]byte{3}, 32)), field, encrypted); err == nil {
+		t.Errorf("expected decryption with an unknown key ID to fail")
+	}
+
+	// Values that are not encrypted are returned unchanged.
+	if decrypted, err := decryptValue(ctx, keys, field, value); err != nil || !decrypted.Equal(value) {
+		t.Errorf("expected unencrypted value unchanged, got %v, %v", decrypted, err)
+	}
+}
+
+func TestEncryptFilter(t *testing.T) {
+	ctx := WithEncryption(context.Background(), testKeys())
+	ssn, err := encryptValue(ctx, testKeys(), encryptedField{path: "ssn", name: "ssn", deterministic: true},
+		mustMarshal(t, bson.D{{Key: "v", Value: "123"}}).Lookup("v"))
+	if err != nil {
+		t.Fatalf("encryptValue failed: %v", err)
+	}
+
+	filter, err := encryptFilter[Patient](ctx, bson.D{
+		{Key: "name", Value: "Ada"},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "ssn", Value: "123"}},
+			bson.D{{Key: "ssn", Value: bson.D{{Key: "$in", Value: bson.A{"123"}}}}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("encryptFilter failed: %v", err)
+	}
+	raw := filter.(bson.Raw)
+	if name := raw.Lookup("name").StringValue(); name != "Ada" {
+		t.Errorf("expected name unchanged, got %q", name)
+	}
+	if got := raw.Lookup("$or", "0", "ssn"); !got.Equal(ssn) {
+		t.Errorf("expected encrypted ssn in $or, got %v", got)
+	}
+	if got := raw.Lookup("$or", "1", "ssn", "$in", "0"); !got.Equal(ssn) {
+		t.Errorf("expected encrypted ssn in $in, got %v", got)
+	}
+
+	if _, err := encryptFilter[Patient](ctx, bson.D{{Key: "notes", Value: "private"}}); err == nil {
+		t.Errorf("expected filtering on a randomized field to fail")
+	}
+	if _, err := encryptFilter[Patient](ctx, bson.D{{Key: "ssn", Value: bson.D{{Key: "$gt", Value: "1"}}}}); err == nil {
+		t.Errorf("expected range filters on an encrypted field to fail")
+	}
+
+	for _, filter := range []bson.D{
+		{{Key: "contact", Value: bson.D{{Key: "phone", Value: "555"}, {Key: "city", Value: "London"}}}},
+		{{Key: "contact", Value: bson.D{{Key: "$ne", Value: bson.D{{Key: "phone", Value: "555"}}}}}},
+		{{Key: "contact", Value: bson.D{{Key: "$in", Value: bson.A{bson.D{{Key: "phone", Value: "555"}}}}}}},
+	} {
+		if _, err := encryptFilter[Patient](ctx, filter); err == nil {
+			t.Errorf("expected comparing a document holding encrypted fields to fail: %v", filter)
+		}
+	}
+	if _, err := encryptFilter[Patient](ctx, bson.D{{Key: "contact", Value: bson.D{{Key: "$exists", Value: true}}}}); err != nil {
+		t.Errorf("expected $exists on a document holding encrypted fields to pass, got %v", err)
+	}
+}
+
+func TestEncryptFilterKeyRotation(t *testing.T) {
+	ssnField := encryptedField{path: "ssn", name: "ssn", deterministic: true}
+	value := mustMarshal(t, bson.D{{Key: "v", Value: "123"}}).Lookup("v")
+	oldKeys := LocalKeys{Current: "old", Keys: map[string][]byte{"old": bytes.Repeat([]byte{1}, 32)}}
+	newKeys := LocalKeys{Current: "new", Keys: map[string][]byte{"old": oldKeys.Keys["old"], "new": bytes.Repeat([]byte{2}, 32)}}
+	ctx := WithEncryption(context.Background(), newKeys)
+
+	oldSSN, err := encryptValue(ctx, oldKeys, ssnField, value)
+	if err != nil {
+		t.Fatalf("encryptValue failed: %v", err)
+	}
+	newSSN, err := encryptValue(ctx, newKeys, ssnField, value)
+	if err != nil {
+		t.Fatalf("encryptValue failed: %v", err)
+	}
+
+	filter, err := encryptFilter[Patient](ctx, bson.D{{Key: "ssn", Value: "123"}})
+	if err != nil {
+		t.Fatalf("encryptFilter failed: %v", err)
+	}
+	in := filter.(bson.Raw).Lookup("ssn", "$in")
+	if got := in.Array().Index(0); !got.Equal(newSSN) {
+		t.Errorf("expected the current key ciphertext first, got %v", got)
+	}
+	if got := in.Array().Index(1); !got.Equal(oldSSN) {
+		t.Errorf("expected the previous key ciphertext, got %v", got)
+	}
+
+	filter, err = encryptFilter[Patient](ctx, bson.D{{Key: "ssn", Value: bson.D{{Key: "$ne", Value: "123"}, {Key: "$nin", Value: bson.A{"456"}}}}})
+	if err != nil {
+		t.Fatalf("encryptFilter failed: %v", err)
+	}
+	if values, _ := filter.(bson.Raw).Lookup("ssn", "$nin").Array().Values(); len(values) != 4 {
+		t.Errorf("expected $ne and $nin merged into $nin over both keys, got %v", filter)
+	}
+
+	if _, err := encryptFilter[Patient](ctx, bson.D{{Key: "ssn", Value: bson.D{{Key: "$eq", Value: "123"}, {Key: "$in", Value: bson.A{"123"}}}}}); err == nil {
+		t.Errorf("expected combining $eq and $in on an encrypted field to fail")
+	}
+}
+
+func TestEncryptUpdate(t *testing.T) {
+	ctx := WithEncryption(context.Background(), testKeys())
+	update, err := encryptUpdate[Patient](ctx, bson.D{
+		{Key: "$set", Value: bson.D{{Key: "notes", Value: "private"}, {Key: "contact.phone", Value: "555"}}},
+		{Key: "$inc", Value: bson.D{{Key: "visits", Value: 1}}},
+	})
+	if err != nil {
+		t.Fatalf("encryptUpdate failed: %v", err)
+	}
+	raw := update.(bson.Raw)
+	set := raw.Lookup("$set").Document()
+	if set.Lookup("notes").Type != bson.TypeBinary || set.Lookup("contact.phone").Type != bson.TypeBinary {
+		t.Errorf("expected $set values to be encrypted, got %v", set)
+	}
+	if raw.Lookup("$inc", "visits").Int32() != 1 {
+		t.Errorf("expected $inc unchanged, got %v", raw.Lookup("$inc"))
+	}
+}
+
+func TestEncryptUpdateRejectsUnencryptableWrites(t *testing.T) {
+	ctx := WithEncryption(context.Background(), testKeys())
+	for name, update := range map[string]any{
+		"$max":         bson.D{{Key: "$max", Value: bson.D{{Key: "ssn", Value: "999"}}}},
+		"$push":        bson.D{{Key: "$push", Value: bson.D{{Key: "contact.phone", Value: "555"}}}},
+		"$rename from": bson.D{{Key: "$rename", Value: bson.D{{Key: "notes", Value: "memo"}}}},
+		"$rename to":   bson.D{{Key: "$rename", Value: bson.D{{Key: "memo", Value: "notes"}}}},
+		"parent":       bson.D{{Key: "$min", Value: bson.D{{Key: "contact", Value: bson.D{}}}}},
+		"pipeline":     bson.A{bson.D{{Key: "$set", Value: bson.D{{Key: "notes", Value: "$name"}}}}},
+		"replaceWith":  bson.A{bson.D{{Key: "$replaceWith", Value: "$$ROOT"}}},
+	} {
+		if _, err := encryptUpdate[Patient](ctx, update); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	for name, update := range map[string]any{
+		"$unset":   bson.D{{Key: "$unset", Value: bson.D{{Key: "notes", Value: ""}}}},
+		"$inc":     bson.D{{Key: "$inc", Value: bson.D{{Key: "visits", Value: 1}}}},
+		"sibling":  bson.D{{Key: "$max", Value: bson.D{{Key: "contact.city", Value: "Lagos"}}}},
+		"pipeline": bson.A{bson.D{{Key: "$set", Value: bson.D{{Key: "name", Value: "$contact.city"}}}}, bson.D{{Key: "$unset", Value: "notes"}}},
+	} {
+		if _, err := encryptUpdate[Patient](ctx, update); err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		}
+	}
+}
+
+func TestEncryptedCache(t *testing.T) {
+	lru := NewLRU(10)
+	config := CacheConfig{Cache: lru, Collections: map[string]time.Duration{"patients": time.Minute}}
+	ctx := WithEncryption(WithCache(context.Background(), config), testKeys())
+
+	find := func() (Patient, error) {
+		return Patient{ID: "p1", Name: "Ada", SSN: "123-45-6789"}, nil
+	}
+	filter := bson.D{{Key: "_id", Value: "p1"}}
+	if _, err := Patients.cachedFindOne(ctx, config, time.Minute, filter, find); err != nil {
+		t.Fatalf("cachedFindOne failed: %v", err)
+	}
+	key, err := Patients.cacheEntryKey(ctx, lru, filter)
+	if err != nil {
+		t.Fatalf("cacheEntryKey failed: %v", err)
+	}
+	value, ok := lru.Get(ctx, key)
+	if !ok {
+		t.Fatalf("expected the patient to be cached")
+	}
+	if bytes.Contains(value, []byte("123-45-6789")) {
+		t.Errorf("expected the cached ssn to be encrypted")
+	}
+
+	patient, err := Patients.cachedFindOne(ctx, config, time.Minute, filter, func() (Patient, error) {
+		t.Fatalf("expected a cache hit")
+		return Patient{}, nil
+	})
+	if err != nil || patient.SSN != "123-45-6789" {
+		t.Errorf("expected the cached patient decrypted, got %+v, %v", patient, err)
+	}
+}
+
+func TestEncryption(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := WithEncryption(WithContext(context.Background(), db), testKeys())
+
+	cleanupCollection(t, ctx, Patients)
+
+	patient := Patient{ID: "enc1", Name: "Ada", SSN: "123-45-6789", Notes: "private", Contact: Contact{Phone: "555", City: "London"}}
+	if _, err := Patients.InsertOne(ctx, patient); err != nil {
+		t.Fatalf("InsertOne failed: %v", err)
+	}
+
+	stored, err := FindOneAs[bson.Raw](WithContext(context.Background(), db), Patients, bson.D{{Key: "_id", Value: "enc1"}})
+	if err != nil {
+		t.Fatalf("FindOneAs failed: %v", err)
+	}
+	if stored.Lookup("ssn").Type != bson.TypeBinary || stored.Lookup("contact", "phone").Type != bson.TypeBinary {
+		t.Errorf("expected encrypted fields to be stored encrypted, got %v", stored)
+	}
+
+	found, err := Patients.FindOne(ctx, bson.D{{Key: "ssn", Value: "123-45-6789"}})
+	if err != nil || found != patient {
+		t.Errorf("expected %+v, got %+v, %v", patient, found, err)
+	}
+
+	if _, err := Patients.UpdateOne(ctx, bson.D{{Key: "_id", Value: "enc1"}}, bson.D{{Key: "$set", Value: bson.D{{Key: "notes", Value: "updated"}}}}); err != nil {
+		t.Fatalf("UpdateOne failed: %v", err)
+	}
+	patients, err := Patients.Find(ctx, bson.D{{Key: "ssn", Value: bson.D{{Key: "$in", Value: bson.A{"123-45-6789"}}}}})
+	if err != nil || len(patients) != 1 || patients[0].Notes != "updated" {
+		t.Errorf("expected updated notes, got %+v, %v", patients, err)
+	}
+
+	if _, err := Patients.Find(ctx, bson.D{{Key: "notes", Value: "updated"}}); err == nil {
+		t.Errorf("expected filtering on a randomized field to fail")
+	}
+
+	// Values written with a previous key still match after a rotation.
+	rotated := testKeys()
+	rotated.Keys["next"] = bytes.Repeat([]byte{2}, 32)
+	rotated.Current = "next"
+	found, err = Patients.FindOne(WithEncryption(ctx, rotated), bson.D{{Key: "ssn", Value: "123-45-6789"}})
+	if err != nil || found.ID != "enc1" {
+		t.Errorf("expected enc1 found after key rotation, got %+v, %v", found, err)
+	}
+}
@@ -0,0 +1,224 @@
+package monarch
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// OpError wraps an error returned by the driver with the collection and operation that caused it.
+//
+// The driver error is available through errors.Is and errors.As, so checks such as
+// mongo.IsDuplicateKeyError and errors.As(err, &mongo.WriteException{}) keep working.
+type OpError struct {
+	// Collection is the name of the collection the operation was executed on.
+	Collection string
+	// Op is the name of the monarch operation, e.g. "InsertOne".
+	Op string
+	// Err is the underlying driver error.
+	Err error
+}
+
+func (e *OpError) Error() string {
+	return fmt.Sprintf("monarch: %s.%s: %v", e.Collection, e.Op, e.Err)
+}
+
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
+
+// wrapError wraps a driver error with the collection name and operation.
+//
+// mongo.ErrNoDocuments reports that nothing matched rather than a failure,
+// so it is returned unchanged and may still be compared directly.
+func wrapError(collection, op string, err error) error {
+	if err == nil || err == mongo.ErrNoDocuments || err == ErrNoDatabase {
+		return err
+	}
+	return &OpError{Collection: collection, Op: op, Err: err}
+}
+
+// Server error codes used to classify errors.
+const (
+	codeDuplicateKey       = 11000
+	codeWriteConflict      = 112
+	codeValidationFailure  = 121
+	codeDuplicateKeyUpdate = 11001
+	codeDuplicateKeyCapped = 12582
+)
+
+// retryableCodes are the server error codes that the driver treats as retryable.
+var retryableCodes = []int{6, 7, 89, 91, 189, 262, 9001, 10107, 11600, 11602, 13435, 13436}
+
+// serverErrorDoc is a single error reported by the server.
+type serverErrorDoc struct {
+	code    int
+	message string
+	// details holds errInfo, if the server provided it.
+	details bson.Raw
+	// raw holds the original error document or command reply.
+	raw bson.Raw
+}
+
+// serverErrors returns the individual errors reported by the server in err.
+func serverErrors(err error) []serverErrorDoc {
+	var docs []serverErrorDoc
+	if we := (mongo.WriteException{}); errors.As(err, &we) {
+		for _, e := range we.WriteErrors {
+			docs = append(docs, serverErrorDoc{code: e.Code, message: e.Message, details: e.Details, raw: e.Raw})
+		}
+		if wce := we.WriteConcernError; wce != nil {
+			docs = append(docs, serverErrorDoc{code: wce.Code, message: wce.Message, details: wce.Details, raw: wce.Raw})
+		}
+	}
+	if bwe := (mongo.BulkWriteException{}); errors.As(err, &bwe) {
+		for _, e := range bwe.WriteErrors {
+			docs = append(docs, serverErrorDoc{code: e.Code, message: e.Message, details: e.Details, raw: e.Raw})
+		}
+		if wce := bwe.WriteConcernError; wce != nil {
+			docs = append(docs, serverErrorDoc{code: wce.Code, message: wce.Message, details: wce.Details, raw: wce.Raw})
+		}
+	}
+	if ce := (mongo.CommandError{}); errors.As(err, &ce) {
+		details, _ := ce.Raw.Lookup("errInfo").DocumentOK()
+		docs = append(docs, serverErrorDoc{code: int(ce.Code), message: ce.Message, details: details, raw: ce.Raw})
+	}
+	return docs
+}
+
+// hasCode reports whether err is a server error with any of the given codes.
+func hasCode(err error, codes ...int) bool {
+	var se mongo.ServerError
+	if !errors.As(err, &se) {
+		return false
+	}
+	return slices.ContainsFunc(se.ErrorCodes(), func(code int) bool {
+		return slices.Contains(codes, code)
+	})
+}
+
+// hasLabel reports whether err is labeled with label by the server or driver.
+func hasLabel(err error, label string) bool {
+	var le mongo.LabeledError
+	return errors.As(err, &le) && le.HasErrorLabel(label)
+}
+
+// DuplicateKey describes a unique index violation.
+type DuplicateKey struct {
+	// Index is the name of the violated unique index.
+	Index string
+	// Key holds the duplicated key values, e.g. {email: "alice@example.com"}.
+	// It is nil if the server did not report the key values.
+	Key bson.D
+}
+
+var duplicateKeyIndexPattern = regexp.MustCompile(`index: (\S+) dup key`)
+
+// IsDuplicateKey reports whether err is caused by a unique index violation and
+// returns the offending index name and key values.
+//
+// For bulk writes, the first duplicate key error is described.
+func IsDuplicateKey(err error) (DuplicateKey, bool) {
+	if !mongo.IsDuplicateKeyError(err) {
+		return DuplicateKey{}, false
+	}
+	var dup DuplicateKey
+	for _, doc := range serverErrors(err) {
+		if doc.code != codeDuplicateKey && doc.code != codeDuplicateKeyUpdate && doc.code != codeDuplicateKeyCapped {
+			continue
+		}
+		if m := duplicateKeyIndexPattern.FindStringSubmatch(doc.message); m != nil {
+			dup.Index = m[1]
+		}
+		if keyValue, ok := doc.raw.Lookup("keyValue").DocumentOK(); ok {
+			_ = bson.Unmarshal(keyValue, &dup.Key)
+		}
+		break
+	}
+	return dup, true
+}
+
+// IsTimeout reports whether err was caused by a timeout, including context
+// deadlines, server selection timeouts and operations exceeding maxTimeMS.
+//
+// See [mongo.IsTimeout] for more details.
+func IsTimeout(err error) bool {
+	return mongo.IsTimeout(err)
+}
+
+// IsNetwork reports whether err was caused by a network failure.
+//
+// See [mongo.IsNetworkError] for more details.
+func IsNetwork(err error) bool {
+	return mongo.IsNetworkError(err)
+}
+
+// IsRetryable reports whether the operation that caused err may succeed if retried.
+//
+// This includes network errors, errors labeled RetryableWriteError, and server
+// errors with codes the driver considers retryable, such as those returned during
+// a replica set election. It does not include TransientTransactionError, which
+// requires retrying the whole transaction.
+func IsRetryable(err error) bool {
+	return IsNetwork(err) || hasLabel(err, "RetryableWriteError") || hasCode(err, retryableCodes...)
+}
+
+// IsWriteConflict reports whether err was caused by a write conflict with a
+// concurrent operation, typically within a transaction.
+func IsWriteConflict(err error) bool {
+	return hasCode(err, codeWriteConflict)
+}
+
+// ValidationFailure describes a document that failed collection schema validation.
+type ValidationFailure struct {
+	// DocumentID is the _id of the failing document.
+	DocumentID bson.RawValue
+	// Operator is the top-level validation operator that failed, e.g. "$jsonSchema".
+	Operator string
+	// Rules lists the rules that were not satisfied.
+	Rules []ValidationRule
+	// Details holds the unparsed validation details reported by the server.
+	Details bson.Raw
+}
+
+// ValidationRule describes a single unsatisfied validation rule.
+type ValidationRule struct {
+	// Operator is the rule operator, e.g. "required" or "properties".
+	Operator string `bson:"operatorName"`
+	// MissingProperties lists required properties that were missing.
+	MissingProperties []string `bson:"missingProperties"`
+	// Properties lists properties whose values did not satisfy the rule.
+	Properties []ValidationProperty `bson:"propertiesNotSatisfied"`
+}
+
+// ValidationProperty describes a property that did not satisfy a validation rule.
+type ValidationProperty struct {
+	// Name is the property name.
+	Name string `bson:"propertyName"`
+	// Details describes each failing condition, e.g. {operatorName: "bsonType", reason: "type did not match"}.
+	Details []bson.Raw `bson:"details"`
+}
+
+// IsDocumentValidationFailure reports whether err is caused by a document failing
+// collection schema validation and returns the parsed validation details.
+func IsDocumentValidationFailure(err error) (ValidationFailure, bool) {
+	for _, doc := range serverErrors(err) {
+		if doc.code != codeValidationFailure {
+			continue
+		}
+		failure := ValidationFailure{DocumentID: doc.details.Lookup("failingDocumentId")}
+		if details, ok := doc.details.Lookup("details").DocumentOK(); ok {
+			failure.Details = details
+			failure.Operator, _ = details.Lookup("operatorName").StringValueOK()
+			if rules, ok := details.Lookup("schemaRulesNotSatisfied").ArrayOK(); ok {
+				_ = bson.UnmarshalValue(bson.TypeArray, rules, &failure.Rules)
+			}
+		}
+		return failure, true
+	}
+	return ValidationFailure{}, false
+}
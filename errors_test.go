@@ -0,0 +1,246 @@
+package monarch
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+func mustMarshal(t *testing.T, v any) bson.Raw {
+	t.Helper()
+	raw, err := bson.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	return raw
+}
+
+func TestWrapError(t *testing.T) {
+	t.Run("nil and sentinel errors are unchanged", func(t *testing.T) {
+		for _, err := range []error{nil, mongo.ErrNoDocuments, ErrNoDatabase} {
+			if got := wrapError("users", "FindOne", err); got != err {
+				t.Errorf("expected %v unchanged, got %v", err, got)
+			}
+		}
+	})
+
+	t.Run("driver errors are wrapped", func(t *testing.T) {
+		cause := mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 11000, Message: "E11000 duplicate key error"}}}
+		err := wrapError("users", "InsertOne", cause)
+
+		var opErr *OpError
+		if !errors.As(err, &opErr) {
+			t.Fatalf("expected *OpError, got %T", err)
+		}
+		if opErr.Collection != "users" || opErr.Op != "InsertOne" {
+			t.Errorf("expected users/InsertOne, got %s/%s", opErr.Collection, opErr.Op)
+		}
+		if want := "monarch: users.InsertOne: write exception: write errors: [E11000 duplicate key error]"; err.Error() != want {
+			t.Errorf("expected message %q, got %q", want, err.Error())
+		}
+		if !errors.As(err, &mongo.WriteException{}) {
+			t.Errorf("expected wrapped error to match mongo.WriteException")
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			t.Errorf("expected wrapped error to be a duplicate key error")
+		}
+	})
+}
+
+func TestIsDuplicateKey(t *testing.T) {
+	message := `E11000 duplicate key error collection: db.users index: email_1 dup key: { email: "a@example.com" }`
+	raw := mustMarshal(t, bson.D{
+		{Key: "code", Value: 11000},
+		{Key: "keyPattern", Value: bson.D{{Key: "email", Value: 1}}},
+		{Key: "keyValue", Value: bson.D{{Key: "email", Value: "a@example.com"}}},
+	})
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{
+			name: "write exception",
+			err:  mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 11000, Message: message, Raw: raw}}},
+		},
+		{
+			name: "bulk write exception",
+			err: mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{
+				{WriteError: mongo.WriteError{Code: 11000, Message: message, Raw: raw}},
+			}},
+		},
+		{
+			name: "command error",
+			err:  mongo.CommandError{Code: 11000, Message: message, Raw: raw},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dup, ok := IsDuplicateKey(wrapError("users", "InsertOne", tt.err))
+			if !ok {
+				t.Fatal("expected duplicate key error")
+			}
+			if dup.Index != "email_1" {
+				t.Errorf("expected index email_1, got %q", dup.Index)
+			}
+			want := bson.D{{Key: "email", Value: "a@example.com"}}
+			if len(dup.Key) != 1 || dup.Key[0] != want[0] {
+				t.Errorf("expected key %v, got %v", want, dup.Key)
+			}
+		})
+	}
+
+	t.Run("other errors", func(t *testing.T) {
+		if _, ok := IsDuplicateKey(mongo.CommandError{Code: 112}); ok {
+			t.Error("expected write conflict not to be a duplicate key error")
+		}
+		if _, ok := IsDuplicateKey(errors.New("boom")); ok {
+			t.Error("expected plain error not to be a duplicate key error")
+		}
+	})
+}
+
+func TestErrorClassification(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		timeout       bool
+		network       bool
+		retryable     bool
+		writeConflict bool
+	}{
+		{
+			name:    "context deadline",
+			err:     context.DeadlineExceeded,
+			timeout: true,
+		},
+		{
+			name:      "network error",
+			err:       mongo.CommandError{Labels: []string{"NetworkError"}},
+			network:   true,
+			retryable: true,
+		},
+		{
+			name:      "retryable write label",
+			err:       mongo.WriteException{Labels: []string{"RetryableWriteError"}},
+			retryable: true,
+		},
+		{
+			name:      "not primary during election",
+			err:       mongo.CommandError{Code: 10107, Message: "not primary"},
+			retryable: true,
+		},
+		{
+			name:          "write conflict",
+			err:           mongo.CommandError{Code: 112, Message: "WriteConflict"},
+			writeConflict: true,
+		},
+		{
+			name: "duplicate key",
+			err:  mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 11000}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := wrapError("users", "UpdateOne", tt.err)
+			if got := IsTimeout(err); got != tt.timeout {
+				t.Errorf("IsTimeout: expected %v, got %v", tt.timeout, got)
+			}
+			if got := IsNetwork(err); got != tt.network {
+				t.Errorf("IsNetwork: expected %v, got %v", tt.network, got)
+			}
+			if got := IsRetryable(err); got != tt.retryable {
+				t.Errorf("IsRetryable: expected %v, got %v", tt.retryable, got)
+			}
+			if got := IsWriteConflict(err); got != tt.writeConflict {
+				t.Errorf("IsWriteConflict: expected %v, got %v", tt.writeConflict, got)
+			}
+		})
+	}
+}
+
+func TestIsDocumentValidationFailure(t *testing.T) {
+	errInfo := mustMarshal(t, bson.D{
+		{Key: "failingDocumentId", Value: "user1"},
+		{Key: "details", Value: bson.D{
+			{Key: "operatorName", Value: "$jsonSchema"},
+			{Key: "schemaRulesNotSatisfied", Value: bson.A{
+				bson.D{
+					{Key: "operatorName", Value: "required"},
+					{Key: "missingProperties", Value: bson.A{"email"}},
+				},
+				bson.D{
+					{Key: "operatorName", Value: "properties"},
+					{Key: "propertiesNotSatisfied", Value: bson.A{
+						bson.D{
+							{Key: "propertyName", Value: "age"},
+							{Key: "details", Value: bson.A{
+								bson.D{{Key: "operatorName", Value: "minimum"}, {Key: "reason", Value: "comparison failed"}},
+							}},
+						},
+					}},
+				},
+			}},
+		}},
+	})
+
+	err := wrapError("users", "InsertOne", mongo.WriteException{
+		WriteErrors: mongo.WriteErrors{{Code: 121, Message: "Document failed validation", Details: errInfo}},
+	})
+
+	failure, ok := IsDocumentValidationFailure(err)
+	if !ok {
+		t.Fatal("expected document validation failure")
+	}
+	if id, _ := failure.DocumentID.StringValueOK(); id != "user1" {
+		t.Errorf("expected failing document user1, got %v", failure.DocumentID)
+	}
+	if failure.Operator != "$jsonSchema" {
+		t.Errorf("expected operator $jsonSchema, got %q", failure.Operator)
+	}
+	if len(failure.Rules) != 2 {
+		t.Fatalf("expected 2 rules, got %d", len(failure.Rules))
+	}
+	if rule := failure.Rules[0]; rule.Operator != "required" || len(rule.MissingProperties) != 1 || rule.MissingProperties[0] != "email" {
+		t.Errorf("expected required rule missing email, got %+v", rule)
+	}
+	if props := failure.Rules[1].Properties; len(props) != 1 || props[0].Name != "age" || len(props[0].Details) != 1 {
+		t.Errorf("expected properties rule failing age, got %+v", props)
+	}
+
+	if _, ok := IsDocumentValidationFailure(mongo.CommandError{Code: 11000}); ok {
+		t.Error("expected duplicate key not to be a validation failure")
+	}
+}
+
+func TestDuplicateKeyFromServer(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := WithContext(context.Background(), db)
+
+	cleanupCollection(t, ctx, Users)
+
+	_, err := Users.InsertOne(ctx, User{ID: "dup1", Name: "Alice"})
+	if err != nil {
+		t.Fatalf("InsertOne failed: %v", err)
+	}
+
+	_, err = Users.InsertOne(ctx, User{ID: "dup1", Name: "Alice"})
+	dup, ok := IsDuplicateKey(err)
+	if !ok {
+		t.Fatalf("expected duplicate key error, got %v", err)
+	}
+	if dup.Index != "_id_" {
+		t.Errorf("expected index _id_, got %q", dup.Index)
+	}
+
+	var opErr *OpError
+	if !errors.As(err, &opErr) || opErr.Op != "InsertOne" || opErr.Collection != "users" {
+		t.Errorf("expected error wrapped with users.InsertOne, got %v", err)
+	}
+}
@@ -0,0 +1,336 @@
+package monarch
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// ExplainVerbosity selects how much information an explain returns.
+type ExplainVerbosity string
+
+const (
+	// ExplainQueryPlanner returns the plan selected by the query planner without
+	// running the query.
+	ExplainQueryPlanner ExplainVerbosity = "queryPlanner"
+	// ExplainExecutionStats runs the winning plan and returns its execution statistics.
+	ExplainExecutionStats ExplainVerbosity = "executionStats"
+	// ExplainAllPlansExecution runs the winning plan and the rejected plans and
+	// returns the execution statistics of all of them.
+	ExplainAllPlansExecution ExplainVerbosity = "allPlansExecution"
+)
+
+// ExplainResult is the parsed result of an explain command.
+type ExplainResult struct {
+	// Namespace is the namespace of the explained collection.
+	Namespace string
+	// WinningPlan is the root stage of the plan selected by the query planner.
+	WinningPlan PlanStage
+	// RejectedPlans are the root stages of the other plans considered by the query planner.
+	RejectedPlans []PlanStage
+	// IndexesUsed are the names of the indexes scanned by the winning plan.
+	IndexesUsed []string
+	// CollectionScan reports whether the winning plan scans the whole collection.
+	CollectionScan bool
+	// Pipeline are the names of the aggregation stages run after the query,
+	// such as $group, in order. It is empty for find commands and for pipelines
+	// entirely run by the query.
+	Pipeline []string
+
+	// Executed reports whether the execution statistics below are set, which requires
+	// ExplainExecutionStats or ExplainAllPlansExecution.
+	Executed bool
+	// DocsReturned is the number of documents returned by the query.
+	DocsReturned int64
+	// DocsExamined is the number of documents examined by the query.
+	DocsExamined int64
+	// KeysExamined is the number of index keys examined by the query.
+	KeysExamined int64
+	// ExecutionTime is the time taken to run the query.
+	ExecutionTime time.Duration
+
+	// Raw is the unparsed reply of the explain command.
+	Raw bson.Raw
+}
+
+// PlanStage is a stage of a query plan, such as COLLSCAN, IXSCAN or FETCH.
+type PlanStage struct {
+	// Stage is the name of the stage.
+	Stage string
+	// IndexName is the name of the index scanned by an IXSCAN stage.
+	IndexName string
+	// KeyPattern is the key pattern of the index scanned by an IXSCAN stage.
+	KeyPattern bson.Raw
+	// Filter is the filter applied by the stage, if any.
+	Filter bson.Raw
+	// Inputs are the stages providing the input of the stage.
+	Inputs []PlanStage
+}
+
+// Stages returns the stage and its input stages, depth first.
+func (s PlanStage) Stages() []PlanStage {
+	stages := []PlanStage{s}
+	for _, input := range s.Inputs {
+		stages = append(stages, input.Stages()...)
+	}
+	return stages
+}
+
+// ExplainFind explains the find command run by Find with the same filter and options,
+// including the tenant scope and encrypted values added to the filter.
+//
+// Verbosity ExplainQueryPlanner does not run the query; the other verbosities run it
+// to collect execution statistics, without returning its documents.
+//
+// See the explain command documentation for more details.
+func (c Collection[T]) ExplainFind(ctx context.Context, verbosity ExplainVerbosity, filter any, opts ...options.Lister[options.FindOptions]) (*ExplainResult, error) {
+	filter, err := c.prepareFilter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	args := &options.FindOptions{}
+	for _, opt := range opts {
+		for _, set := range opt.List() {
+			if err := set(args); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	cmd := bson.D{{Key: "find", Value: string(c)}, {Key: "filter", Value: filter}}
+	cmd = appendOption(cmd, "sort", args.Sort)
+	cmd = appendOption(cmd, "projection", args.Projection)
+	cmd = appendOption(cmd, "hint", args.Hint)
+	cmd = appendOption(cmd, "skip", args.Skip)
+	if args.Limit != nil {
+		// A negative limit returns a single batch of at most -limit documents.
+		cmd = append(cmd, bson.E{Key: "limit", Value: max(*args.Limit, -*args.Limit)})
+		if *args.Limit < 0 {
+			cmd = append(cmd, bson.E{Key: "singleBatch", Value: true})
+		}
+	}
+	cmd = appendOption(cmd, "collation", collationDocument(args.Collation))
+	cmd = appendOption(cmd, "min", args.Min)
+	cmd = appendOption(cmd, "max", args.Max)
+	cmd = appendOption(cmd, "let", args.Let)
+	cmd = appendOption(cmd, "comment", args.Comment)
+	return c.explain(ctx, "ExplainFind", verbosity, cmd)
+}
+
+// ExplainAggregate explains the aggregate command run by Aggregate with the same
+// pipeline and options, including the tenant scope added to the pipeline.
+//
+// Verbosity ExplainQueryPlanner does not run the pipeline; the other verbosities run
+// it to collect execution statistics, without returning its documents. Pipelines
+// writing with $out or $merge can only be explained with ExplainQueryPlanner.
+//
+// See the explain command documentation for more details.
+func (c Collection[T]) ExplainAggregate(ctx context.Context, verbosity ExplainVerbosity, pipeline any, opts ...options.Lister[options.AggregateOptions]) (*ExplainResult, error) {
+	pipeline, err := c.scopePipeline(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	if pipeline == nil {
+		pipeline = bson.A{}
+	}
+	args := &options.AggregateOptions{}
+	for _, opt := range opts {
+		for _, set := range opt.List() {
+			if err := set(args); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	cmd := bson.D{
+		{Key: "aggregate", Value: string(c)},
+		{Key: "pipeline", Value: pipeline},
+		{Key: "cursor", Value: bson.D{}},
+	}
+	cmd = appendOption(cmd, "allowDiskUse", args.AllowDiskUse)
+	cmd = appendOption(cmd, "collation", collationDocument(args.Collation))
+	cmd = appendOption(cmd, "hint", args.Hint)
+	cmd = appendOption(cmd, "let", args.Let)
+	cmd = appendOption(cmd, "comment", args.Comment)
+	for key, value := range args.Custom {
+		cmd = append(cmd, bson.E{Key: key, Value: value})
+	}
+	return c.explain(ctx, "ExplainAggregate", verbosity, cmd)
+}
+
+// explain runs the explain command of cmd and parses its reply.
+func (c Collection[T]) explain(ctx context.Context, op string, verbosity ExplainVerbosity, cmd bson.D) (*ExplainResult, error) {
+	if verbosity == "" {
+		verbosity = ExplainQueryPlanner
+	}
+	var reply bson.Raw
+	err := c.exec(ctx, op, opRead, func(collection *mongo.Collection) error {
+		var err error
+		reply, err = collection.Database().RunCommand(ctx, bson.D{
+			{Key: "explain", Value: cmd},
+			{Key: "verbosity", Value: string(verbosity)},
+		}).Raw()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseExplain(reply), nil
+}
+
+// parseExplain parses the reply of an explain command.
+//
+// The query plan of an aggregation is either at the top level of the reply, when the
+// whole pipeline runs in the query, or in the $cursor stage of its stages otherwise.
+func parseExplain(reply bson.Raw) *ExplainResult {
+	result := &ExplainResult{Raw: reply}
+	query := reply
+	if stages, ok := reply.Lookup("stages").ArrayOK(); ok {
+		values, _ := stages.Values()
+		for _, value := range values {
+			stage, ok := value.DocumentOK()
+			if !ok {
+				continue
+			}
+			elem, err := stage.IndexErr(0)
+			if err != nil {
+				continue
+			}
+			if elem.Key() == "$cursor" {
+				query, _ = elem.Value().DocumentOK()
+			} else {
+				result.Pipeline = append(result.Pipeline, elem.Key())
+			}
+		}
+	}
+
+	planner, _ := query.Lookup("queryPlanner").DocumentOK()
+	result.Namespace, _ = planner.Lookup("namespace").StringValueOK()
+	result.WinningPlan = parsePlan(planner.Lookup("winningPlan"))
+	if rejected, ok := planner.Lookup("rejectedPlans").ArrayOK(); ok {
+		values, _ := rejected.Values()
+		for _, value := range values {
+			result.RejectedPlans = append(result.RejectedPlans, parsePlan(value))
+		}
+	}
+	for _, stage := range result.WinningPlan.Stages() {
+		switch stage.Stage {
+		case "COLLSCAN":
+			result.CollectionScan = true
+		case "IXSCAN", "COUNT_SCAN", "DISTINCT_SCAN", "EXPRESS_IXSCAN":
+			if stage.IndexName != "" && !slices.Contains(result.IndexesUsed, stage.IndexName) {
+				result.IndexesUsed = append(result.IndexesUsed, stage.IndexName)
+			}
+		}
+	}
+
+	if stats, ok := query.Lookup("executionStats").DocumentOK(); ok {
+		result.Executed = true
+		result.DocsReturned = rawInt(stats.Lookup("nReturned"))
+		result.DocsExamined = rawInt(stats.Lookup("totalDocsExamined"))
+		result.KeysExamined = rawInt(stats.Lookup("totalKeysExamined"))
+		result.ExecutionTime = time.Duration(rawInt(stats.Lookup("executionTimeMillis"))) * time.Millisecond
+	}
+	return result
+}
+
+// parsePlan parses a plan stage and its inputs. Plans of the slot based execution
+// engine hold the stages in queryPlan, and plans of sharded clusters hold the plan
+// of each shard in shards.
+func parsePlan(value bson.RawValue) PlanStage {
+	doc, ok := value.DocumentOK()
+	if !ok {
+		return PlanStage{}
+	}
+	if plan, ok := doc.Lookup("queryPlan").DocumentOK(); ok {
+		doc = plan
+	}
+	var stage PlanStage
+	stage.Stage, _ = doc.Lookup("stage").StringValueOK()
+	stage.IndexName, _ = doc.Lookup("indexName").StringValueOK()
+	stage.KeyPattern, _ = doc.Lookup("keyPattern").DocumentOK()
+	stage.Filter, _ = doc.Lookup("filter").DocumentOK()
+	if input, err := doc.LookupErr("inputStage"); err == nil {
+		stage.Inputs = append(stage.Inputs, parsePlan(input))
+	}
+	if inputs, ok := doc.Lookup("inputStages").ArrayOK(); ok {
+		values, _ := inputs.Values()
+		for _, input := range values {
+			stage.Inputs = append(stage.Inputs, parsePlan(input))
+		}
+	}
+	if shards, ok := doc.Lookup("shards").ArrayOK(); ok {
+		values, _ := shards.Values()
+		for _, shard := range values {
+			if shard, ok := shard.DocumentOK(); ok {
+				stage.Inputs = append(stage.Inputs, parsePlan(shard.Lookup("winningPlan")))
+			}
+		}
+	}
+	return stage
+}
+
+// rawInt returns the value of a numeric BSON value as an int64, or 0.
+func rawInt(value bson.RawValue) int64 {
+	if n, ok := value.AsInt64OK(); ok {
+		return n
+	}
+	if f, ok := value.DoubleOK(); ok {
+		return int64(f)
+	}
+	return 0
+}
+
+// appendOption appends the option to cmd if it is set.
+func appendOption(cmd bson.D, key string, value any) bson.D {
+	switch v := value.(type) {
+	case nil:
+		return cmd
+	case *int64:
+		if v == nil {
+			return cmd
+		}
+	case *bool:
+		if v == nil {
+			return cmd
+		}
+	}
+	return append(cmd, bson.E{Key: key, Value: value})
+}
+
+// collationDocument returns the command document of a collation, or nil.
+func collationDocument(collation *options.Collation) any {
+	if collation == nil {
+		return nil
+	}
+	doc := bson.D{{Key: "locale", Value: collation.Locale}}
+	if collation.CaseLevel {
+		doc = append(doc, bson.E{Key: "caseLevel", Value: true})
+	}
+	if collation.CaseFirst != "" {
+		doc = append(doc, bson.E{Key: "caseFirst", Value: collation.CaseFirst})
+	}
+	if collation.Strength != 0 {
+		doc = append(doc, bson.E{Key: "strength", Value: int32(collation.Strength)})
+	}
+	if collation.NumericOrdering {
+		doc = append(doc, bson.E{Key: "numericOrdering", Value: true})
+	}
+	if collation.Alternate != "" {
+		doc = append(doc, bson.E{Key: "alternate", Value: collation.Alternate})
+	}
+	if collation.MaxVariable != "" {
+		doc = append(doc, bson.E{Key: "maxVariable", Value: collation.MaxVariable})
+	}
+	if collation.Normalization {
+		doc = append(doc, bson.E{Key: "normalization", Value: true})
+	}
+	if collation.Backwards {
+		doc = append(doc, bson.E{Key: "backwards", Value: true})
+	}
+	return doc
+}
@@ -0,0 +1,141 @@
+package monarch
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+func TestParseExplain(t *testing.T) {
+	t.Run("find with index", func(t *testing.T) {
+		reply := mustMarshal(t, bson.D{
+			{Key: "queryPlanner", Value: bson.D{
+				{Key: "namespace", Value: "db.users"},
+				{Key: "winningPlan", Value: bson.D{
+					{Key: "stage", Value: "FETCH"},
+					{Key: "inputStage", Value: bson.D{
+						{Key: "stage", Value: "IXSCAN"},
+						{Key: "indexName", Value: "name_1"},
+						{Key: "keyPattern", Value: bson.D{{Key: "name", Value: 1}}},
+					}},
+				}},
+				{Key: "rejectedPlans", Value: bson.A{bson.D{{Key: "stage", Value: "COLLSCAN"}}}},
+			}},
+			{Key: "executionStats", Value: bson.D{
+				{Key: "nReturned", Value: int32(2)},
+				{Key: "executionTimeMillis", Value: int32(3)},
+				{Key: "totalKeysExamined", Value: int32(2)},
+				{Key: "totalDocsExamined", Value: int64(2)},
+			}},
+			{Key: "ok", Value: 1.0},
+		})
+
+		result := parseExplain(reply)
+		if result.Namespace != "db.users" || result.WinningPlan.Stage != "FETCH" {
+			t.Errorf("unexpected result %+v", result)
+		}
+		if !slices.Equal(result.IndexesUsed, []string{"name_1"}) || result.CollectionScan {
+			t.Errorf("expected index scan of name_1, got %v (collection scan %v)", result.IndexesUsed, result.CollectionScan)
+		}
+		if len(result.RejectedPlans) != 1 || result.RejectedPlans[0].Stage != "COLLSCAN" {
+			t.Errorf("expected rejected collection scan, got %+v", result.RejectedPlans)
+		}
+		if !result.Executed || result.DocsReturned != 2 || result.DocsExamined != 2 || result.KeysExamined != 2 || result.ExecutionTime != 3*time.Millisecond {
+			t.Errorf("unexpected execution stats %+v", result)
+		}
+	})
+
+	t.Run("slot based plan", func(t *testing.T) {
+		reply := mustMarshal(t, bson.D{
+			{Key: "queryPlanner", Value: bson.D{
+				{Key: "winningPlan", Value: bson.D{
+					{Key: "queryPlan", Value: bson.D{{Key: "stage", Value: "COLLSCAN"}}},
+					{Key: "slotBasedPlan", Value: bson.D{{Key: "stages", Value: "..."}}},
+				}},
+			}},
+		})
+
+		result := parseExplain(reply)
+		if !result.CollectionScan || result.Executed {
+			t.Errorf("expected unexecuted collection scan, got %+v", result)
+		}
+	})
+
+	t.Run("aggregate with stages", func(t *testing.T) {
+		reply := mustMarshal(t, bson.D{
+			{Key: "stages", Value: bson.A{
+				bson.D{{Key: "$cursor", Value: bson.D{
+					{Key: "queryPlanner", Value: bson.D{
+						{Key: "winningPlan", Value: bson.D{{Key: "stage", Value: "COLLSCAN"}}},
+					}},
+				}}},
+				bson.D{{Key: "$group", Value: bson.D{}}},
+				bson.D{{Key: "$sort", Value: bson.D{}}},
+			}},
+		})
+
+		result := parseExplain(reply)
+		if !result.CollectionScan || !slices.Equal(result.Pipeline, []string{"$group", "$sort"}) {
+			t.Errorf("expected collection scan followed by $group and $sort, got %+v", result)
+		}
+	})
+}
+
+func TestExplain(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := WithContext(context.Background(), db)
+
+	cleanupCollection(t, ctx, Users)
+
+	if _, err := Users.InsertMany(ctx, []User{{ID: "1", Name: "Alice", Age: 30}, {ID: "2", Name: "Bob", Age: 25}}); err != nil {
+		t.Fatalf("InsertMany failed: %v", err)
+	}
+	if _, err := Users.CreateIndexes(ctx, []mongo.IndexModel{{
+		Keys:    bson.D{{Key: "name", Value: 1}},
+		Options: options.Index().SetName("explain_name"),
+	}}); err != nil {
+		t.Fatalf("CreateIndexes failed: %v", err)
+	}
+
+	t.Run("find uses index", func(t *testing.T) {
+		result, err := Users.ExplainFind(ctx, ExplainExecutionStats, bson.M{"name": "Alice"})
+		if err != nil {
+			t.Fatalf("ExplainFind failed: %v", err)
+		}
+		if !slices.Contains(result.IndexesUsed, "explain_name") || result.CollectionScan {
+			t.Errorf("expected index explain_name, got %v", result.IndexesUsed)
+		}
+		if !result.Executed || result.DocsReturned != 1 {
+			t.Errorf("expected 1 document returned, got %d", result.DocsReturned)
+		}
+	})
+
+	t.Run("find scans collection", func(t *testing.T) {
+		result, err := Users.ExplainFind(ctx, ExplainQueryPlanner, bson.M{"age": 30}, options.Find().SetLimit(1))
+		if err != nil {
+			t.Fatalf("ExplainFind failed: %v", err)
+		}
+		if !result.CollectionScan || result.Executed {
+			t.Errorf("expected unexecuted collection scan, got %+v", result)
+		}
+	})
+
+	t.Run("aggregate", func(t *testing.T) {
+		result, err := Users.ExplainAggregate(ctx, ExplainQueryPlanner, bson.A{
+			bson.M{"$match": bson.M{"name": "Alice"}},
+			bson.M{"$group": bson.M{"_id": "$age", "count": bson.M{"$sum": 1}}},
+		})
+		if err != nil {
+			t.Fatalf("ExplainAggregate failed: %v", err)
+		}
+		if !slices.Contains(result.IndexesUsed, "explain_name") {
+			t.Errorf("expected index explain_name, got %v", result.IndexesUsed)
+		}
+	})
+}
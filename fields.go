@@ -0,0 +1,145 @@
+package monarch
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// structField describes a struct field as it is encoded by the bson package.
+type structField struct {
+	// name is the document key the field is encoded as.
+	name string
+	// index is the reflect index sequence of the field, including inlined structs.
+	index []int
+	// field is the reflected struct field.
+	field reflect.StructField
+	// tag holds the options of the monarch struct tag.
+	tag map[string]string
+}
+
+var structFieldsCache sync.Map // map[reflect.Type][]structField
+
+// structFields returns the encoded fields of the struct type t.
+//
+// Field names follow the bson package rules: the name in the bson tag if present,
+// otherwise the lowercased Go field name. Unexported fields and fields tagged
+// `bson:"-"` are skipped, and fields of structs tagged `bson:",inline"` are
+// flattened into the parent.
+func structFields(t reflect.Type) []structField {
+	if cached, ok := structFieldsCache.Load(t); ok {
+		return cached.([]structField)
+	}
+
+	var fields []structField
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("bson"), ",")
+		if name == "-" {
+			continue
+		}
+		if hasOption(opts, "inline") && f.Type.Kind() == reflect.Struct {
+			for _, inner := range structFields(f.Type) {
+				inner.index = append([]int{i}, inner.index...)
+				fields = append(fields, inner)
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields = append(fields, structField{
+			name:  name,
+			index: []int{i},
+			field: f,
+			tag:   parseTag(f.Tag.Get("monarch")),
+		})
+	}
+
+	structFieldsCache.Store(t, fields)
+	return fields
+}
+
+// lookupField returns the encoded field of the struct type t with the given document key.
+func lookupField(t reflect.Type, name string) (structField, bool) {
+	for _, f := range structFields(t) {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return structField{}, false
+}
+
+// validate returns an error if the path of f is not a field of T holding values of
+// type V. Arrays hold values of their element type. Paths into values that are not
+// structs, such as maps, and V of interface type are not checked.
+func (f Field[T, V]) validate() error {
+	t, want := reflect.TypeFor[T](), reflect.TypeFor[V]()
+	for name := range strings.SplitSeq(string(f), ".") {
+		t = elemType(t)
+		if t.Kind() != reflect.Struct {
+			return nil
+		}
+		field, ok := lookupField(t, name)
+		if !ok {
+			return fmt.Errorf("monarch: field %q not found in %s", string(f), reflect.TypeFor[T]())
+		}
+		t = field.field.Type
+	}
+	if want.Kind() == reflect.Interface || t == want {
+		return nil
+	}
+	if got := elemType(t); got != want && got.Kind() != reflect.Interface {
+		return fmt.Errorf("monarch: field %q has type %s, not %s", string(f), t, want)
+	}
+	return nil
+}
+
+// elemType returns the type of the values held by t: the type t points to, or the
+// element type of slices and arrays other than byte slices.
+func elemType(t reflect.Type) reflect.Type {
+	t = indirectType(t)
+	if (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() != reflect.Uint8 {
+		t = indirectType(t.Elem())
+	}
+	return t
+}
+
+// hasOption reports whether the comma-separated options contain opt.
+func hasOption(opts string, opt string) bool {
+	for o := range strings.SplitSeq(opts, ",") {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTag parses the comma-separated options of a monarch struct tag.
+// Options may carry a value, e.g. `monarch:"ref=userId"`. Options without
+// a value map to the empty string.
+func parseTag(tag string) map[string]string {
+	if tag == "" {
+		return nil
+	}
+	opts := make(map[string]string)
+	for opt := range strings.SplitSeq(tag, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(opt), "=")
+		if key != "" {
+			opts[key] = value
+		}
+	}
+	return opts
+}
+
+// indirectType returns the type after removing any pointer indirection.
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
@@ -2,7 +2,10 @@ module github.com/eriicafes/monarch
 
 go 1.25.4
 
-require go.mongodb.org/mongo-driver/v2 v2.4.1
+require (
+	go.mongodb.org/mongo-driver/v2 v2.4.1
+	go.yaml.in/yaml/v3 v3.0.4
+)
 
 require (
 	github.com/golang/snappy v1.0.0 // indirect
@@ -0,0 +1,210 @@
+package monarch
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// HistoryConfig configures document versioning.
+type HistoryConfig struct {
+	// Collections lists the names of the versioned collections.
+	Collections []string
+	// Transactional, if true, runs each versioned write and its snapshot in a
+	// transaction, starting one if the context does not carry one. Transactions
+	// require a replica set or sharded cluster.
+	Transactional bool
+}
+
+// Version is a previous version of a document of type T.
+type Version[T any] struct {
+	// ID identifies the version.
+	ID bson.ObjectID `bson:"_id"`
+	// DocumentID is the _id of the versioned document.
+	DocumentID bson.RawValue `bson:"documentId"`
+	// Version is the version number, starting at 1 for the first version of the document.
+	Version int `bson:"version"`
+	// Document is the document as it was in this version.
+	Document T `bson:"document" monarch:"document"`
+	// ReplacedAt is the time the version was replaced by the next version.
+	ReplacedAt time.Time `bson:"replacedAt"`
+	// Op is the name of the monarch operation that replaced the version, e.g. "UpdateOne".
+	Op string `bson:"op"`
+	// Tenant is the tenant of the document of a tenant-scoped collection.
+	Tenant bson.RawValue `bson:"tenant,omitempty"`
+}
+
+// versionRecord is a Version stored with its document in raw form.
+type versionRecord struct {
+	ID         bson.ObjectID `bson:"_id"`
+	DocumentID bson.RawValue `bson:"documentId"`
+	Version    int           `bson:"version"`
+	Document   bson.Raw      `bson:"document,omitempty"`
+	ReplacedAt time.Time     `bson:"replacedAt"`
+	Op         string        `bson:"op"`
+	Tenant     bson.RawValue `bson:"tenant,omitempty"`
+}
+
+type historyKey struct{}
+
+// WithHistory returns a new context with document versioning configured.
+//
+// UpdateOne, ReplaceOne, DeleteOne, FindOneAndUpdate, FindOneAndReplace,
+// FindOneAndDelete, Upsert and UpsertWith on versioned collections then store the
+// previous version of the changed document in the "<name>_history" collection,
+// using the same context, so that the snapshot is part of the transaction of the
+// write if any. Writes that leave a document unchanged are not versioned. Inserts
+// by InsertOne and upserts record the creation time of the document for AsOf.
+//
+// UpdateMany, DeleteMany, UpsertMany and InsertMany are not versioned:
+// their changes are not stored and AsOf returns the current document for them.
+//
+// The previous version is read separately from the write. Outside of a transaction,
+// concurrent writes to the same document may be interleaved with the read; enable
+// Transactional for exact snapshots.
+func WithHistory(ctx context.Context, config HistoryConfig) context.Context {
+	return context.WithValue(ctx, historyKey{}, config)
+}
+
+// historyFor returns the history configuration in ctx, or false if the collection is not versioned.
+func historyFor(ctx context.Context, collection string) (HistoryConfig, bool) {
+	config, ok := ctx.Value(historyKey{}).(HistoryConfig)
+	return config, ok && slices.Contains(config.Collections, collection)
+}
+
+// historyIndexes are the indexes of history collections. Version numbers are unique
+// per document, so concurrent snapshots of the same version fail instead of diverging.
+// Creation records have no document and share the number of the preceding version.
+var historyIndexes = []mongo.IndexModel{
+	{
+		Keys:    bson.D{{Key: "documentId", Value: 1}, {Key: "version", Value: 1}},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(hasVersionDocument),
+	},
+	{Keys: bson.D{{Key: "documentId", Value: 1}, {Key: "replacedAt", Value: 1}}},
+}
+
+// hasVersionDocument matches the history records storing a version of a document,
+// as opposed to creation records.
+var hasVersionDocument = bson.D{{Key: "document", Value: bson.D{{Key: "$exists", Value: true}}}}
+
+// history returns the history collection of c.
+func (c Collection[T]) history() Collection[versionRecord] {
+	return Collection[versionRecord](string(c) + "_history")
+}
+
+// snapshot stores before as the next version of the document with the given _id.
+// A nil before records the creation of the document, which has no previous version.
+// The record holds the tenant of the document, if any.
+func (c Collection[T]) snapshot(ctx context.Context, op string, id, tenant bson.RawValue, before bson.Raw) error {
+	history := c.history()
+	version := 1
+	latest, err := history.FindOne(ctx,
+		append(bson.D{{Key: "documentId", Value: id}}, hasVersionDocument...),
+		options.FindOne().SetSort(bson.D{{Key: "version", Value: -1}}).SetProjection(bson.D{{Key: "version", Value: 1}}),
+	)
+	switch {
+	case err == nil:
+		version = latest.Version + 1
+	case err != mongo.ErrNoDocuments:
+		return err
+	}
+	if before == nil {
+		version--
+	}
+
+	_, err = history.InsertOne(ctx, versionRecord{
+		ID:         bson.NewObjectID(),
+		DocumentID: id,
+		Version:    version,
+		Document:   before,
+		ReplacedAt: time.Now(),
+		Op:         op,
+		Tenant:     tenant,
+	})
+	return err
+}
+
+// History returns the previous versions of the document with the given _id, oldest first.
+//
+// The current document is not included. Versions are recorded for writes made with
+// a context configured with WithHistory.
+func (c Collection[T]) History(ctx context.Context, id any) ([]Version[T], error) {
+	if err := c.history().EnsureIndexes(ctx, historyIndexes); err != nil {
+		return nil, err
+	}
+	filter, err := c.scopeRecords(ctx, append(bson.D{{Key: "documentId", Value: id}}, hasVersionDocument...))
+	if err != nil {
+		return nil, err
+	}
+	return FindAs[Version[T]](ctx, c.history(), filter, options.Find().SetSort(bson.D{{Key: "version", Value: 1}}))
+}
+
+// AsOf returns the document with the given _id as it was at time t.
+//
+// If the document has changed since t, the version that was current at t is returned,
+// otherwise the current document is returned. Returns mongo.ErrNoDocuments if the
+// document did not exist at t, as far as recorded: it was deleted before t, or its
+// creation was recorded after t, or it has no recorded creation and an ObjectID _id
+// generated after t.
+func (c Collection[T]) AsOf(ctx context.Context, id any, t time.Time) (T, error) {
+	var zero T
+	if err := c.history().EnsureIndexes(ctx, historyIndexes); err != nil {
+		return zero, err
+	}
+	filter, err := c.scopeRecords(ctx, bson.D{
+		{Key: "documentId", Value: id},
+		{Key: "replacedAt", Value: bson.D{{Key: "$gt", Value: t}}},
+	})
+	if err != nil {
+		return zero, err
+	}
+	next, err := c.history().FindOne(ctx, filter,
+		options.FindOne().SetSort(bson.D{{Key: "replacedAt", Value: 1}, {Key: "_id", Value: 1}}),
+	)
+	switch {
+	case err == nil && next.Document == nil:
+		return zero, mongo.ErrNoDocuments
+	case err == nil:
+		var doc T
+		err = decodeRaw(ctx, next.Document, &doc)
+		return doc, err
+	case err != mongo.ErrNoDocuments:
+		return zero, err
+	}
+
+	current, err := c.FindOne(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		return zero, err
+	}
+	if oid, ok := id.(bson.ObjectID); ok && oid.Timestamp().After(t) {
+		return zero, mongo.ErrNoDocuments
+	}
+	return current, nil
+}
+
+// Revert replaces the document with the given _id with the given previous version,
+// restoring it if it was deleted, and returns the restored document.
+//
+// The revert is itself a write, so the replaced document is stored as a new version
+// when the context is configured with WithHistory. Returns mongo.ErrNoDocuments if
+// the version does not exist.
+func (c Collection[T]) Revert(ctx context.Context, id any, version int) (T, error) {
+	var v Version[T]
+	if err := c.history().EnsureIndexes(ctx, historyIndexes); err != nil {
+		return v.Document, err
+	}
+	filter, err := c.scopeRecords(ctx, append(bson.D{{Key: "documentId", Value: id}, {Key: "version", Value: version}}, hasVersionDocument...))
+	if err != nil {
+		return v.Document, err
+	}
+	v, err = FindOneAs[Version[T]](ctx, c.history(), filter)
+	if err != nil {
+		return v.Document, err
+	}
+	_, err = c.ReplaceOne(ctx, bson.D{{Key: "_id", Value: id}}, v.Document, options.Replace().SetUpsert(true))
+	return v.Document, err
+}
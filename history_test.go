@@ -0,0 +1,140 @@
+package monarch
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+func TestHistoryFor(t *testing.T) {
+	ctx := WithHistory(context.Background(), HistoryConfig{Collections: []string{"users"}})
+
+	if _, ok := historyFor(ctx, "users"); !ok {
+		t.Errorf("expected users to be versioned")
+	}
+	if _, ok := historyFor(ctx, "orders"); ok {
+		t.Errorf("expected orders not to be versioned")
+	}
+	if got := Users.history(); got != "users_history" {
+		t.Errorf("expected users_history, got %q", got)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := WithContext(context.Background(), db)
+	ctx = WithHistory(ctx, HistoryConfig{Collections: []string{string(Users)}})
+
+	cleanupCollection(t, ctx, Users)
+	cleanupCollection(t, ctx, Users.history())
+
+	beforeInsert := time.Now()
+	time.Sleep(5 * time.Millisecond)
+	if _, err := Users.InsertOne(ctx, User{ID: "hist1", Name: "v1", Age: 1}); err != nil {
+		t.Fatalf("InsertOne failed: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	afterV1 := time.Now()
+	time.Sleep(5 * time.Millisecond)
+
+	if _, err := Users.UpdateOne(ctx, bson.M{"_id": "hist1"}, bson.M{"$set": bson.M{"name": "v2"}}); err != nil {
+		t.Fatalf("UpdateOne failed: %v", err)
+	}
+	// Unchanged documents are not versioned.
+	if _, err := Users.UpdateOne(ctx, bson.M{"_id": "hist1"}, bson.M{"$set": bson.M{"name": "v2"}}); err != nil {
+		t.Fatalf("UpdateOne failed: %v", err)
+	}
+	if _, err := Users.ReplaceOne(ctx, bson.M{"_id": "hist1"}, User{ID: "hist1", Name: "v3", Age: 3}); err != nil {
+		t.Fatalf("ReplaceOne failed: %v", err)
+	}
+
+	history, err := Users.History(ctx, "hist1")
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("expected 2 versions, got %d", len(history))
+	}
+	if history[0].Version != 1 || history[0].Document.Name != "v1" || history[0].Op != "UpdateOne" {
+		t.Errorf("unexpected first version: %+v", history[0])
+	}
+	if history[1].Version != 2 || history[1].Document.Name != "v2" || history[1].Op != "ReplaceOne" {
+		t.Errorf("unexpected second version: %+v", history[1])
+	}
+
+	if _, err := Users.AsOf(ctx, "hist1", beforeInsert); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Errorf("expected ErrNoDocuments before insert, got %v", err)
+	}
+	user, err := Users.AsOf(ctx, "hist1", afterV1)
+	if err != nil || user.Name != "v1" {
+		t.Errorf("expected v1 as of %v, got %+v, %v", afterV1, user, err)
+	}
+	user, err = Users.AsOf(ctx, "hist1", time.Now())
+	if err != nil || user.Name != "v3" {
+		t.Errorf("expected current v3, got %+v, %v", user, err)
+	}
+
+	if _, err := Users.DeleteOne(ctx, bson.M{"_id": "hist1"}); err != nil {
+		t.Fatalf("DeleteOne failed: %v", err)
+	}
+	if _, err := Users.AsOf(ctx, "hist1", time.Now()); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Errorf("expected ErrNoDocuments after delete, got %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	afterDelete := time.Now()
+	time.Sleep(5 * time.Millisecond)
+
+	restored, err := Users.Revert(ctx, "hist1", 1)
+	if err != nil || restored.Name != "v1" {
+		t.Fatalf("expected v1 restored, got %+v, %v", restored, err)
+	}
+	current, err := FindByID(ctx, Users, "hist1")
+	if err != nil || current.Name != "v1" || current.Age != 1 {
+		t.Errorf("expected restored v1, got %+v, %v", current, err)
+	}
+	if _, err := Users.AsOf(ctx, "hist1", afterDelete); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Errorf("expected ErrNoDocuments between delete and revert, got %v", err)
+	}
+	if _, err := Users.Revert(ctx, "hist1", 99); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Errorf("expected ErrNoDocuments for a missing version, got %v", err)
+	}
+}
+
+func TestHistoryTransactionalIndexes(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	requireReplicaSet(t, db)
+	ctx := WithContext(context.Background(), db)
+	ctx = WithHistory(ctx, HistoryConfig{Collections: []string{string(Users)}, Transactional: true})
+
+	cleanupCollection(t, ctx, Users)
+	if err := db.Collection(string(Users.history())).Drop(ctx); err != nil {
+		t.Fatalf("Drop failed: %v", err)
+	}
+
+	if _, err := Users.InsertOne(ctx, User{ID: "histtx1", Name: "v1"}); err != nil {
+		t.Fatalf("InsertOne failed: %v", err)
+	}
+	if _, err := Users.UpdateOne(ctx, bson.M{"_id": "histtx1"}, bson.M{"$set": bson.M{"name": "v2"}}); err != nil {
+		t.Fatalf("UpdateOne failed: %v", err)
+	}
+
+	specs, err := db.Collection(string(Users.history())).Indexes().ListSpecifications(ctx)
+	if err != nil {
+		t.Fatalf("ListSpecifications failed: %v", err)
+	}
+	var unique bool
+	for _, spec := range specs {
+		if spec.Name == "documentId_1_version_1" && spec.Unique != nil && *spec.Unique {
+			unique = true
+		}
+	}
+	if !unique {
+		t.Errorf("expected a unique {documentId, version} index on the transactional history collection, got %+v", specs)
+	}
+}
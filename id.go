@@ -0,0 +1,152 @@
+package monarch
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Document is implemented by document types that expose their _id value.
+//
+// The type parameter ID specifies the type of the _id field. Implementing Document
+// enables the ID-aware helpers such as FindByID and DeleteByID, which infer the ID
+// type from the collection so that passing an ID of the wrong type fails to compile.
+// The ID type is taken from the DocumentID method rather than from the _id struct
+// field, since Go type inference cannot see struct tags, so every document type used
+// with these helpers must implement it, returning the value of its _id field:
+//
+//	type User struct {
+//	    ID   bson.ObjectID `bson:"_id"`
+//	    Name string        `bson:"name"`
+//	}
+//
+//	func (u User) DocumentID() bson.ObjectID { return u.ID }
+type Document[ID comparable] interface {
+	DocumentID() ID
+}
+
+// FindByID executes a find command and returns the document with the given _id.
+//
+// Returns mongo.ErrNoDocuments if no document has the given _id.
+//
+// See [mongo.Collection.FindOne] for more details.
+func FindByID[T Document[ID], ID comparable](ctx context.Context, c Collection[T], id ID, opts ...options.Lister[options.FindOneOptions]) (T, error) {
+	return c.FindOne(ctx, bson.D{{Key: "_id", Value: id}}, opts...)
+}
+
+// FindByIDs executes a find command and returns the documents with the given _id values.
+//
+// Documents are returned in the order of ids, and an ID that appears more than once
+// yields its document more than once. IDs that do not match any document are returned
+// in missing, also in input order. Missing IDs are not treated as an error.
+//
+// Documents are matched to ids by their DocumentID, so a projection that excludes
+// _id has that exclusion dropped.
+//
+// See [mongo.Collection.Find] for more details.
+func FindByIDs[T Document[ID], ID comparable](ctx context.Context, c Collection[T], ids []ID, opts ...options.Lister[options.FindOptions]) (found []T, missing []ID, err error) {
+	if len(ids) == 0 {
+		return nil, nil, nil
+	}
+	projection, changed, err := keepID(listedOption(opts, func(o *options.FindOptions) any { return o.Projection }))
+	if err != nil {
+		return nil, nil, err
+	}
+	if changed {
+		opts = append(slices.Clone(opts), options.Find().SetProjection(projection))
+	}
+	results, err := c.Find(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}}, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	byID := make(map[ID]T, len(results))
+	for _, result := range results {
+		byID[result.DocumentID()] = result
+	}
+	found = make([]T, 0, len(ids))
+	for _, id := range ids {
+		if result, ok := byID[id]; ok {
+			found = append(found, result)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	return found, missing, nil
+}
+
+// keepID returns projection without its _id entry, so that _id is returned with
+// the other fields, and reports whether projection had one.
+func keepID(projection any) (bson.D, bool, error) {
+	if projection == nil {
+		return nil, false, nil
+	}
+	raw, err := bson.Marshal(projection)
+	if err != nil {
+		return nil, false, err
+	}
+	var doc bson.D
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, false, err
+	}
+	i := slices.IndexFunc(doc, func(e bson.E) bool { return e.Key == "_id" })
+	if i < 0 {
+		return nil, false, nil
+	}
+	return slices.Delete(doc, i, i+1), true, nil
+}
+
+// UpdateByID executes an update command to update the document with the given _id.
+//
+// The update parameter must contain update operators (e.g., $set, $inc, $push).
+//
+// See [mongo.Collection.UpdateOne] for more details.
+func UpdateByID[T Document[ID], ID comparable](ctx context.Context, c Collection[T], id ID, update any, opts ...options.Lister[options.UpdateOneOptions]) (*mongo.UpdateResult, error) {
+	return c.UpdateOne(ctx, bson.D{{Key: "_id", Value: id}}, update, opts...)
+}
+
+// ReplaceByID executes an update command to replace the document with the given _id.
+//
+// The replacement document must not contain update operators.
+//
+// See [mongo.Collection.ReplaceOne] for more details.
+func ReplaceByID[T Document[ID], ID comparable](ctx context.Context, c Collection[T], id ID, replacement T, opts ...options.Lister[options.ReplaceOptions]) (*mongo.UpdateResult, error) {
+	return c.ReplaceOne(ctx, bson.D{{Key: "_id", Value: id}}, replacement, opts...)
+}
+
+// DeleteByID executes a delete command to delete the document with the given _id.
+//
+// Returns DeleteResult with DeletedCount field indicating whether the document was deleted.
+//
+// See [mongo.Collection.DeleteOne] for more details.
+func DeleteByID[T Document[ID], ID comparable](ctx context.Context, c Collection[T], id ID, opts ...options.Lister[options.DeleteOneOptions]) (*mongo.DeleteResult, error) {
+	return c.DeleteOne(ctx, bson.D{{Key: "_id", Value: id}}, opts...)
+}
+
+// Exists reports whether at least one document matches the filter.
+//
+// Only the _id of a single document is fetched, so this is cheaper than
+// CountDocuments when the exact number of matches is not needed.
+//
+// See [mongo.Collection.FindOne] for more details.
+func (c Collection[T]) Exists(ctx context.Context, filter any) (bool, error) {
+	filter, err := c.guardFilter(ctx, "Exists", filter, true)
+	if err != nil {
+		return false, err
+	}
+	opts := options.FindOne().SetProjection(bson.D{{Key: "_id", Value: 1}})
+	err = c.exec(ctx, "Exists", opRead, func(collection *mongo.Collection) error {
+		return collection.FindOne(ctx, filter, opts).Err()
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,180 @@
+package monarch
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+func TestFindByID(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := WithContext(context.Background(), db)
+
+	cleanupCollection(t, ctx, Users)
+
+	_, err := Users.InsertOne(ctx, User{ID: "id1", Name: "Alice", Age: 20})
+	if err != nil {
+		t.Fatalf("InsertOne failed: %v", err)
+	}
+
+	user, err := FindByID(ctx, Users, "id1")
+	if err != nil {
+		t.Fatalf("FindByID failed: %v", err)
+	}
+	if user.Name != "Alice" {
+		t.Errorf("expected Alice, got %s", user.Name)
+	}
+
+	_, err = FindByID(ctx, Users, "missing")
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Errorf("expected ErrNoDocuments, got %v", err)
+	}
+}
+
+func TestFindByIDs(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := WithContext(context.Background(), db)
+
+	cleanupCollection(t, ctx, Users)
+
+	users := []User{
+		{ID: "ids1", Name: "A"},
+		{ID: "ids2", Name: "B"},
+		{ID: "ids3", Name: "C"},
+	}
+	_, err := Users.InsertMany(ctx, users)
+	if err != nil {
+		t.Fatalf("InsertMany failed: %v", err)
+	}
+
+	found, missing, err := FindByIDs(ctx, Users, []string{"ids3", "nope1", "ids1", "nope2"})
+	if err != nil {
+		t.Fatalf("FindByIDs failed: %v", err)
+	}
+	names := make([]string, len(found))
+	for i, u := range found {
+		names[i] = u.Name
+	}
+	if !slices.Equal(names, []string{"C", "A"}) {
+		t.Errorf("expected [C A] in input order, got %v", names)
+	}
+	if !slices.Equal(missing, []string{"nope1", "nope2"}) {
+		t.Errorf("expected missing [nope1 nope2], got %v", missing)
+	}
+
+	found, missing, err = FindByIDs(ctx, Users, []string{"ids2"}, options.Find().SetProjection(bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 0}}))
+	if err != nil {
+		t.Fatalf("FindByIDs with projection failed: %v", err)
+	}
+	if len(found) != 1 || found[0].Name != "B" || len(missing) != 0 {
+		t.Errorf("expected [B] with nothing missing when the projection excludes _id, got %v %v", found, missing)
+	}
+
+	found, missing, err = FindByIDs(ctx, Users, []string{})
+	if err != nil || found != nil || missing != nil {
+		t.Errorf("expected empty results for no IDs, got %v %v %v", found, missing, err)
+	}
+}
+
+func TestUpdateReplaceDeleteByID(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := WithContext(context.Background(), db)
+
+	cleanupCollection(t, ctx, Users)
+
+	_, err := Users.InsertOne(ctx, User{ID: "urd1", Name: "Original", Age: 20})
+	if err != nil {
+		t.Fatalf("InsertOne failed: %v", err)
+	}
+
+	updateRes, err := UpdateByID(ctx, Users, "urd1", bson.M{"$set": bson.M{"age": 21}})
+	if err != nil {
+		t.Fatalf("UpdateByID failed: %v", err)
+	}
+	if updateRes.ModifiedCount != 1 {
+		t.Errorf("UpdateByID: expected 1 modified, got %d", updateRes.ModifiedCount)
+	}
+
+	replaceRes, err := ReplaceByID(ctx, Users, "urd1", User{ID: "urd1", Name: "Replaced", Age: 99})
+	if err != nil {
+		t.Fatalf("ReplaceByID failed: %v", err)
+	}
+	if replaceRes.ModifiedCount != 1 {
+		t.Errorf("ReplaceByID: expected 1 modified, got %d", replaceRes.ModifiedCount)
+	}
+
+	user, err := FindByID(ctx, Users, "urd1")
+	if err != nil {
+		t.Fatalf("FindByID failed: %v", err)
+	}
+	if user.Name != "Replaced" || user.Age != 99 {
+		t.Errorf("expected Replaced/99, got %s/%d", user.Name, user.Age)
+	}
+
+	deleteRes, err := DeleteByID(ctx, Users, "urd1")
+	if err != nil {
+		t.Fatalf("DeleteByID failed: %v", err)
+	}
+	if deleteRes.DeletedCount != 1 {
+		t.Errorf("DeleteByID: expected 1 deleted, got %d", deleteRes.DeletedCount)
+	}
+}
+
+func TestExists(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := WithContext(context.Background(), db)
+
+	cleanupCollection(t, ctx, Users)
+
+	_, err := Users.InsertOne(ctx, User{ID: "ex1", Name: "Alice", Age: 20})
+	if err != nil {
+		t.Fatalf("InsertOne failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		filter   bson.M
+		expected bool
+	}{
+		{name: "matching filter", filter: bson.M{"name": "Alice"}, expected: true},
+		{name: "non-matching filter", filter: bson.M{"name": "Bob"}, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exists, err := Users.Exists(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("Exists failed: %v", err)
+			}
+			if exists != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, exists)
+			}
+		})
+	}
+}
+
+func TestKeepID(t *testing.T) {
+	projection, changed, err := keepID(bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 0}})
+	if err != nil || !changed {
+		t.Fatalf("expected the _id exclusion to be dropped, got %v %v", changed, err)
+	}
+	if len(projection) != 1 || projection[0].Key != "name" {
+		t.Errorf("expected {name: 1}, got %v", projection)
+	}
+
+	if _, changed, err := keepID(bson.M{"name": 0}); err != nil || changed {
+		t.Errorf("expected a projection without _id to be kept, got %v %v", changed, err)
+	}
+	if _, changed, err := keepID(nil); err != nil || changed {
+		t.Errorf("expected no projection to be kept, got %v %v", changed, err)
+	}
+}
@@ -0,0 +1,65 @@
+// Package bsonequal compares BSON values ignoring the order of document keys.
+package bsonequal
+
+import "go.mongodb.org/mongo-driver/v2/bson"
+
+// Equal reports whether a and b are equal, ignoring the order of document keys
+// at any depth. Array elements are compared in order.
+func Equal(a, b bson.RawValue) bool {
+	switch {
+	case a.Type == bson.TypeEmbeddedDocument && b.Type == bson.TypeEmbeddedDocument:
+		return Documents(a.Document(), b.Document())
+	case a.Type == bson.TypeArray && b.Type == bson.TypeArray:
+		aValues, err1 := a.Array().Values()
+		bValues, err2 := b.Array().Values()
+		if err1 != nil || err2 != nil || len(aValues) != len(bValues) {
+			return false
+		}
+		for i := range aValues {
+			if !Equal(aValues[i], bValues[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a.Equal(b)
+}
+
+// Documents reports whether the documents a and b are equal, ignoring the order of keys.
+func Documents(a, b bson.Raw) bool {
+	aElems, err1 := a.Elements()
+	bElems, err2 := b.Elements()
+	if err1 != nil || err2 != nil || len(aElems) != len(bElems) {
+		return false
+	}
+	for _, elem := range aElems {
+		other, err := b.LookupErr(elem.Key())
+		if err != nil || !Equal(elem.Value(), other) {
+			return false
+		}
+	}
+	return true
+}
+
+// Values reports whether the BSON encodings of a and b are equal, ignoring the order
+// of document keys. Values that cannot be encoded are not equal.
+func Values(a, b any) bool {
+	aValue, err := encode(a)
+	if err != nil {
+		return false
+	}
+	bValue, err := encode(b)
+	if err != nil {
+		return false
+	}
+	return Equal(aValue, bValue)
+}
+
+// encode returns the BSON encoding of v.
+func encode(v any) (bson.RawValue, error) {
+	raw, err := bson.Marshal(bson.D{{Key: "v", Value: v}})
+	if err != nil {
+		return bson.RawValue{}, err
+	}
+	return bson.Raw(raw).LookupErr("v")
+}
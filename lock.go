@@ -0,0 +1,273 @@
+package monarch
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// ErrLocked is returned by Lock when the lock is held by another owner.
+var ErrLocked = errors.New("monarch: lock is held")
+
+// ErrLockLost is returned when a lock is no longer held, because it expired and may have
+// been acquired by another owner.
+var ErrLockLost = errors.New("monarch: lock lost")
+
+// lockDocument is a lock stored in the locks collection.
+type lockDocument struct {
+	Name      string        `bson:"_id"`
+	Owner     bson.ObjectID `bson:"owner"`
+	Token     int64         `bson:"token"`
+	ExpiresAt time.Time     `bson:"expiresAt"`
+}
+
+// locks is the collection storing locks. Lock names are unique through the _id index,
+// and expired locks are removed by a TTL index on expiresAt.
+const locks Collection[lockDocument] = "monarch_locks"
+
+// LockHandle is a held lock returned by Lock.
+type LockHandle struct {
+	name  string
+	owner bson.ObjectID
+	token int64
+	ttl   time.Duration
+}
+
+// Lock acquires the named lock for the given time to live, or returns ErrLocked
+// if it is held by another owner.
+//
+// The lock expires unless it is refreshed before the TTL elapses, so a crashed owner
+// does not hold it forever. Expiry uses the server clock, so client clock skew does
+// not affect it.
+//
+// Each acquisition of a lock is assigned a fencing token greater than the tokens of
+// previous acquisitions. Pass the token to the resources protected by the lock and
+// reject writes carrying a token lower than one already seen, so that an owner whose
+// lock expired without noticing cannot overwrite the work of the next owner.
+func Lock(ctx context.Context, name string, ttl time.Duration) (*LockHandle, error) {
+	if err := locks.EnsureIndexes(ctx, lockIndexes); err != nil {
+		return nil, err
+	}
+
+	owner := bson.NewObjectID()
+	now := bson.D{{Key: "$toLong", Value: "$$NOW"}}
+	doc, err := locks.FindOneAndUpdate(ctx,
+		bson.D{
+			{Key: "_id", Value: name},
+			{Key: "$expr", Value: bson.D{{Key: "$lte", Value: bson.A{"$expiresAt", "$$NOW"}}}},
+		},
+		bson.A{bson.D{{Key: "$set", Value: bson.D{
+			{Key: "owner", Value: owner},
+			// The token grows by one per acquisition, and restarts from the server time in
+			// milliseconds if the lock document was removed by the TTL index.
+			{Key: "token", Value: bson.D{{Key: "$max", Value: bson.A{
+				bson.D{{Key: "$add", Value: bson.A{bson.D{{Key: "$ifNull", Value: bson.A{"$token", 0}}}, 1}}},
+				now,
+			}}}},
+			{Key: "expiresAt", Value: expiresIn(ttl)},
+		}}}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrLocked
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &LockHandle{name: name, owner: owner, token: doc.Token, ttl: ttl}, nil
+}
+
+// Name returns the name of the lock.
+func (l *LockHandle) Name() string {
+	return l.name
+}
+
+// Token returns the fencing token of this acquisition of the lock.
+func (l *LockHandle) Token() int64 {
+	return l.token
+}
+
+// Refresh extends the lock so that it expires after its TTL from now.
+// Returns ErrLockLost if the lock has expired.
+func (l *LockHandle) Refresh(ctx context.Context) error {
+	result, err := locks.UpdateOne(ctx,
+		bson.D{
+			{Key: "_id", Value: l.name},
+			{Key: "owner", Value: l.owner},
+			{Key: "$expr", Value: bson.D{{Key: "$gt", Value: bson.A{"$expiresAt", "$$NOW"}}}},
+		},
+		bson.A{bson.D{{Key: "$set", Value: bson.D{{Key: "expiresAt", Value: expiresIn(l.ttl)}}}}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// Unlock releases the lock so that it can be acquired immediately.
+// Returns ErrLockLost if the lock was acquired by another owner after it expired.
+func (l *LockHandle) Unlock(ctx context.Context) error {
+	// The lock document is kept rather than deleted so the next acquisition
+	// continues from its fencing token.
+	result, err := locks.UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: l.name}, {Key: "owner", Value: l.owner}},
+		bson.A{bson.D{{Key: "$set", Value: bson.D{{Key: "expiresAt", Value: "$$NOW"}}}}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// expiresIn returns an aggregation expression for the server time after ttl.
+func expiresIn(ttl time.Duration) bson.D {
+	return bson.D{{Key: "$add", Value: bson.A{"$$NOW", ttl.Milliseconds()}}}
+}
+
+// lockIndexes are the indexes of the locks collection.
+var lockIndexes = []mongo.IndexModel{
+	{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+}
+
+// Default values for unset LeaderElector fields.
+const defaultLeaderTTL = 15 * time.Second
+
+// Leadership describes a change of leadership reported by a LeaderElector.
+type Leadership struct {
+	// Leader reports whether this instance is the leader.
+	Leader bool
+	// Token is the fencing token of the leadership term. It is zero when Leader is false.
+	Token int64
+}
+
+// LeaderElector elects a single leader among instances campaigning for the same lock.
+//
+// Run campaigns continuously: an instance that is not the leader tries to acquire
+// the lock, and the leader refreshes it. Leadership changes are reported on the
+// channel returned by Changes:
+//
+//	elector := &monarch.LeaderElector{Name: "cron"}
+//	go elector.Run(ctx)
+//
+//	for change := range elector.Changes() {
+//	    if change.Leader {
+//	        // start leader-only work
+//	    } else {
+//	        // stop leader-only work
+//	    }
+//	}
+type LeaderElector struct {
+	// Name is the name of the lock campaigned for.
+	Name string
+	// TTL is how long leadership lasts without being refreshed. Defaults to 15s.
+	TTL time.Duration
+	// Interval is the time between campaigns and refreshes. Defaults to a third of the TTL.
+	Interval time.Duration
+	// OnError, if set, is called with errors encountered while campaigning.
+	OnError func(error)
+
+	once    sync.Once
+	changes chan Leadership
+}
+
+// Changes returns the channel on which leadership changes are reported.
+//
+// The channel holds only the latest change, so a slow receiver skips intermediate
+// changes but always observes the current leadership. It is closed when Run returns.
+func (e *LeaderElector) Changes() <-chan Leadership {
+	e.once.Do(func() { e.changes = make(chan Leadership, 1) })
+	return e.changes
+}
+
+// Run campaigns for leadership until the context is done, and then releases
+// leadership if held and returns the context error.
+//
+// The leader steps down when a refresh reports that the lock was lost, or when
+// refreshes fail until the TTL elapses. Run must be called at most once.
+func (e *LeaderElector) Run(ctx context.Context) error {
+	e.Changes()
+	defer close(e.changes)
+
+	ttl := e.TTL
+	if ttl <= 0 {
+		ttl = defaultLeaderTTL
+	}
+	interval := e.Interval
+	if interval <= 0 {
+		interval = ttl / 3
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lock *LockHandle
+	var expires time.Time
+	defer func() {
+		if lock != nil {
+			e.report(lock.Unlock(context.WithoutCancel(ctx)))
+			e.notify(Leadership{})
+		}
+	}()
+
+	for {
+		start := time.Now()
+		if lock == nil {
+			var err error
+			lock, err = Lock(ctx, e.Name, ttl)
+			if err == nil {
+				expires = start.Add(ttl)
+				e.notify(Leadership{Leader: true, Token: lock.Token()})
+			} else if !errors.Is(err, ErrLocked) && ctx.Err() == nil {
+				e.report(err)
+			}
+		} else {
+			err := lock.Refresh(ctx)
+			if err == nil {
+				expires = start.Add(ttl)
+			} else if ctx.Err() == nil {
+				e.report(err)
+				if errors.Is(err, ErrLockLost) || !time.Now().Before(expires) {
+					lock = nil
+					e.notify(Leadership{})
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// notify replaces any unreceived change with the given change.
+func (e *LeaderElector) notify(change Leadership) {
+	for {
+		select {
+		case e.changes <- change:
+			return
+		default:
+		}
+		select {
+		case <-e.changes:
+		default:
+		}
+	}
+}
+
+func (e *LeaderElector) report(err error) {
+	if err != nil && e.OnError != nil {
+		e.OnError(err)
+	}
+}
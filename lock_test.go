@@ -0,0 +1,126 @@
+package monarch
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLock(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := WithContext(context.Background(), db)
+
+	first, err := Lock(ctx, "cron", time.Minute)
+	if err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	if _, err := Lock(ctx, "cron", time.Minute); !errors.Is(err, ErrLocked) {
+		t.Errorf("expected ErrLocked, got %v", err)
+	}
+	if err := first.Refresh(ctx); err != nil {
+		t.Errorf("Refresh failed: %v", err)
+	}
+	if err := first.Unlock(ctx); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+
+	second, err := Lock(ctx, "cron", time.Minute)
+	if err != nil {
+		t.Fatalf("Lock after Unlock failed: %v", err)
+	}
+	if second.Token() <= first.Token() {
+		t.Errorf("expected token greater than %d, got %d", first.Token(), second.Token())
+	}
+	if err := first.Refresh(ctx); !errors.Is(err, ErrLockLost) {
+		t.Errorf("expected ErrLockLost refreshing a released lock, got %v", err)
+	}
+	if err := first.Unlock(ctx); !errors.Is(err, ErrLockLost) {
+		t.Errorf("expected ErrLockLost unlocking a released lock, got %v", err)
+	}
+}
+
+func TestLockExpiry(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := WithContext(context.Background(), db)
+
+	expired, err := Lock(ctx, "cron", time.Millisecond)
+	if err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	next, err := Lock(ctx, "cron", time.Minute)
+	if err != nil {
+		t.Fatalf("Lock after expiry failed: %v", err)
+	}
+	if next.Token() <= expired.Token() {
+		t.Errorf("expected token greater than %d, got %d", expired.Token(), next.Token())
+	}
+	if err := expired.Refresh(ctx); !errors.Is(err, ErrLockLost) {
+		t.Errorf("expected ErrLockLost, got %v", err)
+	}
+}
+
+func TestLeaderElector(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := WithContext(context.Background(), db)
+
+	leaderCtx, stopLeader := context.WithCancel(ctx)
+	leader := &LeaderElector{Name: "cron", TTL: time.Second, Interval: 20 * time.Millisecond}
+	go leader.Run(leaderCtx)
+
+	change := <-leader.Changes()
+	if !change.Leader || change.Token == 0 {
+		t.Fatalf("expected first elector to lead, got %+v", change)
+	}
+
+	followerCtx, stopFollower := context.WithCancel(ctx)
+	defer stopFollower()
+	follower := &LeaderElector{Name: "cron", TTL: time.Second, Interval: 20 * time.Millisecond}
+	go follower.Run(followerCtx)
+
+	select {
+	case change := <-follower.Changes():
+		t.Fatalf("expected follower not to lead while leader runs, got %+v", change)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	stopLeader()
+	if change, ok := <-leader.Changes(); !ok || change.Leader {
+		t.Errorf("expected leader to step down, got %+v", change)
+	}
+	if _, ok := <-leader.Changes(); ok {
+		t.Errorf("expected changes to be closed after Run returns")
+	}
+
+	select {
+	case change := <-follower.Changes():
+		if !change.Leader || change.Token <= 0 {
+			t.Errorf("expected follower to lead, got %+v", change)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected follower to take over leadership")
+	}
+}
+
+func TestLeaderElectorNotify(t *testing.T) {
+	e := &LeaderElector{}
+	e.Changes()
+
+	e.notify(Leadership{Leader: true, Token: 1})
+	e.notify(Leadership{})
+	e.notify(Leadership{Leader: true, Token: 2})
+
+	if change := <-e.Changes(); change != (Leadership{Leader: true, Token: 2}) {
+		t.Errorf("expected only the latest change, got %+v", change)
+	}
+	select {
+	case change := <-e.Changes():
+		t.Errorf("expected no more changes, got %+v", change)
+	default:
+	}
+}
@@ -0,0 +1,350 @@
+package monarch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// WhenMatched is the action of a $merge when a result document matches a document
+// of the target collection.
+type WhenMatched string
+
+const (
+	// WhenMatchedReplace replaces the existing document with the result document.
+	WhenMatchedReplace WhenMatched = "replace"
+	// WhenMatchedKeepExisting keeps the existing document.
+	WhenMatchedKeepExisting WhenMatched = "keepExisting"
+	// WhenMatchedMerge merges the fields of the result document into the existing document.
+	WhenMatchedMerge WhenMatched = "merge"
+	// WhenMatchedFail stops the aggregation with an error.
+	WhenMatchedFail WhenMatched = "fail"
+)
+
+// WhenNotMatched is the action of a $merge when a result document does not match
+// any document of the target collection.
+type WhenNotMatched string
+
+const (
+	// WhenNotMatchedInsert inserts the result document.
+	WhenNotMatchedInsert WhenNotMatched = "insert"
+	// WhenNotMatchedDiscard discards the result document.
+	WhenNotMatchedDiscard WhenNotMatched = "discard"
+	// WhenNotMatchedFail stops the aggregation with an error.
+	WhenNotMatchedFail WhenNotMatched = "fail"
+)
+
+// MaterializeMode selects how Materialize writes the results of the pipeline to
+// the target collection.
+//
+// The zero value merges results into the target by _id, replacing matched documents
+// and inserting the others.
+type MaterializeMode struct {
+	// Out replaces the whole target collection with the results using $out.
+	// It cannot be combined with the other fields, and replaces the documents of
+	// every tenant, so it requires a CrossTenant context if the target is tenant-scoped.
+	Out bool
+	// On are the fields identifying the target document matched by a result.
+	// The target must have a unique index on them. Defaults to _id. The tenant
+	// field of tenant-scoped targets is added, see Materialize.
+	On []string
+	// WhenMatched is the action for results matching a target document.
+	// Defaults to WhenMatchedReplace.
+	WhenMatched WhenMatched
+	// WhenNotMatched is the action for results not matching any target document.
+	// Defaults to WhenNotMatchedInsert.
+	WhenNotMatched WhenNotMatched
+	// Watermark, if set, is a field of the source documents that increases as documents
+	// are inserted or updated, such as an updatedAt timestamp. Each run only aggregates
+	// the source documents with a watermark above the highest one already materialized.
+	// Source documents without the field are never materialized.
+	Watermark string
+}
+
+// MaterializeStats are the statistics of a Materialize run.
+type MaterializeStats struct {
+	// Target is the name of the target collection.
+	Target string
+	// Started is the time the run started.
+	Started time.Time
+	// Duration is the time the run took.
+	Duration time.Duration
+	// Processed is the number of source documents aggregated by an incremental run.
+	// It is -1 for runs without a watermark.
+	Processed int64
+	// Documents is the number of documents of the target after the run.
+	Documents int64
+	// Watermark is the highest watermark materialized so far, or the zero value for
+	// runs without a watermark or when no source document has one.
+	Watermark bson.RawValue
+}
+
+// materializationDocument stores the watermark of an incremental materialization.
+type materializationDocument struct {
+	Key       string        `bson:"_id"`
+	Watermark bson.RawValue `bson:"watermark"`
+	UpdatedAt time.Time     `bson:"updatedAt"`
+}
+
+// materializations is the collection storing the watermarks of incremental
+// materializations, keyed by target collection and tenant.
+const materializations Collection[materializationDocument] = "monarch_materializations"
+
+// Materialize runs the pipeline on the source collection and writes its results to
+// the target collection with a $merge or $out stage appended to the pipeline, as
+// selected by mode. The result type R of the target is the type of the documents
+// produced by the pipeline.
+//
+//	var UserStats monarch.Collection[UserStat] = "user_stats"
+//
+//	stats, err := monarch.Materialize(ctx, Orders, bson.A{
+//	    bson.M{"$group": bson.M{"_id": "$userId", "total": bson.M{"$sum": "$amount"}}},
+//	}, UserStats, monarch.MaterializeMode{WhenMatched: monarch.WhenMatchedReplace})
+//
+// With a Watermark, the pipeline runs only on the source documents changed since the
+// previous run, and the highest watermark seen is stored in the monarch_materializations
+// collection. Incremental runs must merge results, so pipelines that aggregate several
+// source documents into one result should use WhenMatchedMerge or a target that
+// accumulates them, since unchanged source documents are not aggregated again.
+//
+// The pipeline is scoped to the tenant in the context like Aggregate, and incremental
+// watermarks are stored per tenant if the source or the target is tenant-scoped. Results written to a tenant-scoped target get the
+// tenant field set to the tenant and are matched within the tenant, by the On fields
+// and the tenant field, so the target needs a unique index on them. Since _id is
+// unique across tenants, results keyed by values that tenants share, such as a
+// grouping key, should be matched on another field:
+//
+//	err := UserStats.EnsureIndexes(ctx, []mongo.IndexModel{{
+//	    Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "tenantId", Value: 1}},
+//	    Options: options.Index().SetUnique(true),
+//	}})
+//	stats, err := monarch.Materialize(ctx, Orders, bson.A{
+//	    bson.M{"$group": bson.M{"_id": "$userId", "total": bson.M{"$sum": "$amount"}}},
+//	    bson.M{"$project": bson.M{"_id": 0, "userId": "$_id", "total": 1}},
+//	}, UserStats, monarch.MaterializeMode{On: []string{"userId"}})
+func Materialize[R, T any](ctx context.Context, source Collection[T], pipeline any, target Collection[R], mode MaterializeMode) (MaterializeStats, error) {
+	stats := MaterializeStats{Target: string(target), Started: time.Now(), Processed: -1}
+	finish := func() (MaterializeStats, error) {
+		count, err := target.CountDocuments(ctx, bson.D{})
+		stats.Documents = count
+		stats.Duration = time.Since(stats.Started)
+		return stats, err
+	}
+	field, tenant, scoped, err := target.tenant(ctx)
+	if err != nil {
+		return stats, err
+	}
+	if scoped && mode.Out {
+		return stats, errors.New("monarch: $out materialization replaces the documents of every tenant and requires a CrossTenant context")
+	}
+	if !scoped {
+		field.name = ""
+	}
+	output, err := mode.stage(string(target), field.name)
+	if err != nil {
+		return stats, err
+	}
+	stages, err := pipelineStages(pipeline)
+	if err != nil {
+		return stats, err
+	}
+
+	var key string
+	if mode.Watermark != "" {
+		if err := checkWatermark(reflect.TypeFor[T](), mode.Watermark); err != nil {
+			return stats, err
+		}
+		// Tenants materializing into their part of the target keep their own
+		// watermark, even from a source that is not tenant-scoped.
+		key = string(target)
+		if scoped {
+			key += ":" + fmt.Sprint(tenant)
+		} else if _, tenant, ok, _ := source.tenant(ctx); ok {
+			key += ":" + fmt.Sprint(tenant)
+		}
+
+		window, processed, err := watermarkWindow(ctx, source, mode.Watermark, key, &stats.Watermark)
+		if err != nil {
+			return stats, err
+		}
+		stats.Processed = processed
+		if processed == 0 {
+			return finish()
+		}
+		stages = append(bson.A{bson.D{{Key: "$match", Value: window}}}, stages...)
+	}
+	if scoped {
+		stages = append(stages, bson.D{{Key: "$set", Value: bson.D{{Key: field.name, Value: tenant}}}})
+	}
+	aggregation, err := source.scopePipeline(ctx, append(stages, output))
+	if err != nil {
+		return stats, err
+	}
+
+	// The pipeline runs on the source, so errors are reported for the source, but
+	// it writes to the target.
+	db, err := getDB(ctx)
+	if err != nil {
+		return stats, err
+	}
+	defer target.invalidateWrite(ctx)
+	err = retry(ctx, string(source), "Materialize", opWrite, func() error {
+		cursor, err := db.Collection(string(source)).Aggregate(ctx, aggregation)
+		if err == nil {
+			err = cursor.Close(ctx)
+		}
+		return wrapError(string(source), "Materialize", err)
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	if mode.Watermark != "" {
+		_, err := materializations.UpdateOne(ctx, bson.D{{Key: "_id", Value: key}}, bson.D{
+			{Key: "$max", Value: bson.D{{Key: "watermark", Value: stats.Watermark}}},
+			{Key: "$set", Value: bson.D{{Key: "updatedAt", Value: time.Now()}}},
+		}, options.UpdateOne().SetUpsert(true))
+		if err != nil {
+			return stats, err
+		}
+	}
+	return finish()
+}
+
+// MaterializeEvery runs Materialize immediately and then at every interval until the
+// context is done, and then returns the context error. Each run is reported to report,
+// if set, including failed runs.
+//
+// Runs do not overlap within a process. To materialize from a single instance, run
+// MaterializeEvery while leader, see LeaderElector.
+func MaterializeEvery[R, T any](ctx context.Context, interval time.Duration, source Collection[T], pipeline any, target Collection[R], mode MaterializeMode, report func(MaterializeStats, error)) error {
+	if interval <= 0 {
+		return errors.New("monarch: materialize interval must be positive")
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		stats, err := Materialize(ctx, source, pipeline, target, mode)
+		if report != nil && ctx.Err() == nil {
+			report(stats, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// stage returns the output stage writing to the target collection. Results are
+// matched within the tenant by the tenant field, if not empty.
+func (m MaterializeMode) stage(target, tenantField string) (bson.D, error) {
+	if m.Out {
+		if len(m.On) > 0 || m.WhenMatched != "" || m.WhenNotMatched != "" || m.Watermark != "" {
+			return nil, errors.New("monarch: $out materialization cannot be combined with merge options or a watermark")
+		}
+		return bson.D{{Key: "$out", Value: target}}, nil
+	}
+
+	merge := bson.D{{Key: "into", Value: target}}
+	on := m.On
+	if tenantField != "" {
+		if len(on) == 0 {
+			on = []string{"_id"}
+		}
+		if !slices.Contains(on, tenantField) {
+			on = append(slices.Clip(on), tenantField)
+		}
+	}
+	if len(on) > 0 {
+		merge = append(merge, bson.E{Key: "on", Value: on})
+	}
+	whenMatched := m.WhenMatched
+	if whenMatched == "" {
+		whenMatched = WhenMatchedReplace
+	}
+	whenNotMatched := m.WhenNotMatched
+	if whenNotMatched == "" {
+		whenNotMatched = WhenNotMatchedInsert
+	}
+	merge = append(merge,
+		bson.E{Key: "whenMatched", Value: string(whenMatched)},
+		bson.E{Key: "whenNotMatched", Value: string(whenNotMatched)},
+	)
+	return bson.D{{Key: "$merge", Value: merge}}, nil
+}
+
+// watermarkWindow returns the filter matching the source documents with a watermark
+// above the stored one and up to the current highest one, which is set to watermark,
+// and the number of documents matched.
+//
+// The upper bound keeps documents changed while the pipeline runs for the next run.
+func watermarkWindow[T any](ctx context.Context, source Collection[T], field, key string, watermark *bson.RawValue) (bson.D, int64, error) {
+	bound := bson.D{{Key: "$ne", Value: nil}}
+	previous, err := materializations.FindOne(ctx, bson.D{{Key: "_id", Value: key}})
+	switch {
+	case err == nil:
+		*watermark = previous.Watermark
+		bound = bson.D{{Key: "$gt", Value: previous.Watermark}}
+	case !errors.Is(err, mongo.ErrNoDocuments):
+		return nil, 0, err
+	}
+
+	type window struct {
+		Watermark bson.RawValue `bson:"watermark"`
+		Count     int64         `bson:"count"`
+	}
+	results, err := AggregateAs[window](ctx, source, bson.A{
+		bson.D{{Key: "$match", Value: bson.D{{Key: field, Value: bound}}}},
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: nil},
+			{Key: "watermark", Value: bson.D{{Key: "$max", Value: "$" + field}}},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+	})
+	if err != nil || len(results) == 0 {
+		return nil, 0, err
+	}
+	*watermark = results[0].Watermark
+	return bson.D{{Key: field, Value: append(bound, bson.E{Key: "$lte", Value: results[0].Watermark})}}, results[0].Count, nil
+}
+
+// checkWatermark returns an error if the first element of the watermark path is not
+// a field of the struct type t. Types other than structs are not checked.
+func checkWatermark(t reflect.Type, field string) error {
+	t = indirectType(t)
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	name, _, _ := strings.Cut(field, ".")
+	if _, ok := lookupField(t, name); !ok {
+		return fmt.Errorf("monarch: watermark field %q not found in %s", field, t)
+	}
+	return nil
+}
+
+// pipelineStages returns the stages of pipeline, which must be a slice or array.
+func pipelineStages(pipeline any) (bson.A, error) {
+	if pipeline == nil {
+		return bson.A{}, nil
+	}
+	v := reflect.Indirect(reflect.ValueOf(pipeline))
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("monarch: pipeline must be a slice of stages, got %T", pipeline)
+	}
+	stages := make(bson.A, 0, v.Len()+2)
+	for i := range v.Len() {
+		stages = append(stages, v.Index(i).Interface())
+	}
+	return stages, nil
+}
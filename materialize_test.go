@@ -0,0 +1,321 @@
+package monarch
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type AgeGroup struct {
+	Age   int `bson:"_id"`
+	Count int `bson:"count"`
+}
+
+var AgeGroups Collection[AgeGroup] = "age_groups"
+
+var ageGroupPipeline = bson.A{
+	bson.M{"$group": bson.M{"_id": "$age", "count": bson.M{"$sum": 1}}},
+}
+
+func TestMaterializeModeStage(t *testing.T) {
+	stage, err := MaterializeMode{}.stage("target", "")
+	if err != nil {
+		t.Fatalf("stage failed: %v", err)
+	}
+	raw := mustMarshal(t, stage)
+	if got := raw.Lookup("$merge", "whenMatched").StringValue(); got != "replace" {
+		t.Errorf("expected default whenMatched replace, got %q", got)
+	}
+	if got := raw.Lookup("$merge", "whenNotMatched").StringValue(); got != "insert" {
+		t.Errorf("expected default whenNotMatched insert, got %q", got)
+	}
+	if _, err := raw.LookupErr("$merge", "on"); err == nil {
+		t.Error("expected no on fields by default")
+	}
+
+	stage, err = MaterializeMode{On: []string{"day"}, WhenMatched: WhenMatchedMerge, WhenNotMatched: WhenNotMatchedDiscard}.stage("target", "")
+	if err != nil {
+		t.Fatalf("stage failed: %v", err)
+	}
+	raw = mustMarshal(t, stage)
+	if got := raw.Lookup("$merge", "on", "0").StringValue(); got != "day" {
+		t.Errorf("expected on day, got %q", got)
+	}
+	if got := raw.Lookup("$merge", "whenMatched").StringValue(); got != "merge" {
+		t.Errorf("expected whenMatched merge, got %q", got)
+	}
+
+	stage, err = MaterializeMode{Out: true}.stage("target", "")
+	if err != nil || !reflect.DeepEqual(stage, bson.D{{Key: "$out", Value: "target"}}) {
+		t.Errorf("expected $out stage, got %v, %v", stage, err)
+	}
+	if _, err := (MaterializeMode{Out: true, Watermark: "updatedAt"}).stage("target", ""); err == nil {
+		t.Error("expected $out with a watermark to fail")
+	}
+
+	stage, err = MaterializeMode{}.stage("target", "tenantId")
+	if err != nil {
+		t.Fatalf("stage failed: %v", err)
+	}
+	raw = mustMarshal(t, stage)
+	if on := raw.Lookup("$merge", "on"); on.Array().Index(0).StringValue() != "_id" || on.Array().Index(1).StringValue() != "tenantId" {
+		t.Errorf("expected on _id and tenantId, got %v", on)
+	}
+	stage, err = MaterializeMode{On: []string{"day"}}.stage("target", "tenantId")
+	if err != nil {
+		t.Fatalf("stage failed: %v", err)
+	}
+	raw = mustMarshal(t, stage)
+	if on := raw.Lookup("$merge", "on"); on.Array().Index(0).StringValue() != "day" || on.Array().Index(1).StringValue() != "tenantId" {
+		t.Errorf("expected on day and tenantId, got %v", on)
+	}
+}
+
+func TestMaterializeValidation(t *testing.T) {
+	_, err := Materialize(context.Background(), Users, ageGroupPipeline, AgeGroups, MaterializeMode{Watermark: "updatedAt"})
+	if err == nil || !strings.Contains(err.Error(), `watermark field "updatedAt" not found`) {
+		t.Errorf("expected unknown watermark field to fail, got %v", err)
+	}
+	if _, err := Materialize(context.Background(), Users, bson.M{}, AgeGroups, MaterializeMode{}); err == nil {
+		t.Error("expected pipeline that is not a slice to fail")
+	}
+	if _, err := Materialize(context.Background(), Users, ageGroupPipeline, AgeGroups, MaterializeMode{}); !errors.Is(err, ErrNoDatabase) {
+		t.Errorf("expected ErrNoDatabase, got %v", err)
+	}
+	tenant := WithTenant(context.Background(), "acme")
+	if _, err := Materialize(tenant, Users, ageGroupPipeline, ProjectCounts, MaterializeMode{Out: true}); err == nil || errors.Is(err, ErrNoDatabase) {
+		t.Errorf("expected $out to a tenant-scoped target to fail, got %v", err)
+	}
+	if _, err := Materialize(CrossTenant(tenant), Users, ageGroupPipeline, ProjectCounts, MaterializeMode{Out: true}); !errors.Is(err, ErrNoDatabase) {
+		t.Errorf("expected $out across tenants to be allowed, got %v", err)
+	}
+	if _, err := Materialize(tenant, Users, ageGroupPipeline, AgeGroups, MaterializeMode{Out: true}); !errors.Is(err, ErrNoDatabase) {
+		t.Errorf("expected $out with a tenant to a target that is not tenant-scoped to be allowed, got %v", err)
+	}
+	if err := MaterializeEvery(context.Background(), 0, Users, ageGroupPipeline, AgeGroups, MaterializeMode{}, nil); err == nil {
+		t.Error("expected non-positive interval to fail")
+	}
+}
+
+type Event struct {
+	ID      string    `bson:"_id"`
+	Kind    string    `bson:"kind"`
+	Created time.Time `bson:"created"`
+}
+
+var Events Collection[Event] = "events"
+
+type EventCopy struct {
+	ID   string `bson:"_id"`
+	Kind string `bson:"kind"`
+}
+
+var EventCopies Collection[EventCopy] = "event_copies"
+
+type ProjectCount struct {
+	ID       bson.ObjectID `bson:"_id,omitempty"`
+	Name     string        `bson:"name"`
+	TenantID string        `bson:"tenantId" monarch:"tenant"`
+	Count    int           `bson:"count"`
+}
+
+var ProjectCounts Collection[ProjectCount] = "project_counts"
+
+type EventKind struct {
+	ID       bson.ObjectID `bson:"_id,omitempty"`
+	Kind     string        `bson:"kind"`
+	TenantID string        `bson:"tenantId" monarch:"tenant"`
+}
+
+var EventKinds Collection[EventKind] = "event_kinds"
+
+func TestMaterialize(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := WithContext(context.Background(), db)
+
+	t.Run("merge and out", func(t *testing.T) {
+		cleanupCollection(t, ctx, Users)
+		cleanupCollection(t, ctx, AgeGroups)
+		_, err := Users.InsertMany(ctx, []User{
+			{ID: "1", Name: "Alice", Age: 30},
+			{ID: "2", Name: "Bob", Age: 30},
+			{ID: "3", Name: "Carol", Age: 40},
+		})
+		if err != nil {
+			t.Fatalf("InsertMany failed: %v", err)
+		}
+
+		stats, err := Materialize(ctx, Users, ageGroupPipeline, AgeGroups, MaterializeMode{})
+		if err != nil {
+			t.Fatalf("Materialize failed: %v", err)
+		}
+		if stats.Documents != 2 || stats.Processed != -1 || stats.Target != "age_groups" {
+			t.Errorf("unexpected stats %+v", stats)
+		}
+		group, err := AgeGroups.FindOne(ctx, bson.M{"_id": 30})
+		if err != nil || group.Count != 2 {
+			t.Errorf("expected 2 users aged 30, got %+v, %v", group, err)
+		}
+
+		// $out replaces the target, dropping groups no longer produced.
+		if _, err := Users.DeleteOne(ctx, bson.M{"_id": "3"}); err != nil {
+			t.Fatalf("DeleteOne failed: %v", err)
+		}
+		stats, err = Materialize(ctx, Users, ageGroupPipeline, AgeGroups, MaterializeMode{Out: true})
+		if err != nil {
+			t.Fatalf("Materialize with $out failed: %v", err)
+		}
+		if stats.Documents != 1 {
+			t.Errorf("expected target replaced with 1 group, got %d", stats.Documents)
+		}
+	})
+
+	t.Run("incremental", func(t *testing.T) {
+		cleanupCollection(t, ctx, Events)
+		cleanupCollection(t, ctx, EventCopies)
+		if _, err := materializations.DeleteMany(ctx, bson.M{}); err != nil {
+			t.Fatalf("DeleteMany failed: %v", err)
+		}
+
+		start := time.Now().UTC().Truncate(time.Millisecond)
+		mode := MaterializeMode{Watermark: "created"}
+		project := bson.A{bson.M{"$project": bson.M{"kind": 1}}}
+		if _, err := Events.InsertMany(ctx, []Event{
+			{ID: "1", Kind: "a", Created: start},
+			{ID: "2", Kind: "b", Created: start.Add(time.Second)},
+		}); err != nil {
+			t.Fatalf("InsertMany failed: %v", err)
+		}
+		stats, err := Materialize(ctx, Events, project, EventCopies, mode)
+		if err != nil {
+			t.Fatalf("Materialize failed: %v", err)
+		}
+		if stats.Processed != 2 || stats.Documents != 2 || !stats.Watermark.Time().Equal(start.Add(time.Second)) {
+			t.Errorf("unexpected stats %+v", stats)
+		}
+
+		stats, err = Materialize(ctx, Events, project, EventCopies, mode)
+		if err != nil {
+			t.Fatalf("Materialize failed: %v", err)
+		}
+		if stats.Processed != 0 {
+			t.Errorf("expected no changed events, got %d", stats.Processed)
+		}
+
+		if _, err := Events.InsertOne(ctx, Event{ID: "3", Kind: "c", Created: start.Add(2 * time.Second)}); err != nil {
+			t.Fatalf("InsertOne failed: %v", err)
+		}
+		// Events not changed since the last run are not aggregated again.
+		if _, err := EventCopies.DeleteOne(ctx, bson.M{"_id": "1"}); err != nil {
+			t.Fatalf("DeleteOne failed: %v", err)
+		}
+		stats, err = Materialize(ctx, Events, project, EventCopies, mode)
+		if err != nil {
+			t.Fatalf("Materialize failed: %v", err)
+		}
+		if stats.Processed != 1 || stats.Documents != 2 {
+			t.Errorf("expected only the new event materialized, got %+v", stats)
+		}
+	})
+
+	t.Run("every", func(t *testing.T) {
+		cleanupCollection(t, ctx, AgeGroups)
+		everyCtx, cancel := context.WithCancel(ctx)
+		runs := 0
+		err := MaterializeEvery(everyCtx, 10*time.Millisecond, Users, ageGroupPipeline, AgeGroups, MaterializeMode{}, func(stats MaterializeStats, err error) {
+			if err != nil {
+				t.Errorf("Materialize failed: %v", err)
+			}
+			if runs++; runs == 3 {
+				cancel()
+			}
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+		if runs != 3 {
+			t.Errorf("expected 3 runs, got %d", runs)
+		}
+	})
+	t.Run("tenant", func(t *testing.T) {
+		cross := CrossTenant(ctx)
+		cleanupCollection(t, cross, Projects)
+		cleanupCollection(t, cross, ProjectCounts)
+		err := ProjectCounts.EnsureIndexes(ctx, []mongo.IndexModel{{
+			Keys:    bson.D{{Key: "name", Value: 1}, {Key: "tenantId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}})
+		if err != nil {
+			t.Fatalf("EnsureIndexes failed: %v", err)
+		}
+
+		acme, globex := WithTenant(ctx, "acme"), WithTenant(ctx, "globex")
+		if _, err := Projects.InsertMany(acme, []Project{{ID: "p1", Name: "rockets"}, {ID: "p2", Name: "rockets"}}); err != nil {
+			t.Fatalf("InsertMany failed: %v", err)
+		}
+		if _, err := Projects.InsertMany(globex, []Project{{ID: "p3", Name: "rockets"}}); err != nil {
+			t.Fatalf("InsertMany failed: %v", err)
+		}
+
+		pipeline := bson.A{
+			bson.M{"$group": bson.M{"_id": "$name", "count": bson.M{"$sum": 1}}},
+			bson.M{"$project": bson.M{"_id": 0, "name": "$_id", "count": 1}},
+		}
+		for _, tenant := range []context.Context{acme, globex, acme} {
+			if _, err := Materialize(tenant, Projects, pipeline, ProjectCounts, MaterializeMode{On: []string{"name"}}); err != nil {
+				t.Fatalf("Materialize failed: %v", err)
+			}
+		}
+		if count, err := ProjectCounts.FindOne(acme, bson.M{"name": "rockets"}); err != nil || count.Count != 2 {
+			t.Errorf("expected 2 acme rockets, got %+v, %v", count, err)
+		}
+		if count, err := ProjectCounts.FindOne(globex, bson.M{"name": "rockets"}); err != nil || count.Count != 1 {
+			t.Errorf("expected 1 globex rocket, got %+v, %v", count, err)
+		}
+	})
+
+	t.Run("tenant watermarks", func(t *testing.T) {
+		cross := CrossTenant(ctx)
+		cleanupCollection(t, ctx, Events)
+		cleanupCollection(t, cross, EventKinds)
+		if _, err := materializations.DeleteMany(ctx, bson.M{}); err != nil {
+			t.Fatalf("DeleteMany failed: %v", err)
+		}
+		err := EventKinds.EnsureIndexes(ctx, []mongo.IndexModel{{
+			Keys:    bson.D{{Key: "kind", Value: 1}, {Key: "tenantId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}})
+		if err != nil {
+			t.Fatalf("EnsureIndexes failed: %v", err)
+		}
+
+		start := time.Now().UTC().Truncate(time.Millisecond)
+		if _, err := Events.InsertMany(ctx, []Event{
+			{ID: "1", Kind: "a", Created: start},
+			{ID: "2", Kind: "b", Created: start.Add(time.Second)},
+		}); err != nil {
+			t.Fatalf("InsertMany failed: %v", err)
+		}
+
+		// Each tenant materializes the shared source into its own part of the target.
+		mode := MaterializeMode{Watermark: "created", On: []string{"kind"}}
+		project := bson.A{bson.M{"$project": bson.M{"_id": 0, "kind": 1}}}
+		for _, tenant := range []string{"acme", "globex"} {
+			stats, err := Materialize(WithTenant(ctx, tenant), Events, project, EventKinds, mode)
+			if err != nil {
+				t.Fatalf("Materialize for %s failed: %v", tenant, err)
+			}
+			if stats.Processed != 2 || stats.Documents != 2 {
+				t.Errorf("expected both events materialized for %s, got %+v", tenant, stats)
+			}
+		}
+	})
+}
@@ -72,13 +72,22 @@
 //
 // # Error Handling
 //
-// All MongoDB driver errors pass through unchanged:
+// mongo.ErrNoDocuments is returned unchanged. Other driver errors are wrapped in an
+// *OpError naming the collection and operation, and remain available to errors.Is
+// and errors.As:
 //
 //	user, err := Users.FindOne(ctx, bson.M{"_id": "nonexistent"})
 //	if errors.Is(err, mongo.ErrNoDocuments) {
 //	    // Handle not found
 //	}
 //
+// Use the classification helpers to inspect common failures:
+//
+//	_, err := Users.InsertOne(ctx, user)
+//	if dup, ok := monarch.IsDuplicateKey(err); ok {
+//	    // dup.Index, dup.Key
+//	}
+//
 // # Streaming Results
 //
 // Use FindSeq for memory-efficient iteration over large result sets:
@@ -95,8 +104,11 @@ package monarch
 import (
 	"context"
 	"errors"
+	"fmt"
 	"iter"
+	"sync"
 
+	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
@@ -120,6 +132,12 @@ func getDB(ctx context.Context) (*mongo.Database, error) {
 	return db, nil
 }
 
+// Database returns the database instance attached to the context with WithContext.
+// Returns ErrNoDatabase if the context has no database.
+func Database(ctx context.Context) (*mongo.Database, error) {
+	return getDB(ctx)
+}
+
 // Collection represents a type-safe MongoDB collection.
 //
 // The generic type parameter T specifies the document structure for this collection,
@@ -129,6 +147,62 @@ func getDB(ctx context.Context) (*mongo.Database, error) {
 // The string value must match the actual collection name in MongoDB.
 type Collection[T any] string
 
+// Field represents a typed field path within documents of type T.
+//
+// The type parameter V specifies the type of a single value stored at the field.
+// For array fields, V is the element type rather than the slice type.
+// Fields are defined as typed string constants holding the dotted field path:
+//
+//	var UserStatus monarch.Field[User, string] = "status"
+type Field[T, V any] string
+
+// exec runs fn with the driver collection for c using the database in the context.
+// Driver errors returned by fn are wrapped with the collection name and operation,
+// and fn is retried according to the retry policy in the context. Writes invalidate
+// the cached lookups of the collection, whether or not they succeed.
+func (c Collection[T]) exec(ctx context.Context, op string, kind opKind, fn func(collection *mongo.Collection) error) error {
+	db, err := getDB(ctx)
+	if err != nil {
+		return err
+	}
+	if kind == opWrite {
+		defer c.invalidateWrite(ctx)
+	}
+	collection := db.Collection(string(c))
+	return retry(ctx, string(c), op, kind, func() error {
+		return wrapError(string(c), op, fn(collection))
+	})
+}
+
+// prepareFilter scopes filter to the tenant in the context and encrypts the values
+// compared with encrypted fields.
+func (c Collection[T]) prepareFilter(ctx context.Context, filter any) (any, error) {
+	filter, err := c.scopeFilter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return encryptFilter[T](ctx, filter)
+}
+
+// prepareDocument sets the tenant field of doc to the tenant in the context and
+// encrypts its encrypted fields.
+func (c Collection[T]) prepareDocument(ctx context.Context, doc T) (any, error) {
+	doc, err := c.stampTenant(ctx, doc)
+	if err != nil {
+		return nil, err
+	}
+	return encryptDocument(ctx, doc)
+}
+
+// prepareUpdate checks that update does not change the tenant field and encrypts
+// the values of encrypted fields it sets.
+func (c Collection[T]) prepareUpdate(ctx context.Context, update any) (any, error) {
+	if err := c.checkTenantUpdate(ctx, update); err != nil {
+		return nil, err
+	}
+	return encryptUpdate[T](ctx, update)
+}
+
 // Find executes a find command and returns all documents matching the filter as a slice.
 //
 // All results are loaded into memory. For large result sets, use FindSeq instead.
@@ -138,19 +212,20 @@ type Collection[T any] string
 //
 // See [mongo.Collection.Find] for more details.
 func (c Collection[T]) Find(ctx context.Context, filter any, opts ...options.Lister[options.FindOptions]) ([]T, error) {
-	db, err := getDB(ctx)
+	filter, err := c.guardFilter(ctx, "Find", filter, false)
 	if err != nil {
 		return nil, err
 	}
-	collection := db.Collection(string(c))
-	cursor, err := collection.Find(ctx, filter, opts...)
-	if err != nil {
-		return nil, err
-	}
-	defer cursor.Close(ctx)
-
 	var results []T
-	if err = cursor.All(ctx, &results); err != nil {
+	err = c.exec(ctx, "Find", opRead, func(collection *mongo.Collection) error {
+		cursor, err := collection.Find(ctx, filter, opts...)
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+		return decodeAll(ctx, cursor, &results)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return results, nil
@@ -166,14 +241,14 @@ func (c Collection[T]) Find(ctx context.Context, filter any, opts ...options.Lis
 // See [mongo.Collection.Find] for more details on the underlying operation.
 func (c Collection[T]) FindSeq(ctx context.Context, filter any, opts ...options.Lister[options.FindOptions]) iter.Seq2[T, error] {
 	return func(yield func(T, error) bool) {
-		db, err := getDB(ctx)
-		if err != nil {
-			var zero T
-			yield(zero, err)
-			return
+		var cursor *mongo.Cursor
+		filter, err := c.guardFilter(ctx, "FindSeq", filter, false)
+		if err == nil {
+			err = c.exec(ctx, "FindSeq", opRead, func(collection *mongo.Collection) (err error) {
+				cursor, err = collection.Find(ctx, filter, opts...)
+				return err
+			})
 		}
-		collection := db.Collection(string(c))
-		cursor, err := collection.Find(ctx, filter, opts...)
 		if err != nil {
 			var zero T
 			yield(zero, err)
@@ -183,15 +258,15 @@ func (c Collection[T]) FindSeq(ctx context.Context, filter any, opts ...options.
 
 		for cursor.Next(ctx) {
 			var result T
-			err := cursor.Decode(&result)
-			if !yield(result, err) {
+			err := decodeCurrent(ctx, cursor, &result)
+			if !yield(result, wrapError(string(c), "FindSeq", err)) {
 				return
 			}
 		}
 
 		if err := cursor.Err(); err != nil {
 			var zero T
-			yield(zero, err)
+			yield(zero, wrapError(string(c), "FindSeq", err))
 		}
 	}
 }
@@ -204,14 +279,22 @@ func (c Collection[T]) FindSeq(ctx context.Context, filter any, opts ...options.
 //
 // See [mongo.Collection.FindOne] for more details.
 func (c Collection[T]) FindOne(ctx context.Context, filter any, opts ...options.Lister[options.FindOneOptions]) (T, error) {
-	var result T
-	db, err := getDB(ctx)
+	filter, err := c.guardFilter(ctx, "FindOne", filter, true)
 	if err != nil {
+		var zero T
+		return zero, err
+	}
+	find := func() (T, error) {
+		var result T
+		err := c.exec(ctx, "FindOne", opRead, func(collection *mongo.Collection) error {
+			return decodeSingle(ctx, collection.FindOne(ctx, filter, opts...), &result)
+		})
 		return result, err
 	}
-	collection := db.Collection(string(c))
-	err = collection.FindOne(ctx, filter, opts...).Decode(&result)
-	return result, err
+	if config, ttl, ok := cacheFor(ctx, string(c)); ok && len(opts) == 0 && !inTransaction(ctx) {
+		return c.cachedFindOne(ctx, config, ttl, filter, find)
+	}
+	return find()
 }
 
 // FindOneAndUpdate executes a findAndModify command to update at most one document.
@@ -227,12 +310,18 @@ func (c Collection[T]) FindOne(ctx context.Context, filter any, opts ...options.
 // See [mongo.Collection.FindOneAndUpdate] for more details.
 func (c Collection[T]) FindOneAndUpdate(ctx context.Context, filter any, update any, opts ...options.Lister[options.FindOneAndUpdateOptions]) (T, error) {
 	var result T
-	db, err := getDB(ctx)
+	filter, err := c.guardFilter(ctx, "FindOneAndUpdate", filter, true)
 	if err != nil {
 		return result, err
 	}
-	collection := db.Collection(string(c))
-	err = collection.FindOneAndUpdate(ctx, filter, update, opts...).Decode(&result)
+	if update, err = c.prepareUpdate(ctx, update); err != nil {
+		return result, err
+	}
+	err = c.track(ctx, "FindOneAndUpdate", filter, listedOption(opts, func(o *options.FindOneAndUpdateOptions) any { return o.Sort }), func(ctx context.Context) error {
+		return c.exec(ctx, "FindOneAndUpdate", opWrite, func(collection *mongo.Collection) error {
+			return decodeSingle(ctx, collection.FindOneAndUpdate(ctx, filter, update, opts...), &result)
+		})
+	}, func() any { return documentID(result) })
 	return result, err
 }
 
@@ -249,12 +338,19 @@ func (c Collection[T]) FindOneAndUpdate(ctx context.Context, filter any, update
 // See [mongo.Collection.FindOneAndReplace] for more details.
 func (c Collection[T]) FindOneAndReplace(ctx context.Context, filter any, replacement T, opts ...options.Lister[options.FindOneAndReplaceOptions]) (T, error) {
 	var result T
-	db, err := getDB(ctx)
+	filter, err := c.guardFilter(ctx, "FindOneAndReplace", filter, true)
 	if err != nil {
 		return result, err
 	}
-	collection := db.Collection(string(c))
-	err = collection.FindOneAndReplace(ctx, filter, replacement, opts...).Decode(&result)
+	doc, err := c.prepareDocument(ctx, replacement)
+	if err != nil {
+		return result, err
+	}
+	err = c.track(ctx, "FindOneAndReplace", filter, listedOption(opts, func(o *options.FindOneAndReplaceOptions) any { return o.Sort }), func(ctx context.Context) error {
+		return c.exec(ctx, "FindOneAndReplace", opWrite, func(collection *mongo.Collection) error {
+			return decodeSingle(ctx, collection.FindOneAndReplace(ctx, filter, doc, opts...), &result)
+		})
+	}, func() any { return documentID(result) })
 	return result, err
 }
 
@@ -267,12 +363,15 @@ func (c Collection[T]) FindOneAndReplace(ctx context.Context, filter any, replac
 // See [mongo.Collection.FindOneAndDelete] for more details.
 func (c Collection[T]) FindOneAndDelete(ctx context.Context, filter any, opts ...options.Lister[options.FindOneAndDeleteOptions]) (T, error) {
 	var result T
-	db, err := getDB(ctx)
+	filter, err := c.guardFilter(ctx, "FindOneAndDelete", filter, true)
 	if err != nil {
 		return result, err
 	}
-	collection := db.Collection(string(c))
-	err = collection.FindOneAndDelete(ctx, filter, opts...).Decode(&result)
+	err = c.track(ctx, "FindOneAndDelete", filter, listedOption(opts, func(o *options.FindOneAndDeleteOptions) any { return o.Sort }), func(ctx context.Context) error {
+		return c.exec(ctx, "FindOneAndDelete", opWrite, func(collection *mongo.Collection) error {
+			return decodeSingle(ctx, collection.FindOneAndDelete(ctx, filter, opts...), &result)
+		})
+	}, nil)
 	return result, err
 }
 
@@ -283,12 +382,18 @@ func (c Collection[T]) FindOneAndDelete(ctx context.Context, filter any, opts ..
 //
 // See [mongo.Collection.InsertOne] for more details.
 func (c Collection[T]) InsertOne(ctx context.Context, value T, opts ...options.Lister[options.InsertOneOptions]) (*mongo.InsertOneResult, error) {
-	db, err := getDB(ctx)
+	doc, err := c.prepareDocument(ctx, value)
 	if err != nil {
 		return nil, err
 	}
-	collection := db.Collection(string(c))
-	return collection.InsertOne(ctx, value, opts...)
+	var result *mongo.InsertOneResult
+	err = c.track(ctx, "InsertOne", nil, nil, func(ctx context.Context) error {
+		return c.exec(ctx, "InsertOne", opWrite, func(collection *mongo.Collection) (err error) {
+			result, err = collection.InsertOne(ctx, doc, opts...)
+			return err
+		})
+	}, func() any { return result.InsertedID })
+	return result, err
 }
 
 // InsertMany executes an insert command to insert multiple documents into the collection.
@@ -302,16 +407,20 @@ func (c Collection[T]) InsertOne(ctx context.Context, value T, opts ...options.L
 //
 // See [mongo.Collection.InsertMany] for more details.
 func (c Collection[T]) InsertMany(ctx context.Context, values []T, opts ...options.Lister[options.InsertManyOptions]) (*mongo.InsertManyResult, error) {
-	db, err := getDB(ctx)
-	if err != nil {
-		return nil, err
-	}
-	collection := db.Collection(string(c))
 	docs := make([]any, len(values))
 	for i, v := range values {
-		docs[i] = v
+		doc, err := c.prepareDocument(ctx, v)
+		if err != nil {
+			return nil, err
+		}
+		docs[i] = doc
 	}
-	return collection.InsertMany(ctx, docs, opts...)
+	var result *mongo.InsertManyResult
+	err := c.exec(ctx, "InsertMany", opWrite, func(collection *mongo.Collection) (err error) {
+		result, err = collection.InsertMany(ctx, docs, opts...)
+		return err
+	})
+	return result, err
 }
 
 // UpdateOne executes an update command to update at most one document matching the filter.
@@ -325,12 +434,21 @@ func (c Collection[T]) InsertMany(ctx context.Context, values []T, opts ...optio
 //
 // See [mongo.Collection.UpdateOne] for more details.
 func (c Collection[T]) UpdateOne(ctx context.Context, filter any, update any, opts ...options.Lister[options.UpdateOneOptions]) (*mongo.UpdateResult, error) {
-	db, err := getDB(ctx)
+	filter, err := c.guardFilter(ctx, "UpdateOne", filter, true)
 	if err != nil {
 		return nil, err
 	}
-	collection := db.Collection(string(c))
-	return collection.UpdateOne(ctx, filter, update, opts...)
+	if update, err = c.prepareUpdate(ctx, update); err != nil {
+		return nil, err
+	}
+	var result *mongo.UpdateResult
+	err = c.track(ctx, "UpdateOne", filter, listedOption(opts, func(o *options.UpdateOneOptions) any { return o.Sort }), func(ctx context.Context) error {
+		return c.exec(ctx, "UpdateOne", opWrite, func(collection *mongo.Collection) (err error) {
+			result, err = collection.UpdateOne(ctx, filter, update, opts...)
+			return err
+		})
+	}, func() any { return result.UpsertedID })
+	return result, err
 }
 
 // UpdateMany executes an update command to update all documents matching the filter.
@@ -341,12 +459,19 @@ func (c Collection[T]) UpdateOne(ctx context.Context, filter any, update any, op
 //
 // See [mongo.Collection.UpdateMany] for more details.
 func (c Collection[T]) UpdateMany(ctx context.Context, filter any, update any, opts ...options.Lister[options.UpdateManyOptions]) (*mongo.UpdateResult, error) {
-	db, err := getDB(ctx)
+	filter, err := c.guardFilter(ctx, "UpdateMany", filter, false)
 	if err != nil {
 		return nil, err
 	}
-	collection := db.Collection(string(c))
-	return collection.UpdateMany(ctx, filter, update, opts...)
+	if update, err = c.prepareUpdate(ctx, update); err != nil {
+		return nil, err
+	}
+	var result *mongo.UpdateResult
+	err = c.exec(ctx, "UpdateMany", opWrite, func(collection *mongo.Collection) (err error) {
+		result, err = collection.UpdateMany(ctx, filter, update, opts...)
+		return err
+	})
+	return result, err
 }
 
 // ReplaceOne executes an update command to replace at most one document matching the filter.
@@ -360,12 +485,22 @@ func (c Collection[T]) UpdateMany(ctx context.Context, filter any, update any, o
 //
 // See [mongo.Collection.ReplaceOne] for more details.
 func (c Collection[T]) ReplaceOne(ctx context.Context, filter any, replacement T, opts ...options.Lister[options.ReplaceOptions]) (*mongo.UpdateResult, error) {
-	db, err := getDB(ctx)
+	filter, err := c.guardFilter(ctx, "ReplaceOne", filter, true)
 	if err != nil {
 		return nil, err
 	}
-	collection := db.Collection(string(c))
-	return collection.ReplaceOne(ctx, filter, replacement, opts...)
+	doc, err := c.prepareDocument(ctx, replacement)
+	if err != nil {
+		return nil, err
+	}
+	var result *mongo.UpdateResult
+	err = c.track(ctx, "ReplaceOne", filter, listedOption(opts, func(o *options.ReplaceOptions) any { return o.Sort }), func(ctx context.Context) error {
+		return c.exec(ctx, "ReplaceOne", opWrite, func(collection *mongo.Collection) (err error) {
+			result, err = collection.ReplaceOne(ctx, filter, doc, opts...)
+			return err
+		})
+	}, func() any { return result.UpsertedID })
+	return result, err
 }
 
 // DeleteOne executes a delete command to delete at most one document matching the filter.
@@ -378,12 +513,18 @@ func (c Collection[T]) ReplaceOne(ctx context.Context, filter any, replacement T
 //
 // See [mongo.Collection.DeleteOne] for more details.
 func (c Collection[T]) DeleteOne(ctx context.Context, filter any, opts ...options.Lister[options.DeleteOneOptions]) (*mongo.DeleteResult, error) {
-	db, err := getDB(ctx)
+	filter, err := c.guardFilter(ctx, "DeleteOne", filter, true)
 	if err != nil {
 		return nil, err
 	}
-	collection := db.Collection(string(c))
-	return collection.DeleteOne(ctx, filter, opts...)
+	var result *mongo.DeleteResult
+	err = c.track(ctx, "DeleteOne", filter, nil, func(ctx context.Context) error {
+		return c.exec(ctx, "DeleteOne", opWrite, func(collection *mongo.Collection) (err error) {
+			result, err = collection.DeleteOne(ctx, filter, opts...)
+			return err
+		})
+	}, nil)
+	return result, err
 }
 
 // DeleteMany executes a delete command to delete all documents matching the filter.
@@ -393,12 +534,16 @@ func (c Collection[T]) DeleteOne(ctx context.Context, filter any, opts ...option
 //
 // See [mongo.Collection.DeleteMany] for more details.
 func (c Collection[T]) DeleteMany(ctx context.Context, filter any, opts ...options.Lister[options.DeleteManyOptions]) (*mongo.DeleteResult, error) {
-	db, err := getDB(ctx)
+	filter, err := c.guardFilter(ctx, "DeleteMany", filter, false)
 	if err != nil {
 		return nil, err
 	}
-	collection := db.Collection(string(c))
-	return collection.DeleteMany(ctx, filter, opts...)
+	var result *mongo.DeleteResult
+	err = c.exec(ctx, "DeleteMany", opWrite, func(collection *mongo.Collection) (err error) {
+		result, err = collection.DeleteMany(ctx, filter, opts...)
+		return err
+	})
+	return result, err
 }
 
 // CountDocuments executes a count command and returns the number of documents matching the filter.
@@ -412,12 +557,36 @@ func (c Collection[T]) DeleteMany(ctx context.Context, filter any, opts ...optio
 //
 // See [mongo.Collection.CountDocuments] for more details.
 func (c Collection[T]) CountDocuments(ctx context.Context, filter any, opts ...options.Lister[options.CountOptions]) (int64, error) {
-	db, err := getDB(ctx)
+	filter, err := c.guardFilter(ctx, "CountDocuments", filter, false)
 	if err != nil {
 		return 0, err
 	}
-	collection := db.Collection(string(c))
-	return collection.CountDocuments(ctx, filter, opts...)
+	var count int64
+	err = c.exec(ctx, "CountDocuments", opRead, func(collection *mongo.Collection) (err error) {
+		count, err = collection.CountDocuments(ctx, filter, opts...)
+		return err
+	})
+	return count, err
+}
+
+// EstimatedCount executes a count command and returns an estimate of the number of
+// documents in the collection using collection metadata.
+//
+// This is faster than CountDocuments because it does not scan the collection,
+// but it does not accept a filter and may be inaccurate after an unclean shutdown
+// or while orphaned documents exist in a sharded cluster.
+//
+// See [mongo.Collection.EstimatedDocumentCount] for more details.
+func (c Collection[T]) EstimatedCount(ctx context.Context, opts ...options.Lister[options.EstimatedDocumentCountOptions]) (int64, error) {
+	if _, _, ok, _ := c.tenant(ctx); ok {
+		return 0, fmt.Errorf("monarch: EstimatedCount cannot be scoped to the tenant of collection %q, use CountDocuments", string(c))
+	}
+	var count int64
+	err := c.exec(ctx, "EstimatedCount", opRead, func(collection *mongo.Collection) (err error) {
+		count, err = collection.EstimatedDocumentCount(ctx, opts...)
+		return err
+	})
+	return count, err
 }
 
 // Aggregate executes an aggregation pipeline and returns results as type T.
@@ -429,21 +598,25 @@ func (c Collection[T]) CountDocuments(ctx context.Context, filter any, opts ...o
 // use AggregateAs instead to specify the result type. An empty pipeline (bson.A{})
 // returns all documents in the collection.
 //
+// Values in the pipeline are not encrypted, so stages must not compare encrypted
+// fields, see WithEncryption. Encrypted fields of the results are decrypted.
+//
 // See [mongo.Collection.Aggregate] for more details.
 func (c Collection[T]) Aggregate(ctx context.Context, pipeline any, opts ...options.Lister[options.AggregateOptions]) ([]T, error) {
-	db, err := getDB(ctx)
+	pipeline, err := c.scopePipeline(ctx, pipeline)
 	if err != nil {
 		return nil, err
 	}
-	collection := db.Collection(string(c))
-	cursor, err := collection.Aggregate(ctx, pipeline, opts...)
-	if err != nil {
-		return nil, err
-	}
-	defer cursor.Close(ctx)
-
 	var results []T
-	if err = cursor.All(ctx, &results); err != nil {
+	err = c.exec(ctx, "Aggregate", opRead, func(collection *mongo.Collection) error {
+		cursor, err := collection.Aggregate(ctx, pipeline, opts...)
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+		return decodeAll(ctx, cursor, &results)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return results, nil
@@ -460,19 +633,20 @@ func (c Collection[T]) Aggregate(ctx context.Context, pipeline any, opts ...opti
 //
 // See [mongo.Collection.Aggregate] for more details.
 func AggregateAs[R, T any](ctx context.Context, c Collection[T], pipeline any, opts ...options.Lister[options.AggregateOptions]) ([]R, error) {
-	db, err := getDB(ctx)
+	pipeline, err := c.scopePipeline(ctx, pipeline)
 	if err != nil {
 		return nil, err
 	}
-	collection := db.Collection(string(c))
-	cursor, err := collection.Aggregate(ctx, pipeline, opts...)
-	if err != nil {
-		return nil, err
-	}
-	defer cursor.Close(ctx)
-
 	var results []R
-	if err = cursor.All(ctx, &results); err != nil {
+	err = c.exec(ctx, "AggregateAs", opRead, func(collection *mongo.Collection) error {
+		cursor, err := collection.Aggregate(ctx, pipeline, opts...)
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+		return decodeAll(ctx, cursor, &results)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return results, nil
@@ -488,19 +662,20 @@ func AggregateAs[R, T any](ctx context.Context, c Collection[T], pipeline any, o
 //
 // See [mongo.Collection.Find] for more details.
 func FindAs[R, T any](ctx context.Context, c Collection[T], filter any, opts ...options.Lister[options.FindOptions]) ([]R, error) {
-	db, err := getDB(ctx)
-	if err != nil {
-		return nil, err
-	}
-	collection := db.Collection(string(c))
-	cursor, err := collection.Find(ctx, filter, opts...)
+	filter, err := c.guardFilter(ctx, "FindAs", filter, false)
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(ctx)
-
 	var results []R
-	if err = cursor.All(ctx, &results); err != nil {
+	err = c.exec(ctx, "FindAs", opRead, func(collection *mongo.Collection) error {
+		cursor, err := collection.Find(ctx, filter, opts...)
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+		return decodeAll(ctx, cursor, &results)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return results, nil
@@ -517,14 +692,14 @@ func FindAs[R, T any](ctx context.Context, c Collection[T], filter any, opts ...
 // See [mongo.Collection.Find] for more details.
 func FindSeqAs[R, T any](ctx context.Context, c Collection[T], filter any, opts ...options.Lister[options.FindOptions]) iter.Seq2[R, error] {
 	return func(yield func(R, error) bool) {
-		db, err := getDB(ctx)
-		if err != nil {
-			var zero R
-			yield(zero, err)
-			return
+		var cursor *mongo.Cursor
+		filter, err := c.guardFilter(ctx, "FindSeqAs", filter, false)
+		if err == nil {
+			err = c.exec(ctx, "FindSeqAs", opRead, func(collection *mongo.Collection) (err error) {
+				cursor, err = collection.Find(ctx, filter, opts...)
+				return err
+			})
 		}
-		collection := db.Collection(string(c))
-		cursor, err := collection.Find(ctx, filter, opts...)
 		if err != nil {
 			var zero R
 			yield(zero, err)
@@ -534,15 +709,15 @@ func FindSeqAs[R, T any](ctx context.Context, c Collection[T], filter any, opts
 
 		for cursor.Next(ctx) {
 			var result R
-			err := cursor.Decode(&result)
-			if !yield(result, err) {
+			err := decodeCurrent(ctx, cursor, &result)
+			if !yield(result, wrapError(string(c), "FindSeqAs", err)) {
 				return
 			}
 		}
 
 		if err := cursor.Err(); err != nil {
 			var zero R
-			yield(zero, err)
+			yield(zero, wrapError(string(c), "FindSeqAs", err))
 		}
 	}
 }
@@ -559,27 +734,203 @@ func FindSeqAs[R, T any](ctx context.Context, c Collection[T], filter any, opts
 // See [mongo.Collection.FindOne] for more details.
 func FindOneAs[R, T any](ctx context.Context, c Collection[T], filter any, opts ...options.Lister[options.FindOneOptions]) (R, error) {
 	var result R
-	db, err := getDB(ctx)
+	filter, err := c.guardFilter(ctx, "FindOneAs", filter, true)
 	if err != nil {
 		return result, err
 	}
-	collection := db.Collection(string(c))
-	err = collection.FindOne(ctx, filter, opts...).Decode(&result)
+	err = c.exec(ctx, "FindOneAs", opRead, func(collection *mongo.Collection) error {
+		return decodeSingle(ctx, collection.FindOne(ctx, filter, opts...), &result)
+	})
 	return result, err
 }
 
+// DistinctAs executes a distinct command and returns the unique values of field as type V.
+//
+// The filter selects which documents are considered. An empty filter (bson.D{} or bson.M{})
+// considers all documents in the collection. If the field holds an array, each element
+// is treated as a separate value.
+//
+// If the stored values cannot be decoded as V, the returned error names the field and
+// the expected type and wraps the underlying decoding error.
+//
+// See [mongo.Collection.Distinct] for more details.
+func DistinctAs[V, T any](ctx context.Context, c Collection[T], field string, filter any, opts ...options.Lister[options.DistinctOptions]) ([]V, error) {
+	filter, err := c.prepareFilter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	var results []V
+	err = c.exec(ctx, "DistinctAs", opRead, func(collection *mongo.Collection) error {
+		res := collection.Distinct(ctx, field, filter, opts...)
+		if err := res.Err(); err != nil {
+			return err
+		}
+		if err := res.Decode(&results); err != nil {
+			return fmt.Errorf("cannot decode distinct values of %q as %T: %w", field, results, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// Distinct executes a distinct command and returns the unique values of a typed field.
+//
+// This behaves like DistinctAs with the value type inferred from the field declaration,
+// so the field must belong to the collection's document type. Returns an error if the
+// field is not a field of T holding values of type V:
+//
+//	var UserStatus monarch.Field[User, string] = "status"
+//
+//	statuses, err := monarch.Distinct(ctx, Users, UserStatus, bson.M{})
+//
+// See [mongo.Collection.Distinct] for more details.
+func Distinct[T, V any](ctx context.Context, c Collection[T], field Field[T, V], filter any, opts ...options.Lister[options.DistinctOptions]) ([]V, error) {
+	if err := field.validate(); err != nil {
+		return nil, err
+	}
+	return DistinctAs[V](ctx, c, string(field), filter, opts...)
+}
+
+// CreateIndexes executes a createIndexes command to create the given indexes on the collection.
+//
+// Creating an index that already exists with the same specification is a no-op,
+// so this is safe to call on every startup. Returns the names of the indexes.
+//
+// See [mongo.IndexView.CreateMany] for more details.
+func (c Collection[T]) CreateIndexes(ctx context.Context, models []mongo.IndexModel, opts ...options.Lister[options.CreateIndexesOptions]) ([]string, error) {
+	var names []string
+	err := c.exec(ctx, "CreateIndexes", opWrite, func(collection *mongo.Collection) (err error) {
+		names, err = collection.Indexes().CreateMany(ctx, models, opts...)
+		return err
+	})
+	return names, err
+}
+
+// indexed records the indexes created by EnsureIndexes.
+var indexed sync.Map // map[indexKey]bool
+
+type indexKey struct {
+	db         *mongo.Database
+	collection string
+	keys       string
+}
+
+// EnsureIndexes creates the indexes on the collection once per database, and is meant
+// to be called before using the collection in code paths that depend on the indexes.
+//
+// Later calls with the same database and index keys return without contacting the
+// server. Index options are not compared. Indexes are not created inside a transaction,
+// where the first call outside of one creates them instead.
+func (c Collection[T]) EnsureIndexes(ctx context.Context, models []mongo.IndexModel) error {
+	if inTransaction(ctx) {
+		return nil
+	}
+	db, err := getDB(ctx)
+	if err != nil {
+		return err
+	}
+	var keys []byte
+	for _, model := range models {
+		raw, err := bson.Marshal(bson.D{{Key: "keys", Value: model.Keys}})
+		if err != nil {
+			return err
+		}
+		keys = append(keys, raw...)
+	}
+	key := indexKey{db: db, collection: string(c), keys: string(keys)}
+	if _, ok := indexed.Load(key); ok {
+		return nil
+	}
+	if _, err := c.CreateIndexes(ctx, models); err != nil {
+		return err
+	}
+	indexed.Store(key, true)
+	return nil
+}
+
+// ChangeEvent is a change stream event for documents of type T.
+type ChangeEvent[T any] struct {
+	// ID is the resume token of the event. Use it with options.ChangeStream().SetResumeAfter
+	// to resume watching after this event.
+	ID bson.Raw `bson:"_id"`
+	// OperationType is the type of change, e.g. "insert", "update", "replace" or "delete".
+	OperationType string `bson:"operationType"`
+	// DocumentKey holds the _id (and shard key) of the changed document.
+	DocumentKey bson.Raw `bson:"documentKey"`
+	// FullDocument is the document after the change. It is set for inserts and replaces,
+	// and for updates when requested with options.ChangeStream().SetFullDocument.
+	FullDocument *T `bson:"fullDocument" monarch:"document"`
+	// ClusterTime is the time of the change on the server.
+	ClusterTime bson.Timestamp `bson:"clusterTime"`
+}
+
+// Watch opens a change stream on the collection and returns an iterator over its events.
+//
+// The pipeline parameter is typically bson.A containing stages that filter or transform
+// events (e.g., $match on operationType). An empty pipeline (bson.A{}) returns all events.
+// The iterator blocks waiting for new events until the context is done or iteration is
+// stopped. Change streams require a replica set or sharded cluster.
+//
+// On tenant-scoped collections, events are matched by the tenant field of their full
+// document, pre-image or document key. Update events only carry the tenant with
+// options.ChangeStream().SetFullDocument, and delete events only with pre-images or
+// a shard key containing the tenant field, so events without it are not returned.
+//
+// See [mongo.Collection.Watch] for more details.
+func (c Collection[T]) Watch(ctx context.Context, pipeline any, opts ...options.Lister[options.ChangeStreamOptions]) iter.Seq2[ChangeEvent[T], error] {
+	return func(yield func(ChangeEvent[T], error) bool) {
+		var stream *mongo.ChangeStream
+		pipeline, err := c.scopeWatch(ctx, pipeline)
+		if err != nil {
+			yield(ChangeEvent[T]{}, err)
+			return
+		}
+		err = c.exec(ctx, "Watch", opRead, func(collection *mongo.Collection) (err error) {
+			stream, err = collection.Watch(ctx, pipeline, opts...)
+			return err
+		})
+		if err != nil {
+			yield(ChangeEvent[T]{}, err)
+			return
+		}
+		defer stream.Close(context.WithoutCancel(ctx))
+
+		for stream.Next(ctx) {
+			var event ChangeEvent[T]
+			err := decodeRaw(ctx, stream.Current, &event)
+			if !yield(event, wrapError(string(c), "Watch", err)) {
+				return
+			}
+		}
+
+		if err := stream.Err(); err != nil {
+			yield(ChangeEvent[T]{}, wrapError(string(c), "Watch", err))
+		}
+	}
+}
+
 // WithTransaction executes a transaction with a typed return value.
 //
 // See [mongo.Session.WithTransaction] for more details.
 func WithTransaction[T any](ctx context.Context, session *mongo.Session, fn func(ctx context.Context) (result T, err error), opts ...options.Lister[options.TransactionOptions]) (T, error) {
+	// Cached lookups of the collections written in the transaction may have been
+	// refilled with the documents as they were before the commit.
+	pending := &pendingInvalidations{}
+	ctx = context.WithValue(ctx, pendingInvalidationsKey{}, pending)
 	val, err := session.WithTransaction(ctx, func(ctx context.Context) (any, error) {
 		return fn(ctx)
 	}, opts...)
+	pending.run()
 
 	if err != nil {
 		var zero T
 		return zero, err
 	}
 
-	return val.(T), nil
+	// val is nil when fn returns a nil interface value.
+	result, _ := val.(T)
+	return result, nil
 }
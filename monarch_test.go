@@ -5,6 +5,8 @@ import (
 	"errors"
 	"os"
 	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -39,7 +41,8 @@ func TestContextFunctions(t *testing.T) {
 }
 
 // setupTestDB connects to MongoDB and returns a database instance for testing.
-// It only skips the test if SKIP_INTEGRATION is set to any non-empty value.
+// It skips the test if SKIP_INTEGRATION is set to any non-empty value, or if MONGO_URI
+// is not set and no server answers on localhost.
 // Returns the database and a cleanup function that should be deferred.
 func setupTestDB(t *testing.T) (*mongo.Database, func()) {
 	t.Helper()
@@ -52,21 +55,18 @@ func setupTestDB(t *testing.T) (*mongo.Database, func()) {
 	if uri == "" {
 		uri = "mongodb://localhost:27017"
 	}
+	if err := pingServer(uri); err != nil {
+		if os.Getenv("MONGO_URI") == "" {
+			t.Skipf("skipping integration tests (no MongoDB at %s: %v)", uri, err)
+		}
+		t.Fatalf("failed to connect to MongoDB: %v", err)
+	}
 
 	client, err := mongo.Connect(options.Client().ApplyURI(uri).SetServerSelectionTimeout(5 * time.Second))
 	if err != nil {
 		t.Fatalf("failed to create MongoDB client: %v", err)
 	}
 
-	// Ping to verify connectivity - use a temporary context just for the ping
-	pingCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	if err := client.Ping(pingCtx, nil); err != nil {
-		client.Disconnect(context.Background())
-		t.Fatalf("failed to connect to MongoDB: %v", err)
-	}
-
 	db := client.Database("monarch_test_db")
 
 	cleanup := func() {
@@ -78,6 +78,46 @@ func setupTestDB(t *testing.T) (*mongo.Database, func()) {
 	return db, cleanup
 }
 
+var (
+	pingOnce sync.Once
+	pingErr  error
+)
+
+// pingServer pings the server at uri once per test binary, so that when no server
+// is running each test is skipped without waiting for server selection again.
+func pingServer(uri string) error {
+	pingOnce.Do(func() {
+		client, err := mongo.Connect(options.Client().ApplyURI(uri).SetServerSelectionTimeout(5 * time.Second))
+		if err != nil {
+			pingErr = err
+			return
+		}
+		defer client.Disconnect(context.Background())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		pingErr = client.Ping(ctx, nil)
+	})
+	return pingErr
+}
+
+// requireReplicaSet skips the test unless db is served by a replica set or a sharded
+// cluster, which change streams and transactions require.
+func requireReplicaSet(t *testing.T, db *mongo.Database) {
+	t.Helper()
+
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	if err := db.RunCommand(context.Background(), bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		t.Fatalf("hello failed: %v", err)
+	}
+	if hello.SetName == "" && hello.Msg != "isdbgrid" {
+		t.Skip("skipping test requiring a replica set (server is standalone)")
+	}
+}
+
 // cleanupCollection removes all documents from a collection.
 // Use this at the beginning of each test to ensure a clean state.
 func cleanupCollection[T any](t *testing.T, ctx context.Context, c Collection[T]) {
@@ -92,6 +132,9 @@ type User struct {
 	Age  int    `bson:"age"`
 }
 
+// DocumentID implements Document for the ID-aware helpers.
+func (u User) DocumentID() string { return u.ID }
+
 // Users is the test collection.
 var Users Collection[User] = "users"
 
@@ -834,6 +877,220 @@ func TestFindSeqEarlyTermination(t *testing.T) {
 	}
 }
 
+func TestEstimatedCount(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := WithContext(context.Background(), db)
+
+	cleanupCollection(t, ctx, Users)
+
+	users := []User{
+		{ID: "ec1", Name: "A", Age: 20},
+		{ID: "ec2", Name: "B", Age: 30},
+	}
+	_, err := Users.InsertMany(ctx, users)
+	if err != nil {
+		t.Fatalf("InsertMany failed: %v", err)
+	}
+
+	count, err := Users.EstimatedCount(ctx)
+	if err != nil {
+		t.Fatalf("EstimatedCount failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("expected estimated count 2, got %d", count)
+	}
+}
+
+func TestDistinct(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := WithContext(context.Background(), db)
+
+	cleanupCollection(t, ctx, Users)
+
+	users := []User{
+		{ID: "d1", Name: "Alice", Age: 20},
+		{ID: "d2", Name: "Bob", Age: 30},
+		{ID: "d3", Name: "Alice", Age: 40},
+	}
+	_, err := Users.InsertMany(ctx, users)
+	if err != nil {
+		t.Fatalf("InsertMany failed: %v", err)
+	}
+
+	t.Run("DistinctAs", func(t *testing.T) {
+		names, err := DistinctAs[string](ctx, Users, "name", bson.M{})
+		if err != nil {
+			t.Fatalf("DistinctAs failed: %v", err)
+		}
+		slices.Sort(names)
+		if !slices.Equal(names, []string{"Alice", "Bob"}) {
+			t.Errorf("expected [Alice Bob], got %v", names)
+		}
+	})
+
+	t.Run("DistinctAs with filter", func(t *testing.T) {
+		ages, err := DistinctAs[int](ctx, Users, "age", bson.M{"name": "Alice"})
+		if err != nil {
+			t.Fatalf("DistinctAs failed: %v", err)
+		}
+		slices.Sort(ages)
+		if !slices.Equal(ages, []int{20, 40}) {
+			t.Errorf("expected [20 40], got %v", ages)
+		}
+	})
+
+	t.Run("DistinctAs with mismatched type", func(t *testing.T) {
+		_, err := DistinctAs[bool](ctx, Users, "name", bson.M{})
+		if err == nil {
+			t.Fatal("expected decode error, got nil")
+		}
+	})
+
+	t.Run("Distinct with typed field", func(t *testing.T) {
+		var UserName Field[User, string] = "name"
+		names, err := Distinct(ctx, Users, UserName, bson.M{"age": bson.M{"$gte": 30}})
+		if err != nil {
+			t.Fatalf("Distinct failed: %v", err)
+		}
+		slices.Sort(names)
+		if !slices.Equal(names, []string{"Alice", "Bob"}) {
+			t.Errorf("expected [Alice Bob], got %v", names)
+		}
+	})
+}
+
+func TestFieldValidate(t *testing.T) {
+	type Address struct {
+		City string `bson:"city"`
+	}
+	type Account struct {
+		Name      string            `bson:"name"`
+		Tags      []string          `bson:"tags"`
+		Addresses []*Address        `bson:"addresses"`
+		Labels    map[string]string `bson:"labels"`
+		Extra     any               `bson:"extra"`
+	}
+
+	valid := []error{
+		Field[Account, string]("name").validate(),
+		Field[Account, string]("tags").validate(),
+		Field[Account, []string]("tags").validate(),
+		Field[Account, string]("addresses.city").validate(),
+		Field[Account, int]("labels.anything").validate(),
+		Field[Account, any]("name").validate(),
+		Field[Account, bool]("extra").validate(),
+	}
+	for i, err := range valid {
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+		}
+	}
+	if err := Field[Account, int]("name").validate(); err == nil || !strings.Contains(err.Error(), `field "name" has type string`) {
+		t.Errorf("expected a type mismatch, got %v", err)
+	}
+	if err := Field[Account, string]("addresses.zip").validate(); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected a missing field, got %v", err)
+	}
+	if _, err := Distinct(context.Background(), Users, Field[User, bool]("name"), bson.M{}); err == nil || errors.Is(err, ErrNoDatabase) {
+		t.Errorf("expected Distinct to reject a mismatched field before running, got %v", err)
+	}
+}
+
+func TestCreateIndexes(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := WithContext(context.Background(), db)
+
+	models := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "name", Value: 1}}, Options: options.Index().SetName("name_idx")},
+	}
+	names, err := Users.CreateIndexes(ctx, models)
+	if err != nil {
+		t.Fatalf("CreateIndexes failed: %v", err)
+	}
+	if !slices.Equal(names, []string{"name_idx"}) {
+		t.Errorf("expected [name_idx], got %v", names)
+	}
+
+	// Creating the same index again is a no-op.
+	if _, err := Users.CreateIndexes(ctx, models); err != nil {
+		t.Errorf("CreateIndexes again failed: %v", err)
+	}
+}
+
+func TestEnsureIndexes(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := WithContext(context.Background(), db)
+
+	cleanupCollection(t, ctx, Users)
+
+	ageIndex := []mongo.IndexModel{{Keys: bson.D{{Key: "age", Value: 1}}}}
+	if err := Users.EnsureIndexes(ctx, ageIndex); err != nil {
+		t.Fatalf("EnsureIndexes failed: %v", err)
+	}
+	if err := db.Collection(string(Users)).Indexes().DropOne(ctx, "age_1"); err != nil {
+		t.Fatalf("DropOne failed: %v", err)
+	}
+	// Indexes are only created once per database, even if dropped since.
+	if err := Users.EnsureIndexes(ctx, ageIndex); err != nil {
+		t.Fatalf("EnsureIndexes again failed: %v", err)
+	}
+	specs, err := db.Collection(string(Users)).Indexes().ListSpecifications(ctx)
+	if err != nil {
+		t.Fatalf("ListSpecifications failed: %v", err)
+	}
+	for _, spec := range specs {
+		if spec.Name == "age_1" {
+			t.Errorf("expected EnsureIndexes not to create the index again")
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	requireReplicaSet(t, db)
+	ctx, cancel := context.WithTimeout(WithContext(context.Background(), db), 10*time.Second)
+	defer cancel()
+
+	cleanupCollection(t, ctx, Users)
+
+	// The stream opens when iteration starts, so insert until the first event arrives.
+	inserted := make(chan struct{})
+	defer func() { <-inserted }()
+	defer cancel()
+	go func() {
+		defer close(inserted)
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			if _, err := Users.InsertOne(ctx, User{Name: "Watched"}); err != nil && ctx.Err() == nil {
+				t.Errorf("InsertOne failed: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	pipeline := bson.A{bson.M{"$match": bson.M{"operationType": "insert"}}}
+	for event, err := range Users.Watch(ctx, pipeline) {
+		if err != nil {
+			t.Fatalf("Watch failed: %v", err)
+		}
+		if event.OperationType != "insert" || event.FullDocument == nil || event.FullDocument.Name != "Watched" {
+			t.Errorf("expected insert of Watched, got %+v", event)
+		}
+		return
+	}
+	t.Fatal("expected an insert event before the timeout")
+}
+
 func TestErrorHandlingNoDatabaseInContext(t *testing.T) {
 	ctxNoDB := context.Background()
 
@@ -890,6 +1147,34 @@ func TestErrorHandlingNoDatabaseInContext(t *testing.T) {
 				return err
 			},
 		},
+		{
+			name: "Exists",
+			fn: func() error {
+				_, err := Users.Exists(ctxNoDB, bson.M{})
+				return err
+			},
+		},
+		{
+			name: "EstimatedCount",
+			fn: func() error {
+				_, err := Users.EstimatedCount(ctxNoDB)
+				return err
+			},
+		},
+		{
+			name: "DistinctAs",
+			fn: func() error {
+				_, err := DistinctAs[string](ctxNoDB, Users, "name", bson.M{})
+				return err
+			},
+		},
+		{
+			name: "Lock",
+			fn: func() error {
+				_, err := Lock(ctxNoDB, "lock", time.Second)
+				return err
+			},
+		},
 	}
 
 	for _, tt := range tests {
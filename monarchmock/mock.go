@@ -0,0 +1,289 @@
+// Package monarchmock provides mock implementations of the monarch.Repository and
+// monarch.Projector interfaces for unit tests that should not need a database.
+//
+// Expectations are declared with On, optionally restricted to calls with matching
+// arguments, and return canned results:
+//
+//	users := monarchmock.New[User](t)
+//	users.On("FindOne").WithFilter(bson.M{"_id": "user123"}).Return(User{ID: "user123"}, nil)
+//	users.On("UpdateOne").Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+//
+//	service := UserService{users: users}
+//
+// Arguments are matched by their BSON encoding, ignoring the order of document keys,
+// so bson.M and bson.D arguments with the same content match. Calls without a matching
+// expectation fail the test and return ErrUnexpectedCall, and expectations that were
+// not met fail the test when it ends.
+package monarchmock
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eriicafes/monarch"
+	"github.com/eriicafes/monarch/internal/bsonequal"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// ErrUnexpectedCall is returned by mocked operations called without a matching expectation.
+var ErrUnexpectedCall = errors.New("monarchmock: unexpected call")
+
+// Args holds the arguments of a mocked call. Only the arguments of the called
+// operation are set; options are not recorded.
+type Args struct {
+	// Filter is the filter of the operation.
+	Filter any
+	// Update is the update of UpdateOne, UpdateMany, FindOneAndUpdate and UpsertWith.
+	Update any
+	// Document is the document written by InsertOne, ReplaceOne, FindOneAndReplace and
+	// Upsert, or the slice of documents written by InsertMany and UpsertMany.
+	Document any
+	// Pipeline is the pipeline of Aggregate, ExplainAggregate and Watch.
+	Pipeline any
+	// ID is the _id of History, AsOf and Revert.
+	ID any
+	// Field is the field of Distinct.
+	Field string
+	// Keys are the key fields of UpsertMany.
+	Keys []string
+	// Models are the index models of CreateIndexes.
+	Models []mongo.IndexModel
+	// Version is the version of Revert.
+	Version int
+	// Time is the time of AsOf.
+	Time time.Time
+	// Verbosity is the verbosity of ExplainFind and ExplainAggregate.
+	Verbosity monarch.ExplainVerbosity
+}
+
+// Matcher matches an argument by a custom condition. Pass a Matcher to the With
+// methods of Call instead of a value to match arguments that are not known exactly.
+type Matcher func(arg any) bool
+
+// Anything matches any argument.
+var Anything Matcher = func(any) bool { return true }
+
+// Mock records calls and matches them against expectations.
+// It is embedded in Repository and Projector.
+type Mock struct {
+	t            testing.TB
+	mu           sync.Mutex
+	expectations []*Call
+	calls        []recordedCall
+}
+
+type recordedCall struct {
+	method string
+	args   Args
+}
+
+func newMock(t testing.TB) Mock {
+	return Mock{t: t}
+}
+
+// On declares an expectation of a call to the named method, such as "FindOne".
+//
+// By default the expectation must be met at least once and matches any number of calls.
+// Expectations are matched in the order they were declared.
+func (m *Mock) On(method string) *Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	call := &Call{mock: m, method: method}
+	m.expectations = append(m.expectations, call)
+	return call
+}
+
+// Calls returns the arguments of the calls made to the named method, in order.
+func (m *Mock) Calls(method string) []Args {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var args []Args
+	for _, call := range m.calls {
+		if call.method == method {
+			args = append(args, call.args)
+		}
+	}
+	return args
+}
+
+// AssertExpectations fails the test if an expectation was not met.
+// It is called automatically when the test ends.
+func (m *Mock) AssertExpectations() {
+	m.t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, call := range m.expectations {
+		switch {
+		case call.times > 0 && call.calls != call.times:
+			m.t.Errorf("monarchmock: expected %s to be called %d times, got %d", call, call.times, call.calls)
+		case call.times == 0 && !call.optional && call.calls == 0:
+			m.t.Errorf("monarchmock: expected %s to be called", call)
+		}
+	}
+}
+
+// called records a call and returns the matching expectation, or nil if there is none.
+func (m *Mock) called(method string, args Args) *Call {
+	m.t.Helper()
+	m.mu.Lock()
+	m.calls = append(m.calls, recordedCall{method: method, args: args})
+	var match *Call
+	for _, call := range m.expectations {
+		if call.matches(method, args) {
+			match = call
+			match.calls++
+			break
+		}
+	}
+	m.mu.Unlock()
+
+	if match == nil {
+		m.t.Errorf("monarchmock: unexpected call to %s with %+v", method, args)
+		return nil
+	}
+	if match.run != nil {
+		match.run(args)
+	}
+	return match
+}
+
+// Call is an expectation of a call to a mocked method.
+type Call struct {
+	mock     *Mock
+	method   string
+	matchers []argMatcher
+	returns  []any
+	run      func(Args)
+	times    int
+	optional bool
+	calls    int
+}
+
+type argMatcher struct {
+	name  string
+	get   func(Args) any
+	value any
+}
+
+func (c *Call) with(name string, get func(Args) any, value any) *Call {
+	c.mock.mu.Lock()
+	defer c.mock.mu.Unlock()
+	c.matchers = append(c.matchers, argMatcher{name: name, get: get, value: value})
+	return c
+}
+
+// WithFilter restricts the expectation to calls with the given filter.
+func (c *Call) WithFilter(filter any) *Call {
+	return c.with("filter", func(a Args) any { return a.Filter }, filter)
+}
+
+// WithUpdate restricts the expectation to calls with the given update.
+func (c *Call) WithUpdate(update any) *Call {
+	return c.with("update", func(a Args) any { return a.Update }, update)
+}
+
+// WithDocument restricts the expectation to calls writing the given document,
+// or the given slice of documents for InsertMany and UpsertMany.
+func (c *Call) WithDocument(doc any) *Call {
+	return c.with("document", func(a Args) any { return a.Document }, doc)
+}
+
+// WithPipeline restricts the expectation to calls with the given pipeline.
+func (c *Call) WithPipeline(pipeline any) *Call {
+	return c.with("pipeline", func(a Args) any { return a.Pipeline }, pipeline)
+}
+
+// WithID restricts the expectation to calls with the given _id.
+func (c *Call) WithID(id any) *Call {
+	return c.with("id", func(a Args) any { return a.ID }, id)
+}
+
+// Return sets the results of the call, in the order of the results of the method,
+// e.g. Return(user, nil) for FindOne. Nil results return the zero value. FindSeq,
+// Tail and Watch return a slice of the values to yield and the error to yield last.
+func (c *Call) Return(results ...any) *Call {
+	c.mock.mu.Lock()
+	defer c.mock.mu.Unlock()
+	c.returns = results
+	return c
+}
+
+// Run sets a function called with the arguments of each matching call, before it returns.
+func (c *Call) Run(fn func(Args)) *Call {
+	c.mock.mu.Lock()
+	defer c.mock.mu.Unlock()
+	c.run = fn
+	return c
+}
+
+// Times restricts the expectation to n calls, and requires exactly n calls.
+func (c *Call) Times(n int) *Call {
+	c.mock.mu.Lock()
+	defer c.mock.mu.Unlock()
+	c.times = n
+	return c
+}
+
+// Once is equivalent to Times(1).
+func (c *Call) Once() *Call {
+	return c.Times(1)
+}
+
+// Maybe allows the expectation not to be met.
+func (c *Call) Maybe() *Call {
+	c.mock.mu.Lock()
+	defer c.mock.mu.Unlock()
+	c.optional = true
+	return c
+}
+
+func (c *Call) String() string {
+	s := c.method
+	for _, m := range c.matchers {
+		s += fmt.Sprintf(" with %s %v", m.name, m.value)
+	}
+	return s
+}
+
+// matches reports whether the expectation matches a call. It is called with the mock locked.
+func (c *Call) matches(method string, args Args) bool {
+	if c.method != method || (c.times > 0 && c.calls >= c.times) {
+		return false
+	}
+	return !slices.ContainsFunc(c.matchers, func(m argMatcher) bool {
+		return !matchArg(m.value, m.get(args))
+	})
+}
+
+// matchArg reports whether the actual argument matches the expected value or Matcher.
+func matchArg(expected, actual any) bool {
+	if matcher, ok := expected.(Matcher); ok {
+		return matcher(actual)
+	}
+	return bsonequal.Values(expected, actual)
+}
+
+// result returns the i-th result of call as type V, or the zero value.
+func result[V any](call *Call, i int) V {
+	var zero V
+	if call == nil || i >= len(call.returns) || call.returns[i] == nil {
+		return zero
+	}
+	v, ok := call.returns[i].(V)
+	if !ok {
+		call.mock.t.Errorf("monarchmock: result %d of %s is %T, expected %T", i, call.method, call.returns[i], zero)
+	}
+	return v
+}
+
+// errorResult returns the i-th result of call as an error, or ErrUnexpectedCall if there is no call.
+func errorResult(call *Call, i int) error {
+	if call == nil {
+		return ErrUnexpectedCall
+	}
+	return result[error](call, i)
+}
@@ -0,0 +1,151 @@
+package monarchmock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/eriicafes/monarch"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type User struct {
+	ID   string `bson:"_id"`
+	Name string `bson:"name"`
+}
+
+// recorder is a testing.TB recording failures instead of failing the test.
+type recorder struct {
+	testing.TB
+	errors   []string
+	cleanups []func()
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Cleanup(fn func()) {
+	r.cleanups = append(r.cleanups, fn)
+}
+
+func (r *recorder) finish() {
+	for _, fn := range r.cleanups {
+		fn()
+	}
+}
+
+// UserService depends on the repository interface.
+type UserService struct {
+	users monarch.Repository[User]
+}
+
+func (s UserService) Rename(ctx context.Context, id, name string) error {
+	result, err := s.users.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"name": name}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func TestRepository(t *testing.T) {
+	ctx := context.Background()
+	users := New[User](t)
+	users.On("FindOne").WithFilter(bson.M{"_id": "u1"}).Return(User{ID: "u1", Name: "Ada"}, nil)
+	users.On("FindOne").Return(nil, mongo.ErrNoDocuments)
+	users.On("UpdateOne").
+		WithFilter(bson.D{{Key: "_id", Value: "u1"}}).
+		WithUpdate(bson.D{{Key: "$set", Value: bson.D{{Key: "name", Value: "Grace"}}}}).
+		Return(&mongo.UpdateResult{MatchedCount: 1}, nil).
+		Once()
+
+	// Filters match by content, whatever their Go type and key order.
+	user, err := users.FindOne(ctx, bson.D{{Key: "_id", Value: "u1"}})
+	if err != nil || user.Name != "Ada" {
+		t.Errorf("expected Ada, got %+v, %v", user, err)
+	}
+	if _, err := users.FindOne(ctx, bson.M{"_id": "u2"}); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Errorf("expected ErrNoDocuments, got %v", err)
+	}
+
+	service := UserService{users: users}
+	if err := service.Rename(ctx, "u1", "Grace"); err != nil {
+		t.Errorf("Rename failed: %v", err)
+	}
+
+	calls := users.Calls("UpdateOne")
+	if len(calls) != 1 || calls[0].Update == nil {
+		t.Errorf("expected 1 recorded UpdateOne call, got %+v", calls)
+	}
+}
+
+func TestRepositorySeq(t *testing.T) {
+	users := New[User](t)
+	failure := errors.New("cursor failed")
+	users.On("FindSeq").WithFilter(Anything).Return([]User{{ID: "u1"}, {ID: "u2"}}, failure)
+
+	var ids []string
+	var last error
+	for user, err := range users.FindSeq(context.Background(), bson.M{}) {
+		if err != nil {
+			last = err
+			break
+		}
+		ids = append(ids, user.ID)
+	}
+	if len(ids) != 2 || last != failure {
+		t.Errorf("expected 2 users and the error, got %v, %v", ids, last)
+	}
+}
+
+func TestUnexpectedCall(t *testing.T) {
+	r := &recorder{TB: t}
+	users := New[User](r)
+	users.On("InsertOne").WithDocument(User{ID: "u1"}).Return(&mongo.InsertOneResult{InsertedID: "u1"}, nil)
+	users.On("DeleteOne").Once()
+
+	if _, err := users.InsertOne(context.Background(), User{ID: "u2"}); !errors.Is(err, ErrUnexpectedCall) {
+		t.Errorf("expected ErrUnexpectedCall, got %v", err)
+	}
+	r.finish()
+
+	// One failure for the unexpected call, and one for each unmet expectation.
+	if len(r.errors) != 3 {
+		t.Errorf("expected 3 failures, got %q", r.errors)
+	}
+}
+
+func TestWrongResultType(t *testing.T) {
+	r := &recorder{TB: t}
+	users := New[User](r)
+	users.On("CountDocuments").Return(3, nil)
+
+	if count, err := users.CountDocuments(context.Background(), bson.M{}); count != 0 || err != nil {
+		t.Errorf("expected zero count, got %d, %v", count, err)
+	}
+	if len(r.errors) != 1 {
+		t.Errorf("expected a failure for the int result, got %q", r.errors)
+	}
+}
+
+func TestProjector(t *testing.T) {
+	type Stats struct {
+		Count int `bson:"count"`
+	}
+	stats := NewProjector[Stats](t)
+	pipeline := bson.A{bson.D{{Key: "$count", Value: "count"}}}
+	stats.On("Aggregate").WithPipeline(pipeline).Return([]Stats{{Count: 2}}, nil)
+
+	var projector monarch.Projector[Stats] = stats
+	results, err := projector.Aggregate(context.Background(), pipeline)
+	if err != nil || len(results) != 1 || results[0].Count != 2 {
+		t.Errorf("expected count 2, got %+v, %v", results, err)
+	}
+}
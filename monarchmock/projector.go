@@ -0,0 +1,55 @@
+package monarchmock
+
+import (
+	"context"
+	"iter"
+	"testing"
+
+	"github.com/eriicafes/monarch"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Projector is a mock implementation of monarch.Projector.
+type Projector[R any] struct {
+	Mock
+}
+
+var _ monarch.Projector[any] = (*Projector[any])(nil)
+
+// NewProjector returns a mock projector of results of type R. Its expectations are
+// asserted when the test ends.
+func NewProjector[R any](t testing.TB) *Projector[R] {
+	p := &Projector[R]{Mock: newMock(t)}
+	t.Cleanup(p.AssertExpectations)
+	return p
+}
+
+// Find returns the results of the expectation: []R and error.
+func (p *Projector[R]) Find(ctx context.Context, filter any, opts ...options.Lister[options.FindOptions]) ([]R, error) {
+	call := p.called("Find", Args{Filter: filter})
+	return result[[]R](call, 0), errorResult(call, 1)
+}
+
+// FindSeq yields the results of the expectation: []R and error.
+func (p *Projector[R]) FindSeq(ctx context.Context, filter any, opts ...options.Lister[options.FindOptions]) iter.Seq2[R, error] {
+	call := p.called("FindSeq", Args{Filter: filter})
+	return seq(result[[]R](call, 0), errorResult(call, 1))
+}
+
+// FindOne returns the results of the expectation: R and error.
+func (p *Projector[R]) FindOne(ctx context.Context, filter any, opts ...options.Lister[options.FindOneOptions]) (R, error) {
+	call := p.called("FindOne", Args{Filter: filter})
+	return result[R](call, 0), errorResult(call, 1)
+}
+
+// Aggregate returns the results of the expectation: []R and error.
+func (p *Projector[R]) Aggregate(ctx context.Context, pipeline any, opts ...options.Lister[options.AggregateOptions]) ([]R, error) {
+	call := p.called("Aggregate", Args{Pipeline: pipeline})
+	return result[[]R](call, 0), errorResult(call, 1)
+}
+
+// Distinct returns the results of the expectation: []R and error.
+func (p *Projector[R]) Distinct(ctx context.Context, field string, filter any, opts ...options.Lister[options.DistinctOptions]) ([]R, error) {
+	call := p.called("Distinct", Args{Field: field, Filter: filter})
+	return result[[]R](call, 0), errorResult(call, 1)
+}
@@ -0,0 +1,239 @@
+package monarchmock
+
+import (
+	"context"
+	"iter"
+	"testing"
+	"time"
+
+	"github.com/eriicafes/monarch"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Repository is a mock implementation of monarch.Repository.
+type Repository[T any] struct {
+	Mock
+}
+
+var _ monarch.Repository[any] = (*Repository[any])(nil)
+
+// New returns a mock repository of documents of type T. Its expectations are
+// asserted when the test ends.
+func New[T any](t testing.TB) *Repository[T] {
+	r := &Repository[T]{Mock: newMock(t)}
+	t.Cleanup(r.AssertExpectations)
+	return r
+}
+
+// Find returns the results of the expectation: []T and error.
+func (r *Repository[T]) Find(ctx context.Context, filter any, opts ...options.Lister[options.FindOptions]) ([]T, error) {
+	call := r.called("Find", Args{Filter: filter})
+	return result[[]T](call, 0), errorResult(call, 1)
+}
+
+// FindSeq yields the results of the expectation: []T and error.
+func (r *Repository[T]) FindSeq(ctx context.Context, filter any, opts ...options.Lister[options.FindOptions]) iter.Seq2[T, error] {
+	call := r.called("FindSeq", Args{Filter: filter})
+	return seq(result[[]T](call, 0), errorResult(call, 1))
+}
+
+// FindOne returns the results of the expectation: T and error.
+func (r *Repository[T]) FindOne(ctx context.Context, filter any, opts ...options.Lister[options.FindOneOptions]) (T, error) {
+	call := r.called("FindOne", Args{Filter: filter})
+	return result[T](call, 0), errorResult(call, 1)
+}
+
+// FindOneAndUpdate returns the results of the expectation: T and error.
+func (r *Repository[T]) FindOneAndUpdate(ctx context.Context, filter any, update any, opts ...options.Lister[options.FindOneAndUpdateOptions]) (T, error) {
+	call := r.called("FindOneAndUpdate", Args{Filter: filter, Update: update})
+	return result[T](call, 0), errorResult(call, 1)
+}
+
+// FindOneAndReplace returns the results of the expectation: T and error.
+func (r *Repository[T]) FindOneAndReplace(ctx context.Context, filter any, replacement T, opts ...options.Lister[options.FindOneAndReplaceOptions]) (T, error) {
+	call := r.called("FindOneAndReplace", Args{Filter: filter, Document: replacement})
+	return result[T](call, 0), errorResult(call, 1)
+}
+
+// FindOneAndDelete returns the results of the expectation: T and error.
+func (r *Repository[T]) FindOneAndDelete(ctx context.Context, filter any, opts ...options.Lister[options.FindOneAndDeleteOptions]) (T, error) {
+	call := r.called("FindOneAndDelete", Args{Filter: filter})
+	return result[T](call, 0), errorResult(call, 1)
+}
+
+// InsertOne returns the results of the expectation: *mongo.InsertOneResult and error.
+func (r *Repository[T]) InsertOne(ctx context.Context, value T, opts ...options.Lister[options.InsertOneOptions]) (*mongo.InsertOneResult, error) {
+	call := r.called("InsertOne", Args{Document: value})
+	return result[*mongo.InsertOneResult](call, 0), errorResult(call, 1)
+}
+
+// InsertMany returns the results of the expectation: *mongo.InsertManyResult and error.
+func (r *Repository[T]) InsertMany(ctx context.Context, values []T, opts ...options.Lister[options.InsertManyOptions]) (*mongo.InsertManyResult, error) {
+	call := r.called("InsertMany", Args{Document: values})
+	return result[*mongo.InsertManyResult](call, 0), errorResult(call, 1)
+}
+
+// UpdateOne returns the results of the expectation: *mongo.UpdateResult and error.
+func (r *Repository[T]) UpdateOne(ctx context.Context, filter any, update any, opts ...options.Lister[options.UpdateOneOptions]) (*mongo.UpdateResult, error) {
+	call := r.called("UpdateOne", Args{Filter: filter, Update: update})
+	return result[*mongo.UpdateResult](call, 0), errorResult(call, 1)
+}
+
+// UpdateMany returns the results of the expectation: *mongo.UpdateResult and error.
+func (r *Repository[T]) UpdateMany(ctx context.Context, filter any, update any, opts ...options.Lister[options.UpdateManyOptions]) (*mongo.UpdateResult, error) {
+	call := r.called("UpdateMany", Args{Filter: filter, Update: update})
+	return result[*mongo.UpdateResult](call, 0), errorResult(call, 1)
+}
+
+// ReplaceOne returns the results of the expectation: *mongo.UpdateResult and error.
+func (r *Repository[T]) ReplaceOne(ctx context.Context, filter any, replacement T, opts ...options.Lister[options.ReplaceOptions]) (*mongo.UpdateResult, error) {
+	call := r.called("ReplaceOne", Args{Filter: filter, Document: replacement})
+	return result[*mongo.UpdateResult](call, 0), errorResult(call, 1)
+}
+
+// DeleteOne returns the results of the expectation: *mongo.DeleteResult and error.
+func (r *Repository[T]) DeleteOne(ctx context.Context, filter any, opts ...options.Lister[options.DeleteOneOptions]) (*mongo.DeleteResult, error) {
+	call := r.called("DeleteOne", Args{Filter: filter})
+	return result[*mongo.DeleteResult](call, 0), errorResult(call, 1)
+}
+
+// DeleteMany returns the results of the expectation: *mongo.DeleteResult and error.
+func (r *Repository[T]) DeleteMany(ctx context.Context, filter any, opts ...options.Lister[options.DeleteManyOptions]) (*mongo.DeleteResult, error) {
+	call := r.called("DeleteMany", Args{Filter: filter})
+	return result[*mongo.DeleteResult](call, 0), errorResult(call, 1)
+}
+
+// Upsert returns the results of the expectation: T, bool and error.
+func (r *Repository[T]) Upsert(ctx context.Context, filter any, replacement T) (T, bool, error) {
+	call := r.called("Upsert", Args{Filter: filter, Document: replacement})
+	return result[T](call, 0), result[bool](call, 1), errorResult(call, 2)
+}
+
+// UpsertWith returns the results of the expectation: T, bool and error.
+func (r *Repository[T]) UpsertWith(ctx context.Context, filter any, update any) (T, bool, error) {
+	call := r.called("UpsertWith", Args{Filter: filter, Update: update})
+	return result[T](call, 0), result[bool](call, 1), errorResult(call, 2)
+}
+
+// UpsertMany returns the results of the expectation: *mongo.BulkWriteResult and error.
+func (r *Repository[T]) UpsertMany(ctx context.Context, values []T, keys []string, opts ...options.Lister[options.BulkWriteOptions]) (*mongo.BulkWriteResult, error) {
+	call := r.called("UpsertMany", Args{Document: values, Keys: keys})
+	return result[*mongo.BulkWriteResult](call, 0), errorResult(call, 1)
+}
+
+// CountDocuments returns the results of the expectation: int64 and error.
+func (r *Repository[T]) CountDocuments(ctx context.Context, filter any, opts ...options.Lister[options.CountOptions]) (int64, error) {
+	call := r.called("CountDocuments", Args{Filter: filter})
+	return result[int64](call, 0), errorResult(call, 1)
+}
+
+// EstimatedCount returns the results of the expectation: int64 and error.
+func (r *Repository[T]) EstimatedCount(ctx context.Context, opts ...options.Lister[options.EstimatedDocumentCountOptions]) (int64, error) {
+	call := r.called("EstimatedCount", Args{})
+	return result[int64](call, 0), errorResult(call, 1)
+}
+
+// Exists returns the results of the expectation: bool and error.
+func (r *Repository[T]) Exists(ctx context.Context, filter any) (bool, error) {
+	call := r.called("Exists", Args{Filter: filter})
+	return result[bool](call, 0), errorResult(call, 1)
+}
+
+// Aggregate returns the results of the expectation: []T and error.
+func (r *Repository[T]) Aggregate(ctx context.Context, pipeline any, opts ...options.Lister[options.AggregateOptions]) ([]T, error) {
+	call := r.called("Aggregate", Args{Pipeline: pipeline})
+	return result[[]T](call, 0), errorResult(call, 1)
+}
+
+// ExplainFind returns the results of the expectation: *monarch.ExplainResult and error.
+func (r *Repository[T]) ExplainFind(ctx context.Context, verbosity monarch.ExplainVerbosity, filter any, opts ...options.Lister[options.FindOptions]) (*monarch.ExplainResult, error) {
+	call := r.called("ExplainFind", Args{Filter: filter, Verbosity: verbosity})
+	return result[*monarch.ExplainResult](call, 0), errorResult(call, 1)
+}
+
+// ExplainAggregate returns the results of the expectation: *monarch.ExplainResult and error.
+func (r *Repository[T]) ExplainAggregate(ctx context.Context, verbosity monarch.ExplainVerbosity, pipeline any, opts ...options.Lister[options.AggregateOptions]) (*monarch.ExplainResult, error) {
+	call := r.called("ExplainAggregate", Args{Pipeline: pipeline, Verbosity: verbosity})
+	return result[*monarch.ExplainResult](call, 0), errorResult(call, 1)
+}
+
+// CreateIndexes returns the results of the expectation: []string and error.
+func (r *Repository[T]) CreateIndexes(ctx context.Context, models []mongo.IndexModel, opts ...options.Lister[options.CreateIndexesOptions]) ([]string, error) {
+	call := r.called("CreateIndexes", Args{Models: models})
+	return result[[]string](call, 0), errorResult(call, 1)
+}
+
+// EnsureIndexes returns the result of the expectation: error.
+func (r *Repository[T]) EnsureIndexes(ctx context.Context, models []mongo.IndexModel) error {
+	call := r.called("EnsureIndexes", Args{Models: models})
+	return errorResult(call, 0)
+}
+
+// EnsureTimeSeries returns the result of the expectation: error.
+func (r *Repository[T]) EnsureTimeSeries(ctx context.Context, ts monarch.TimeSeries) error {
+	call := r.called("EnsureTimeSeries", Args{})
+	return errorResult(call, 0)
+}
+
+// EnsureCapped returns the result of the expectation: error.
+func (r *Repository[T]) EnsureCapped(ctx context.Context, capped monarch.Capped) error {
+	call := r.called("EnsureCapped", Args{})
+	return errorResult(call, 0)
+}
+
+// Tail yields the results of the expectation: []T and error.
+func (r *Repository[T]) Tail(ctx context.Context, filter any) iter.Seq2[T, error] {
+	call := r.called("Tail", Args{Filter: filter})
+	return seq(result[[]T](call, 0), errorResult(call, 1))
+}
+
+// Watch yields the results of the expectation: []monarch.ChangeEvent[T] and error.
+func (r *Repository[T]) Watch(ctx context.Context, pipeline any, opts ...options.Lister[options.ChangeStreamOptions]) iter.Seq2[monarch.ChangeEvent[T], error] {
+	call := r.called("Watch", Args{Pipeline: pipeline})
+	return seq(result[[]monarch.ChangeEvent[T]](call, 0), errorResult(call, 1))
+}
+
+// Invalidate records the call.
+func (r *Repository[T]) Invalidate(ctx context.Context) {
+	r.called("Invalidate", Args{})
+}
+
+// InvalidateOnChange returns the result of the expectation: error.
+func (r *Repository[T]) InvalidateOnChange(ctx context.Context) error {
+	call := r.called("InvalidateOnChange", Args{})
+	return errorResult(call, 0)
+}
+
+// History returns the results of the expectation: []monarch.Version[T] and error.
+func (r *Repository[T]) History(ctx context.Context, id any) ([]monarch.Version[T], error) {
+	call := r.called("History", Args{ID: id})
+	return result[[]monarch.Version[T]](call, 0), errorResult(call, 1)
+}
+
+// AsOf returns the results of the expectation: T and error.
+func (r *Repository[T]) AsOf(ctx context.Context, id any, t time.Time) (T, error) {
+	call := r.called("AsOf", Args{ID: id, Time: t})
+	return result[T](call, 0), errorResult(call, 1)
+}
+
+// Revert returns the results of the expectation: T and error.
+func (r *Repository[T]) Revert(ctx context.Context, id any, version int) (T, error) {
+	call := r.called("Revert", Args{ID: id, Version: version})
+	return result[T](call, 0), errorResult(call, 1)
+}
+
+// seq returns an iterator yielding values and then err if not nil.
+func seq[V any](values []V, err error) iter.Seq2[V, error] {
+	return func(yield func(V, error) bool) {
+		for _, v := range values {
+			if !yield(v, nil) {
+				return
+			}
+		}
+		if err != nil {
+			var zero V
+			yield(zero, err)
+		}
+	}
+}
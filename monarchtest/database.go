@@ -0,0 +1,78 @@
+package monarchtest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/eriicafes/monarch"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// maxDatabaseName is the maximum length of a database name.
+const maxDatabaseName = 63
+
+// TempDatabase returns a database on a real server that is used only by the test, and
+// dropped when the test ends. Its name is derived from config.Database, the test name
+// and a random suffix, so parallel tests and packages never share a database.
+func TempDatabase(t testing.TB, config Config) *mongo.Database {
+	t.Helper()
+	uri := serverURI(config)
+	client, err := mongo.Connect(options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("monarchtest: cannot connect to %s: %v", uri, err)
+	}
+	db := client.Database(tempDatabaseName(config.Database, t.Name()))
+	t.Cleanup(func() {
+		if err := db.Drop(context.Background()); err != nil {
+			t.Errorf("monarchtest: cannot drop database %s: %v", db.Name(), err)
+		}
+		if err := client.Disconnect(context.Background()); err != nil {
+			t.Errorf("monarchtest: disconnect failed: %v", err)
+		}
+	})
+	return db
+}
+
+// TempContext returns the test context with a TempDatabase attached with monarch.WithContext.
+func TempContext(t testing.TB, config Config) context.Context {
+	t.Helper()
+	return monarch.WithContext(t.Context(), TempDatabase(t, config))
+}
+
+// tempDatabaseName returns a unique database name for a test, keeping only the
+// characters allowed in database names on every platform.
+func tempDatabaseName(prefix, test string) string {
+	if prefix == "" {
+		prefix = "monarchtest"
+	}
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, prefix+"_"+test)
+	if max := maxDatabaseName - 1 - 2*len(suffix); len(name) > max {
+		name = name[:max]
+	}
+	return name + "_" + hex.EncodeToString(suffix)
+}
+
+// serverURI returns the connection string of the server used by config.
+func serverURI(config Config) string {
+	if config.URI != "" {
+		return config.URI
+	}
+	if uri := os.Getenv("MONGO_URI"); uri != "" {
+		return uri
+	}
+	return "mongodb://localhost:27017"
+}
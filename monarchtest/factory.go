@@ -0,0 +1,157 @@
+package monarchtest
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/eriicafes/monarch"
+)
+
+// Factory builds documents of a collection for tests, and inserts them through the
+// collection with the database in context.
+//
+// Documents start from a builder called with a sequence number, unique across the
+// factory and starting at 1, to derive distinct field values. Named traits and
+// per-call overrides modify them, and associations reference documents built by
+// other factories:
+//
+//	var UserFactory = monarchtest.NewFactory(Users, func(n int) User {
+//	    return User{ID: fmt.Sprintf("user%d", n), Email: fmt.Sprintf("user%d@example.com", n)}
+//	}).Trait("admin", func(u *User) { u.Role = "admin" })
+//
+//	var PostFactory = monarchtest.Associate(
+//	    monarchtest.NewFactory(Posts, func(n int) Post { return Post{ID: bson.NewObjectID()} }),
+//	    UserFactory, func(p *Post, author User) { p.AuthorID = author.ID },
+//	)
+//
+//	admin, err := UserFactory.With("admin").Create(ctx)
+//	post, err := PostFactory.Create(ctx, func(p *Post) { p.Title = "Hello" })
+//
+// The builder should set the _id of documents, so that created documents are returned
+// complete. Factories are safe for concurrent use once configured.
+type Factory[T any] struct {
+	collection   monarch.Collection[T]
+	build        func(n int) T
+	seq          *atomic.Int64
+	traits       map[string]func(*T)
+	associations []func(ctx context.Context, doc *T, create bool) error
+	with         []func(*T)
+}
+
+// NewFactory returns a Factory of documents of the collection, built by build.
+func NewFactory[T any](c monarch.Collection[T], build func(n int) T) *Factory[T] {
+	return &Factory[T]{
+		collection: c,
+		build:      build,
+		seq:        new(atomic.Int64),
+		traits:     make(map[string]func(*T)),
+	}
+}
+
+// Trait registers a named modification of documents, applied by With.
+// It returns f, and must not be called concurrently with other methods.
+func (f *Factory[T]) Trait(name string, fn func(*T)) *Factory[T] {
+	f.traits[name] = fn
+	return f
+}
+
+// With returns a factory applying the named traits to its documents, in order.
+// The returned factory shares the sequence of f, and uses its current traits and
+// associations.
+//
+// With panics if a trait is not registered.
+func (f *Factory[T]) With(traits ...string) *Factory[T] {
+	derived := *f
+	derived.with = append([]func(*T){}, f.with...)
+	for _, name := range traits {
+		fn, ok := f.traits[name]
+		if !ok {
+			panic(fmt.Sprintf("monarchtest: factory of %s has no trait %q", f.collection, name))
+		}
+		derived.with = append(derived.with, fn)
+	}
+	return &derived
+}
+
+// Associate registers an association of the documents of f with documents of other.
+// Each document built by f gets a new document built by other, passed to set to
+// reference it; when f creates documents, other creates the referenced documents
+// first. It returns f, and must not be called concurrently with other methods.
+func Associate[T, R any](f *Factory[T], other *Factory[R], set func(doc *T, ref R)) *Factory[T] {
+	f.associations = append(f.associations, func(ctx context.Context, doc *T, create bool) error {
+		var ref R
+		if create {
+			var err error
+			if ref, err = other.Create(ctx); err != nil {
+				return err
+			}
+		} else {
+			ref = other.Build()
+		}
+		set(doc, ref)
+		return nil
+	})
+	return f
+}
+
+// Build returns a new document without inserting it or its associations.
+// Associations, traits and overrides are applied in that order.
+func (f *Factory[T]) Build(overrides ...func(*T)) T {
+	doc, _ := f.make(context.Background(), false, overrides)
+	return doc
+}
+
+// Create builds a document and inserts it and its associations through the
+// collection with the database in ctx, and returns it.
+func (f *Factory[T]) Create(ctx context.Context, overrides ...func(*T)) (T, error) {
+	doc, err := f.make(ctx, true, overrides)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	if _, err := f.collection.InsertOne(ctx, doc); err != nil {
+		var zero T
+		return zero, err
+	}
+	return doc, nil
+}
+
+// CreateMany builds n documents and inserts them and their associations through the
+// collection with the database in ctx, and returns them. The overrides are applied
+// to every document.
+func (f *Factory[T]) CreateMany(ctx context.Context, n int, overrides ...func(*T)) ([]T, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	docs := make([]T, n)
+	for i := range docs {
+		doc, err := f.make(ctx, true, overrides)
+		if err != nil {
+			return nil, err
+		}
+		docs[i] = doc
+	}
+	if _, err := f.collection.InsertMany(ctx, docs); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// make builds a document, creating its associations if create is true.
+func (f *Factory[T]) make(ctx context.Context, create bool, overrides []func(*T)) (T, error) {
+	doc := f.build(int(f.seq.Add(1)))
+	for _, associate := range f.associations {
+		if err := associate(ctx, &doc, create); err != nil {
+			var zero T
+			return zero, err
+		}
+	}
+	for _, fn := range f.with {
+		fn(&doc)
+	}
+	for _, fn := range overrides {
+		fn(&doc)
+	}
+	return doc, nil
+}
@@ -0,0 +1,98 @@
+package monarchtest
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func newUserFactory() *Factory[User] {
+	return NewFactory(Users, func(n int) User {
+		return User{ID: fmt.Sprintf("user%d", n), Name: "User"}
+	}).Trait("alice", func(u *User) { u.Name = "Alice" })
+}
+
+func newPostFactory(users *Factory[User]) *Factory[Post] {
+	return Associate(NewFactory(Posts, func(n int) Post {
+		return Post{ID: bson.NewObjectID(), Views: n}
+	}), users, func(p *Post, author User) { p.Author = author.ID })
+}
+
+func TestFactoryBuild(t *testing.T) {
+	users := newUserFactory()
+
+	first, second := users.Build(), users.Build()
+	if first.ID != "user1" || second.ID != "user2" {
+		t.Errorf("expected sequential IDs, got %s and %s", first.ID, second.ID)
+	}
+
+	alice := users.With("alice").Build()
+	if alice.ID != "user3" || alice.Name != "Alice" {
+		t.Errorf("expected trait to apply with the shared sequence, got %+v", alice)
+	}
+
+	bob := users.With("alice").Build(func(u *User) { u.Name = "Bob" })
+	if bob.Name != "Bob" {
+		t.Errorf("expected override to apply after traits, got %+v", bob)
+	}
+
+	post := newPostFactory(users).Build()
+	if post.Author != "user5" || post.Views != 1 {
+		t.Errorf("expected association to build an author, got %+v", post)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected unknown trait to panic")
+		}
+	}()
+	users.With("unknown")
+}
+
+func TestFactoryCreate(t *testing.T) {
+	golden := filepath.Join(t.TempDir(), "factory.json")
+	insert := func(collection string) interaction {
+		return interaction{
+			Command: mustMarshal(t, bson.D{{Key: "insert", Value: collection}}),
+			Reply:   mustMarshal(t, bson.D{{Key: "n", Value: int32(1)}, {Key: "ok", Value: 1.0}}),
+		}
+	}
+	if err := writeGolden(golden, []interaction{insert("users"), insert("posts")}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := Context(t, golden, Config{Match: Loose})
+
+	users := newUserFactory()
+	post, err := newPostFactory(users).Create(ctx)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if post.Author != "user1" {
+		t.Errorf("expected created author user1, got %s", post.Author)
+	}
+}
+
+// TestFactoryCreateMany creates documents and their associations on a real server.
+// It is skipped like the other tests requiring a server, see requireServer.
+func TestFactoryCreateMany(t *testing.T) {
+	requireServer(t)
+	ctx := TempContext(t, Config{})
+
+	posts, err := newPostFactory(newUserFactory()).CreateMany(ctx, 3)
+	if err != nil {
+		t.Fatalf("CreateMany failed: %v", err)
+	}
+	if len(posts) != 3 {
+		t.Fatalf("expected 3 posts, got %d", len(posts))
+	}
+	for _, post := range posts {
+		if _, err := Users.FindOne(ctx, bson.M{"_id": post.Author}); err != nil {
+			t.Errorf("author %s of post %s not created: %v", post.Author, post.ID.Hex(), err)
+		}
+	}
+	if count, err := Posts.CountDocuments(ctx, bson.M{}); err != nil || count != 3 {
+		t.Errorf("expected 3 posts, got %d (%v)", count, err)
+	}
+}
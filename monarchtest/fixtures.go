@@ -0,0 +1,344 @@
+package monarchtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/eriicafes/monarch"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.yaml.in/yaml/v3"
+)
+
+// Fixtures loads fixture files into the collections registered with it.
+//
+// A fixture file maps collection names to lists of documents. YAML files (.yaml, .yml)
+// and extended JSON files (.json) are supported, and YAML values may also use extended
+// JSON forms such as {$oid: ...}:
+//
+//	users:
+//	  - _id: user123
+//	    name: Alice
+//	posts:
+//	  - _id: {$oid: "65a1f0c2e4b0a1b2c3d4e5f6"}
+//	    author: user123
+//	    created_at: 2024-01-15T10:00:00Z
+type Fixtures struct {
+	loaders map[string]fixtureLoader
+}
+
+// Fixture is a collection registered with Fixtures.
+type Fixture struct {
+	name   string
+	loader fixtureLoader
+}
+
+// fixtureLoader validates the documents of a collection and returns a function inserting them.
+type fixtureLoader func(docs []bson.Raw) (insert func(ctx context.Context) error, err error)
+
+// Register returns the Fixture of a collection. Fixture documents of the collection
+// are decoded into T, and inserted with InsertMany. Keys that are not fields of T,
+// which decoding would silently drop, make the document invalid.
+func Register[T any](c monarch.Collection[T]) Fixture {
+	return Fixture{
+		name: string(c),
+		loader: func(docs []bson.Raw) (func(ctx context.Context) error, error) {
+			values := make([]T, len(docs))
+			for i, doc := range docs {
+				if err := bson.Unmarshal(doc, &values[i]); err != nil {
+					return nil, fmt.Errorf("%s[%d]: %w", c, i, err)
+				}
+				if key := unknownKey(doc, reflect.TypeFor[T](), ""); key != "" {
+					return nil, fmt.Errorf("%s[%d]: unknown field %q", c, i, key)
+				}
+			}
+			return func(ctx context.Context) error {
+				if len(values) == 0 {
+					return nil
+				}
+				_, err := c.InsertMany(ctx, values)
+				return err
+			}, nil
+		},
+	}
+}
+
+var (
+	unmarshalerType      = reflect.TypeFor[bson.Unmarshaler]()
+	valueUnmarshalerType = reflect.TypeFor[bson.ValueUnmarshaler]()
+)
+
+// unknownKey returns the dotted path of the first key of doc that is not a field of
+// the type t, or "" if there is none. Embedded documents and arrays of documents are
+// checked against the types of their fields. Types that accept any key, such as maps,
+// structs with an inline map and types decoding themselves, are not checked.
+func unknownKey(doc bson.Raw, t reflect.Type, prefix string) string {
+	fields, ok := documentFields(t)
+	if !ok {
+		return ""
+	}
+	elems, err := doc.Elements()
+	if err != nil {
+		return ""
+	}
+	for _, elem := range elems {
+		path := prefix + elem.Key()
+		ft, ok := fields[elem.Key()]
+		if !ok {
+			return path
+		}
+		if key := unknownValueKey(elem.Value(), ft, path); key != "" {
+			return key
+		}
+	}
+	return ""
+}
+
+// unknownValueKey returns the first unknown key of the embedded documents of value,
+// decoded into the type t.
+func unknownValueKey(value bson.RawValue, t reflect.Type, path string) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch value.Type {
+	case bson.TypeEmbeddedDocument:
+		return unknownKey(value.Document(), t, path+".")
+	case bson.TypeArray:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			return ""
+		}
+		values, err := value.Array().Values()
+		if err != nil {
+			return ""
+		}
+		for i, v := range values {
+			if key := unknownValueKey(v, t.Elem(), fmt.Sprintf("%s.%d", path, i)); key != "" {
+				return key
+			}
+		}
+	}
+	return ""
+}
+
+// documentFields returns the types of the fields of the struct type t by document key,
+// following the bson package rules, and false if t accepts keys of any name.
+func documentFields(t reflect.Type) (map[string]reflect.Type, bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == reflect.TypeFor[time.Time]() || t.PkgPath() == unmarshalerType.PkgPath() {
+		return nil, false
+	}
+	if ptr := reflect.PointerTo(t); ptr.Implements(unmarshalerType) || ptr.Implements(valueUnmarshalerType) {
+		return nil, false
+	}
+	fields := make(map[string]reflect.Type)
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("bson"), ",")
+		if name == "-" {
+			continue
+		}
+		if slices.Contains(strings.Split(opts, ","), "inline") {
+			inner, ok := documentFields(f.Type)
+			if !ok {
+				return nil, false
+			}
+			maps.Copy(fields, inner)
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+	return fields, true
+}
+
+// NewFixtures returns Fixtures loading into the given collections.
+func NewFixtures(fixtures ...Fixture) *Fixtures {
+	f := &Fixtures{loaders: make(map[string]fixtureLoader, len(fixtures))}
+	for _, fixture := range fixtures {
+		f.loaders[fixture.name] = fixture.loader
+	}
+	return f
+}
+
+// Load inserts the documents of the fixture files into the database in ctx.
+//
+// All files are read and every document is validated by decoding it into the type of
+// its collection before anything is inserted, so an invalid fixture inserts nothing.
+// Collections are inserted in the order they first appear in the files.
+func (f *Fixtures) Load(ctx context.Context, paths ...string) error {
+	var inserts []func(ctx context.Context) error
+	for _, path := range paths {
+		collections, err := readFixtures(path)
+		if err != nil {
+			return err
+		}
+		for _, collection := range collections {
+			loader, ok := f.loaders[collection.name]
+			if !ok {
+				return fmt.Errorf("monarchtest: fixture file %s: collection %q is not registered", path, collection.name)
+			}
+			insert, err := loader(collection.docs)
+			if err != nil {
+				return fmt.Errorf("monarchtest: fixture file %s: invalid document %w", path, err)
+			}
+			inserts = append(inserts, insert)
+		}
+	}
+	for _, insert := range inserts {
+		if err := insert(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fixtureCollection is the list of documents of a collection in a fixture file.
+type fixtureCollection struct {
+	name string
+	docs []bson.Raw
+}
+
+// readFixtures reads a fixture file, in the format of its extension.
+func readFixtures(path string) ([]fixtureCollection, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		data, err = yamlToJSON(data)
+		if err != nil {
+			return nil, fmt.Errorf("monarchtest: invalid fixture file %s: %w", path, err)
+		}
+	case ".json":
+	default:
+		return nil, fmt.Errorf("monarchtest: unsupported fixture file %s, expected .yaml, .yml or .json", path)
+	}
+
+	collections, err := parseFixtures(data)
+	if err != nil {
+		return nil, fmt.Errorf("monarchtest: invalid fixture file %s: %w", path, err)
+	}
+	return collections, nil
+}
+
+// parseFixtures parses the extended JSON of a fixture file.
+func parseFixtures(data []byte) ([]fixtureCollection, error) {
+	var root bson.Raw
+	if err := bson.UnmarshalExtJSON(data, false, &root); err != nil {
+		return nil, err
+	}
+	elems, err := root.Elements()
+	if err != nil {
+		return nil, err
+	}
+	collections := make([]fixtureCollection, 0, len(elems))
+	for _, elem := range elems {
+		array, ok := elem.Value().ArrayOK()
+		if !ok {
+			return nil, fmt.Errorf("collection %q is not a list of documents", elem.Key())
+		}
+		values, err := array.Values()
+		if err != nil {
+			return nil, err
+		}
+		collection := fixtureCollection{name: elem.Key(), docs: make([]bson.Raw, len(values))}
+		for i, value := range values {
+			doc, ok := value.DocumentOK()
+			if !ok {
+				return nil, fmt.Errorf("%s[%d] is not a document", elem.Key(), i)
+			}
+			collection.docs[i] = doc
+		}
+		collections = append(collections, collection)
+	}
+	return collections, nil
+}
+
+// yamlToJSON converts a YAML document to extended JSON, preserving the order of keys.
+// Timestamps are converted to dates.
+func yamlToJSON(data []byte) ([]byte, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := writeYAMLNode(&buf, &node); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeYAMLNode(buf *bytes.Buffer, node *yaml.Node) error {
+	switch node.Kind {
+	case 0:
+		buf.WriteString("{}")
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			buf.WriteString("{}")
+			return nil
+		}
+		return writeYAMLNode(buf, node.Content[0])
+	case yaml.AliasNode:
+		return writeYAMLNode(buf, node.Alias)
+	case yaml.MappingNode:
+		buf.WriteByte('{')
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key, err := json.Marshal(node.Content[i].Value)
+			if err != nil {
+				return err
+			}
+			buf.Write(key)
+			buf.WriteByte(':')
+			if err := writeYAMLNode(buf, node.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case yaml.SequenceNode:
+		buf.WriteByte('[')
+		for i, item := range node.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeYAMLNode(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case yaml.ScalarNode:
+		var value any
+		if err := node.Decode(&value); err != nil {
+			return err
+		}
+		if t, ok := value.(time.Time); ok {
+			value = map[string]string{"$date": t.UTC().Format(time.RFC3339Nano)}
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		buf.Write(data)
+	default:
+		return fmt.Errorf("line %d: unsupported YAML node", node.Line)
+	}
+	return nil
+}
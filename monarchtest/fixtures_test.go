@@ -0,0 +1,204 @@
+package monarchtest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eriicafes/monarch"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type Post struct {
+	ID        bson.ObjectID `bson:"_id"`
+	Author    string        `bson:"author"`
+	Views     int           `bson:"views"`
+	CreatedAt time.Time     `bson:"created_at"`
+}
+
+var Posts = monarch.Collection[Post]("posts")
+
+type Profile struct {
+	ID    string            `bson:"_id"`
+	Links []Link            `bson:"links"`
+	Extra map[string]string `bson:"extra"`
+}
+
+type Link struct {
+	URL string `bson:"url"`
+}
+
+var Profiles = monarch.Collection[Profile]("profiles")
+
+var fixtures = NewFixtures(Register(Users), Register(Posts), Register(Profiles))
+
+func writeFixture(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const yamlFixture = `
+users:
+  - _id: user123
+    name: Alice
+  - _id: user456
+    name: Bob
+posts:
+  - _id: {$oid: "65a1f0c2e4b0a1b2c3d4e5f6"}
+    author: user123
+    views: 3
+    created_at: 2024-01-15T10:00:00Z
+`
+
+const jsonFixture = `{
+  "users": [{"_id": "user123", "name": "Alice"}, {"_id": "user456", "name": "Bob"}],
+  "posts": [{
+    "_id": {"$oid": "65a1f0c2e4b0a1b2c3d4e5f6"},
+    "author": "user123",
+    "views": 3,
+    "created_at": {"$date": "2024-01-15T10:00:00Z"}
+  }]
+}`
+
+func TestReadFixtures(t *testing.T) {
+	for _, file := range []struct{ name, content string }{
+		{"fixtures.yaml", yamlFixture},
+		{"fixtures.json", jsonFixture},
+	} {
+		t.Run(file.name, func(t *testing.T) {
+			collections, err := readFixtures(writeFixture(t, file.name, file.content))
+			if err != nil {
+				t.Fatalf("readFixtures failed: %v", err)
+			}
+			if len(collections) != 2 || collections[0].name != "users" || collections[1].name != "posts" {
+				t.Fatalf("expected users and posts in order, got %+v", collections)
+			}
+			if len(collections[0].docs) != 2 {
+				t.Errorf("expected 2 users, got %d", len(collections[0].docs))
+			}
+			if key := collections[0].docs[1].Index(0).Key(); key != "_id" {
+				t.Errorf("expected key order to be preserved, got first key %q", key)
+			}
+
+			var post Post
+			if err := bson.Unmarshal(collections[1].docs[0], &post); err != nil {
+				t.Fatalf("decode post failed: %v", err)
+			}
+			wantID, _ := bson.ObjectIDFromHex("65a1f0c2e4b0a1b2c3d4e5f6")
+			wantTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+			if post.ID != wantID || post.Views != 3 || !post.CreatedAt.Equal(wantTime) {
+				t.Errorf("unexpected post %+v", post)
+			}
+		})
+	}
+
+	t.Run("unsupported extension", func(t *testing.T) {
+		_, err := readFixtures(writeFixture(t, "fixtures.txt", ""))
+		if err == nil || !strings.Contains(err.Error(), "unsupported fixture file") {
+			t.Errorf("expected unsupported fixture file error, got %v", err)
+		}
+	})
+
+	t.Run("collection is not a list", func(t *testing.T) {
+		_, err := readFixtures(writeFixture(t, "fixtures.yaml", "users:\n  _id: user123\n"))
+		if err == nil || !strings.Contains(err.Error(), "not a list of documents") {
+			t.Errorf("expected not a list error, got %v", err)
+		}
+	})
+}
+
+func TestFixturesValidation(t *testing.T) {
+	// Validation fails before the database is used, so none is needed.
+	ctx := context.Background()
+
+	t.Run("unregistered collection", func(t *testing.T) {
+		err := fixtures.Load(ctx, writeFixture(t, "fixtures.yaml", "comments:\n  - _id: c1\n"))
+		if err == nil || !strings.Contains(err.Error(), `collection "comments" is not registered`) {
+			t.Errorf("expected unregistered collection error, got %v", err)
+		}
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		err := fixtures.Load(ctx, writeFixture(t, "fixtures.yaml", "users:\n  - _id: user1\n    nmae: Alice\n"))
+		if err == nil || !strings.Contains(err.Error(), `users[0]: unknown field "nmae"`) {
+			t.Errorf("expected unknown field error, got %v", err)
+		}
+	})
+
+	t.Run("known fields", func(t *testing.T) {
+		collections, err := readFixtures(writeFixture(t, "fixtures.yaml", yamlFixture+"profiles:\n  - _id: p1\n    links: [{url: a}]\n    extra: {any: key}\n"))
+		if err != nil {
+			t.Fatalf("readFixtures failed: %v", err)
+		}
+		for _, collection := range collections {
+			if _, err := fixtures.loaders[collection.name](collection.docs); err != nil {
+				t.Errorf("expected %s to be valid, got %v", collection.name, err)
+			}
+		}
+	})
+
+	t.Run("unknown embedded field", func(t *testing.T) {
+		err := fixtures.Load(ctx, writeFixture(t, "fixtures.yaml", "profiles:\n  - _id: p1\n    links:\n      - url: a\n      - ulr: b\n"))
+		if err == nil || !strings.Contains(err.Error(), `profiles[0]: unknown field "links.1.ulr"`) {
+			t.Errorf("expected unknown embedded field error, got %v", err)
+		}
+	})
+
+	t.Run("document does not decode", func(t *testing.T) {
+		err := fixtures.Load(ctx, writeFixture(t, "fixtures.yaml", "posts:\n  - _id: not-an-object-id\n"))
+		if err == nil || !strings.Contains(err.Error(), "invalid document posts[0]") {
+			t.Errorf("expected invalid document error, got %v", err)
+		}
+	})
+}
+
+func TestTempDatabaseName(t *testing.T) {
+	name := tempDatabaseName("", "TestFoo/sub test."+strings.Repeat("x", 100))
+	if len(name) > maxDatabaseName {
+		t.Errorf("expected name of at most %d characters, got %d", maxDatabaseName, len(name))
+	}
+	if !strings.HasPrefix(name, "monarchtest_TestFoo_sub_test_") {
+		t.Errorf("unexpected name %q", name)
+	}
+	if other := tempDatabaseName("", "TestFoo"); other == tempDatabaseName("", "TestFoo") {
+		t.Errorf("expected unique names, got %q twice", other)
+	}
+}
+
+// TestLoadFixtures loads fixtures into isolated databases.
+// It is skipped like the other tests requiring a server, see requireServer.
+func TestLoadFixtures(t *testing.T) {
+	requireServer(t)
+	yamlPath := writeFixture(t, "fixtures.yaml", yamlFixture)
+
+	for _, name := range []string{"first", "second"} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ctx := TempContext(t, Config{})
+			if err := fixtures.Load(ctx, yamlPath); err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			count, err := Users.CountDocuments(ctx, bson.M{})
+			if err != nil {
+				t.Fatalf("CountDocuments failed: %v", err)
+			}
+			if count != 2 {
+				t.Errorf("expected 2 users, got %d", count)
+			}
+			post, err := Posts.FindOne(ctx, bson.M{"author": "user123"})
+			if err != nil {
+				t.Fatalf("FindOne failed: %v", err)
+			}
+			if post.Views != 3 {
+				t.Errorf("expected 3 views, got %d", post.Views)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+// Package monarchtest provides helpers for testing code built on monarch.
+//
+// Session records the commands sent to a real MongoDB server and the server's
+// replies into a golden file, and replays them later without a server, so tests
+// run fast and deterministically in CI:
+//
+//	func TestCreateUser(t *testing.T) {
+//	    ctx := monarchtest.Context(t, "testdata/create_user.json", monarchtest.Config{})
+//
+//	    _, err := Users.InsertOne(ctx, User{ID: "user123", Name: "Alice"})
+//	    // ...
+//	}
+//
+// Run the tests with MONARCHTEST_RECORD=1 and a server available to record or
+// re-record the golden files, and without it to replay them.
+//
+// Tests that need a real server can instead use TempDatabase, a database dropped
+// when the test ends, and seed it from fixture files with Fixtures.
+package monarchtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/eriicafes/monarch"
+	"github.com/eriicafes/monarch/internal/bsonequal"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/x/bsonx/bsoncore"
+)
+
+// Match selects how replayed commands are matched with recorded commands.
+type Match int
+
+const (
+	// Strict requires commands to be replayed in the recorded order, each equal to the
+	// recorded command, and every recorded command to be replayed.
+	Strict Match = iota
+	// Loose matches each command with the next unused recorded command of the same name
+	// on the same collection, whatever its arguments. Use it for tests that send values
+	// which change between runs, such as generated ObjectIDs or the current time.
+	Loose
+)
+
+// Config configures a Session.
+type Config struct {
+	// URI is the connection string of the server used when recording and by
+	// TempDatabase. Defaults to the MONGO_URI environment variable, or
+	// mongodb://localhost:27017.
+	URI string
+	// Database is the name of the database, or the prefix of the name of a
+	// TempDatabase. Defaults to "monarchtest".
+	Database string
+	// Match selects how replayed commands are matched. Defaults to Strict.
+	Match Match
+	// Record, if true, records the golden file instead of replaying it. Recording is
+	// also enabled by setting the MONARCHTEST_RECORD environment variable.
+	Record bool
+}
+
+// Session returns a database that records to or replays from the golden file.
+//
+// When recording, the database is connected to a real server, and every command
+// sent through it and its reply are written to the golden file as extended JSON
+// when the test ends. When replaying, the database is connected to an in-process
+// server answering each command with the reply recorded for it; commands that do
+// not match a recorded command fail the test.
+//
+// Session metadata that changes between runs, such as session IDs and cluster
+// times, is not recorded. Documents are compared ignoring the order of their keys,
+// since bson.M filters are encoded in random order.
+func Session(t testing.TB, golden string, config Config) *mongo.Database {
+	t.Helper()
+	if config.Database == "" {
+		config.Database = "monarchtest"
+	}
+	if config.Record || os.Getenv("MONARCHTEST_RECORD") != "" {
+		return record(t, golden, config)
+	}
+	return replay(t, golden, config)
+}
+
+// Context returns the test context with the database of a Session attached with monarch.WithContext.
+func Context(t testing.TB, golden string, config Config) context.Context {
+	t.Helper()
+	return monarch.WithContext(t.Context(), Session(t, golden, config))
+}
+
+// interaction is a recorded command and its reply.
+type interaction struct {
+	Command bson.Raw
+	Reply   bson.Raw
+}
+
+// goldenInteraction is an interaction as stored in a golden file.
+type goldenInteraction struct {
+	Command json.RawMessage `json:"command"`
+	Reply   json.RawMessage `json:"reply"`
+}
+
+// readGolden reads the interactions of a golden file.
+func readGolden(path string) ([]interaction, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var stored []goldenInteraction
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("monarchtest: invalid golden file %s: %w", path, err)
+	}
+	interactions := make([]interaction, len(stored))
+	for i, s := range stored {
+		var cmd, reply bson.Raw
+		if err := bson.UnmarshalExtJSON(s.Command, true, &cmd); err != nil {
+			return nil, fmt.Errorf("monarchtest: invalid command %d in golden file %s: %w", i, path, err)
+		}
+		if err := bson.UnmarshalExtJSON(s.Reply, true, &reply); err != nil {
+			return nil, fmt.Errorf("monarchtest: invalid reply %d in golden file %s: %w", i, path, err)
+		}
+		interactions[i] = interaction{Command: cmd, Reply: reply}
+	}
+	return interactions, nil
+}
+
+// writeGolden writes the interactions to a golden file as canonical extended JSON,
+// which preserves the BSON types of values.
+func writeGolden(path string, interactions []interaction) error {
+	stored := make([]goldenInteraction, len(interactions))
+	for i, in := range interactions {
+		cmd, err := bson.MarshalExtJSON(in.Command, true, false)
+		if err != nil {
+			return err
+		}
+		reply, err := bson.MarshalExtJSON(in.Reply, true, false)
+		if err != nil {
+			return err
+		}
+		stored[i] = goldenInteraction{Command: cmd, Reply: reply}
+	}
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// volatileCommandFields are command fields that change between runs. The read
+// preference depends on the topology of the server, which differs when replaying.
+var volatileCommandFields = []string{"lsid", "$clusterTime", "txnNumber", "maxTimeMS", "$readPreference"}
+
+// volatileReplyFields are reply fields that change between runs.
+var volatileReplyFields = []string{"$clusterTime", "operationTime"}
+
+// without returns a copy of doc without the given top-level fields.
+func without(doc bson.Raw, fields []string) bson.Raw {
+	elems, err := doc.Elements()
+	if err != nil {
+		return slices.Clone(doc)
+	}
+	idx, out := bsoncore.AppendDocumentStart(nil)
+	for _, elem := range elems {
+		if !slices.Contains(fields, elem.Key()) {
+			out = append(out, elem...)
+		}
+	}
+	out, _ = bsoncore.AppendDocumentEnd(out, idx)
+	return bson.Raw(out)
+}
+
+// commandName returns the name of a command, which is its first key.
+func commandName(cmd bson.Raw) string {
+	elem, err := cmd.IndexErr(0)
+	if err != nil {
+		return ""
+	}
+	return elem.Key()
+}
+
+// commandKey identifies the kind of a command for loose matching: its name and collection.
+func commandKey(cmd bson.Raw) string {
+	elem, err := cmd.IndexErr(0)
+	if err != nil {
+		return ""
+	}
+	collection, ok := elem.Value().StringValueOK()
+	if !ok {
+		collection, _ = cmd.Lookup("collection").StringValueOK()
+	}
+	return strings.TrimSpace(elem.Key() + " " + collection)
+}
+
+// sameCommand reports whether a replayed command equals a recorded command.
+func sameCommand(replayed, recorded bson.Raw) bool {
+	return bsonequal.Documents(replayed, recorded)
+}
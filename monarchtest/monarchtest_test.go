@@ -0,0 +1,308 @@
+package monarchtest
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eriicafes/monarch"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type User struct {
+	ID   string `bson:"_id"`
+	Name string `bson:"name"`
+}
+
+var Users = monarch.Collection[User]("users")
+
+var (
+	pingOnce sync.Once
+	pingErr  error
+)
+
+// requireServer skips the test if SKIP_INTEGRATION is set to any non-empty value, or
+// if MONGO_URI is not set and no server answers on localhost. The server is pinged
+// once per test binary, so that when none is running each test is skipped immediately.
+func requireServer(t *testing.T) {
+	t.Helper()
+
+	if os.Getenv("SKIP_INTEGRATION") != "" {
+		t.Skip("skipping integration tests (SKIP_INTEGRATION is set)")
+	}
+	uri := serverURI(Config{})
+	pingOnce.Do(func() {
+		client, err := mongo.Connect(options.Client().ApplyURI(uri).SetServerSelectionTimeout(5 * time.Second))
+		if err != nil {
+			pingErr = err
+			return
+		}
+		defer client.Disconnect(context.Background())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		pingErr = client.Ping(ctx, nil)
+	})
+	if pingErr != nil {
+		if os.Getenv("MONGO_URI") == "" {
+			t.Skipf("skipping integration tests (no MongoDB at %s: %v)", uri, pingErr)
+		}
+		t.Fatalf("failed to connect to MongoDB: %v", pingErr)
+	}
+}
+
+// recorderTB is a testing.TB recording failures instead of failing the test.
+type recorderTB struct {
+	testing.TB
+	errors   []string
+	cleanups []func()
+}
+
+func (r *recorderTB) Helper() {}
+
+func (r *recorderTB) Error(args ...any) {
+	r.errors = append(r.errors, fmt.Sprint(args...))
+}
+
+func (r *recorderTB) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recorderTB) Failed() bool {
+	return len(r.errors) > 0
+}
+
+func (r *recorderTB) Cleanup(fn func()) {
+	r.cleanups = append(r.cleanups, fn)
+}
+
+func (r *recorderTB) finish() {
+	for _, fn := range slices.Backward(r.cleanups) {
+		fn()
+	}
+}
+
+func mustMarshal(t *testing.T, v any) bson.Raw {
+	t.Helper()
+	b, err := bson.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// writeTestGolden writes a golden file with a find and an insert on users.
+func writeTestGolden(t *testing.T) string {
+	t.Helper()
+	golden := filepath.Join(t.TempDir(), "users.json")
+	interactions := []interaction{
+		{
+			Command: mustMarshal(t, bson.D{
+				{Key: "find", Value: "users"},
+				{Key: "filter", Value: bson.D{{Key: "name", Value: "Alice"}}},
+				{Key: "$db", Value: "monarchtest"},
+			}),
+			Reply: mustMarshal(t, bson.D{
+				{Key: "cursor", Value: bson.D{
+					{Key: "firstBatch", Value: bson.A{bson.D{{Key: "_id", Value: "user123"}, {Key: "name", Value: "Alice"}}}},
+					{Key: "id", Value: int64(0)},
+					{Key: "ns", Value: "monarchtest.users"},
+				}},
+				{Key: "ok", Value: 1.0},
+			}),
+		},
+		{
+			Command: mustMarshal(t, bson.D{
+				{Key: "insert", Value: "users"},
+				{Key: "ordered", Value: true},
+				{Key: "$db", Value: "monarchtest"},
+				{Key: "documents", Value: bson.A{bson.D{{Key: "_id", Value: "user456"}, {Key: "name", Value: "Bob"}}}},
+			}),
+			Reply: mustMarshal(t, bson.D{{Key: "n", Value: int32(1)}, {Key: "ok", Value: 1.0}}),
+		},
+	}
+	if err := writeGolden(golden, interactions); err != nil {
+		t.Fatal(err)
+	}
+	return golden
+}
+
+func TestReplay(t *testing.T) {
+	t.Run("strict replays commands in order", func(t *testing.T) {
+		ctx := Context(t, writeTestGolden(t), Config{})
+
+		users, err := Users.Find(ctx, bson.M{"name": "Alice"})
+		if err != nil {
+			t.Fatalf("Find failed: %v", err)
+		}
+		if len(users) != 1 || users[0].ID != "user123" {
+			t.Errorf("unexpected users %+v", users)
+		}
+		if _, err := Users.InsertOne(ctx, User{ID: "user456", Name: "Bob"}); err != nil {
+			t.Fatalf("InsertOne failed: %v", err)
+		}
+	})
+
+	t.Run("strict fails on a command out of order", func(t *testing.T) {
+		rt := &recorderTB{TB: t}
+		ctx := monarch.WithContext(t.Context(), Session(rt, writeTestGolden(t), Config{}))
+
+		_, err := Users.InsertOne(ctx, User{ID: "user456", Name: "Bob"})
+		rt.finish()
+		if err == nil {
+			t.Error("expected InsertOne to fail")
+		}
+		if len(rt.errors) != 1 || !strings.Contains(rt.errors[0], "unexpected command") || !strings.Contains(rt.errors[0], `"find"`) {
+			t.Errorf("expected unexpected command error mentioning the expected find, got %v", rt.errors)
+		}
+	})
+
+	t.Run("strict fails on commands not replayed", func(t *testing.T) {
+		rt := &recorderTB{TB: t}
+		ctx := monarch.WithContext(t.Context(), Session(rt, writeTestGolden(t), Config{}))
+
+		if _, err := Users.Find(ctx, bson.M{"name": "Alice"}); err != nil {
+			t.Fatalf("Find failed: %v", err)
+		}
+		rt.finish()
+		if len(rt.errors) != 1 || !strings.Contains(rt.errors[0], "1 recorded commands were not replayed") {
+			t.Errorf("expected not replayed error, got %v", rt.errors)
+		}
+	})
+
+	t.Run("loose matches commands by name and collection", func(t *testing.T) {
+		rt := &recorderTB{TB: t}
+		ctx := monarch.WithContext(t.Context(), Session(rt, writeTestGolden(t), Config{Match: Loose}))
+
+		if _, err := Users.InsertOne(ctx, User{ID: "other", Name: "Carol"}); err != nil {
+			t.Fatalf("InsertOne failed: %v", err)
+		}
+		users, err := Users.Find(ctx, bson.M{"name": "Bob"})
+		if err != nil {
+			t.Fatalf("Find failed: %v", err)
+		}
+		if len(users) != 1 || users[0].ID != "user123" {
+			t.Errorf("unexpected users %+v", users)
+		}
+		if _, err := Users.Find(ctx, bson.M{}); err == nil {
+			t.Error("expected a second Find to fail")
+		}
+		rt.finish()
+		if len(rt.errors) != 1 {
+			t.Errorf("expected one unexpected command error, got %v", rt.errors)
+		}
+	})
+
+	t.Run("missing golden file fails", func(t *testing.T) {
+		rt := &fatalTB{recorderTB: recorderTB{TB: t}}
+		func() {
+			defer func() { recover() }()
+			Session(rt, filepath.Join(t.TempDir(), "missing.json"), Config{})
+		}()
+		if len(rt.errors) != 1 || !strings.Contains(rt.errors[0], "MONARCHTEST_RECORD") {
+			t.Errorf("expected missing golden file error, got %v", rt.errors)
+		}
+	})
+}
+
+// fatalTB is a recorderTB that stops the calling function on Fatal.
+type fatalTB struct {
+	recorderTB
+}
+
+func (r *fatalTB) Fatalf(format string, args ...any) {
+	r.Errorf(format, args...)
+	panic("fatal")
+}
+
+func TestParseMsg(t *testing.T) {
+	cmd := mustMarshal(t, bson.D{{Key: "insert", Value: "users"}, {Key: "$db", Value: "monarchtest"}})
+	doc1 := mustMarshal(t, bson.D{{Key: "_id", Value: "a"}})
+	doc2 := mustMarshal(t, bson.D{{Key: "_id", Value: "b"}})
+
+	body := binary.LittleEndian.AppendUint32(nil, flagMoreToCome)
+	body = append(body, 0)
+	body = append(body, cmd...)
+	sequence := append([]byte("documents\x00"), doc1...)
+	sequence = append(sequence, doc2...)
+	body = append(body, 1)
+	body = binary.LittleEndian.AppendUint32(body, uint32(4+len(sequence)))
+	body = append(body, sequence...)
+
+	got, moreToCome, err := parseMsg(body)
+	if err != nil {
+		t.Fatalf("parseMsg failed: %v", err)
+	}
+	if !moreToCome {
+		t.Error("expected moreToCome to be set")
+	}
+	want := mustMarshal(t, bson.D{
+		{Key: "insert", Value: "users"},
+		{Key: "$db", Value: "monarchtest"},
+		{Key: "documents", Value: bson.A{bson.D{{Key: "_id", Value: "a"}}, bson.D{{Key: "_id", Value: "b"}}}},
+	})
+	if !sameCommand(got, want) {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	if _, _, err := parseMsg(body[:len(body)-3]); err == nil {
+		t.Error("expected truncated message to fail")
+	}
+}
+
+func TestWithout(t *testing.T) {
+	cmd := mustMarshal(t, bson.D{
+		{Key: "find", Value: "users"},
+		{Key: "lsid", Value: bson.D{{Key: "id", Value: "session"}}},
+		{Key: "$db", Value: "monarchtest"},
+	})
+	got := without(cmd, volatileCommandFields)
+	want := mustMarshal(t, bson.D{{Key: "find", Value: "users"}, {Key: "$db", Value: "monarchtest"}})
+	if !sameCommand(got, want) {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if key := commandKey(got); key != "find users" {
+		t.Errorf("expected command key %q, got %q", "find users", key)
+	}
+}
+
+// TestRecordReplay records a session against a real server and replays it.
+// It is skipped like the other tests requiring a server, see requireServer.
+func TestRecordReplay(t *testing.T) {
+	requireServer(t)
+	golden := filepath.Join(t.TempDir(), "record.json")
+	run := func(t *testing.T, ctx context.Context) {
+		if _, err := Users.InsertOne(ctx, User{ID: "user123", Name: "Alice"}); err != nil {
+			t.Fatalf("InsertOne failed: %v", err)
+		}
+		user, err := Users.FindOne(ctx, bson.M{"_id": "user123"})
+		if err != nil {
+			t.Fatalf("FindOne failed: %v", err)
+		}
+		if user.Name != "Alice" {
+			t.Errorf("expected Alice, got %s", user.Name)
+		}
+		if _, err := Users.DeleteMany(ctx, bson.M{}); err != nil {
+			t.Fatalf("DeleteMany failed: %v", err)
+		}
+	}
+
+	t.Run("record", func(t *testing.T) {
+		run(t, Context(t, golden, Config{Database: "monarchtest_record", Record: true}))
+	})
+	if _, err := os.Stat(golden); err != nil {
+		t.Fatalf("golden file not written: %v", err)
+	}
+	t.Run("replay", func(t *testing.T) {
+		run(t, Context(t, golden, Config{Database: "monarchtest_record"}))
+	})
+}
@@ -0,0 +1,103 @@
+package monarchtest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/event"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/x/mongo/driver"
+)
+
+// unrecordedCommands are commands answered by the replay server without a recording.
+// endSessions is sent when the client disconnects, at a time that varies between runs.
+var unrecordedCommands = []string{"hello", "isMaster", "ismaster", "endSessions"}
+
+// recorder records the commands of a client from command monitoring events.
+type recorder struct {
+	mu           sync.Mutex
+	interactions []*interaction
+	pending      map[int64]*interaction
+}
+
+func (r *recorder) started(_ context.Context, e *event.CommandStartedEvent) {
+	// Sensitive commands such as authentication are reported without their contents.
+	if len(e.Command) == 0 || slices.Contains(unrecordedCommands, e.CommandName) {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	in := &interaction{Command: without(e.Command, volatileCommandFields)}
+	r.interactions = append(r.interactions, in)
+	r.pending[e.RequestID] = in
+}
+
+func (r *recorder) succeeded(_ context.Context, e *event.CommandSucceededEvent) {
+	r.finish(e.RequestID, e.Reply)
+}
+
+func (r *recorder) failed(_ context.Context, e *event.CommandFailedEvent) {
+	var serverErr driver.Error
+	if errors.As(e.Failure, &serverErr) && len(serverErr.Raw) > 0 {
+		r.finish(e.RequestID, bson.Raw(serverErr.Raw))
+		return
+	}
+	// The command failed without a server reply, e.g. because of a network error.
+	reply, _ := bson.Marshal(bson.D{{Key: "ok", Value: 0.0}, {Key: "errmsg", Value: e.Failure.Error()}})
+	r.finish(e.RequestID, reply)
+}
+
+func (r *recorder) finish(requestID int64, reply bson.Raw) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if in, ok := r.pending[requestID]; ok {
+		in.Reply = without(reply, volatileReplyFields)
+		delete(r.pending, requestID)
+	}
+}
+
+// recorded returns the interactions that received a reply.
+func (r *recorder) recorded() []interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var interactions []interaction
+	for _, in := range r.interactions {
+		if in.Reply != nil {
+			interactions = append(interactions, *in)
+		}
+	}
+	return interactions
+}
+
+// record returns a database connected to a real server whose commands are written to golden.
+func record(t testing.TB, golden string, config Config) *mongo.Database {
+	t.Helper()
+	uri := serverURI(config)
+	r := &recorder{pending: make(map[int64]*interaction)}
+	client, err := mongo.Connect(options.Client().ApplyURI(uri).SetMonitor(&event.CommandMonitor{
+		Started:   r.started,
+		Succeeded: r.succeeded,
+		Failed:    r.failed,
+	}))
+	if err != nil {
+		t.Fatalf("monarchtest: cannot connect to %s: %v", uri, err)
+	}
+	t.Cleanup(func() {
+		if err := client.Disconnect(context.Background()); err != nil {
+			t.Errorf("monarchtest: disconnect failed: %v", err)
+		}
+		// A failed test may have stopped early, so its recording is not kept.
+		if t.Failed() {
+			return
+		}
+		if err := writeGolden(golden, r.recorded()); err != nil {
+			t.Errorf("monarchtest: cannot write golden file: %v", err)
+		}
+	})
+	return client.Database(config.Database)
+}
@@ -0,0 +1,207 @@
+package monarchtest
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// replayHost is the address of the replay server. It is never resolved, since
+// connections are created by the replayer.
+const replayHost = "monarchtest.replay:27017"
+
+// replayer is an in-process server replying to commands with recorded replies.
+type replayer struct {
+	t            testing.TB
+	match        Match
+	mu           sync.Mutex
+	interactions []interaction
+	used         []bool
+	next         int
+	closed       bool
+	requestID    atomic.Int32
+}
+
+// replay returns a database connected to a replayer of the golden file.
+func replay(t testing.TB, golden string, config Config) *mongo.Database {
+	t.Helper()
+	interactions, err := readGolden(golden)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("monarchtest: golden file %s not found, run with MONARCHTEST_RECORD=1 to record it", golden)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &replayer{t: t, match: config.Match, interactions: interactions, used: make([]bool, len(interactions))}
+	client, err := mongo.Connect(options.Client().
+		SetHosts([]string{replayHost}).
+		SetDirect(true).
+		SetDialer(r).
+		SetServerMonitoringMode(options.ServerMonitoringModePoll))
+	if err != nil {
+		t.Fatalf("monarchtest: cannot start replay: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := client.Disconnect(context.Background()); err != nil {
+			t.Errorf("monarchtest: disconnect failed: %v", err)
+		}
+		r.close()
+	})
+	return client.Database(config.Database)
+}
+
+// DialContext returns a connection to the replay server.
+func (r *replayer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	client, server := net.Pipe()
+	go r.serve(server)
+	return client, nil
+}
+
+// serve answers the requests received on conn until it is closed.
+func (r *replayer) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		requestID, opCode, body, err := readMessage(conn)
+		if err != nil {
+			return
+		}
+		switch opCode {
+		case opQuery:
+			query, err := parseQuery(body)
+			if err != nil {
+				return
+			}
+			if err := writeMessage(conn, r.requestID.Add(1), requestID, opReply, replyBody(r.reply(query))); err != nil {
+				return
+			}
+		case opMsg:
+			cmd, moreToCome, err := parseMsg(body)
+			if err != nil {
+				return
+			}
+			reply := r.reply(cmd)
+			if moreToCome {
+				continue
+			}
+			if err := writeMessage(conn, r.requestID.Add(1), requestID, opMsg, msgBody(reply)); err != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// reply returns the reply to cmd.
+func (r *replayer) reply(cmd bson.Raw) bson.Raw {
+	switch commandName(cmd) {
+	case "hello", "isMaster", "ismaster":
+		return helloReply()
+	case "endSessions":
+		return okReply()
+	}
+
+	cmd = without(cmd, volatileCommandFields)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i := r.find(cmd); i >= 0 {
+		r.used[i] = true
+		r.next = i + 1
+		return r.interactions[i].Reply
+	}
+
+	msg := "monarchtest: unexpected command " + cmd.String()
+	if r.match == Strict && r.next < len(r.interactions) {
+		msg += ", expected " + r.interactions[r.next].Command.String()
+	}
+	if !r.closed {
+		r.t.Error(msg)
+	}
+	reply, _ := bson.Marshal(bson.D{{Key: "ok", Value: 0.0}, {Key: "errmsg", Value: msg}})
+	return reply
+}
+
+// find returns the index of the recorded interaction matching cmd, or -1.
+// It is called with the replayer locked.
+func (r *replayer) find(cmd bson.Raw) int {
+	if r.match == Strict {
+		if r.next < len(r.interactions) && sameCommand(cmd, r.interactions[r.next].Command) {
+			return r.next
+		}
+		return -1
+	}
+	key := commandKey(cmd)
+	for i, in := range r.interactions {
+		if !r.used[i] && commandKey(in.Command) == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// close reports the recorded commands that were not replayed in strict mode, and
+// stops reporting unexpected commands, which the test can no longer observe.
+func (r *replayer) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	if r.match != Strict || r.t.Failed() {
+		return
+	}
+	if unused := len(r.used) - countTrue(r.used); unused > 0 {
+		r.t.Errorf("monarchtest: %d recorded commands were not replayed, starting with %s",
+			unused, r.interactions[slices.Index(r.used, false)].Command)
+	}
+}
+
+func countTrue(values []bool) int {
+	n := 0
+	for _, v := range values {
+		if v {
+			n++
+		}
+	}
+	return n
+}
+
+// helloReply describes the replay server as the primary of a replica set, so that
+// sessions and transactions are available.
+func helloReply() bson.Raw {
+	reply, _ := bson.Marshal(bson.D{
+		{Key: "helloOk", Value: true},
+		{Key: "isWritablePrimary", Value: true},
+		{Key: "ismaster", Value: true},
+		{Key: "setName", Value: "monarchtest"},
+		{Key: "setVersion", Value: int32(1)},
+		{Key: "hosts", Value: bson.A{replayHost}},
+		{Key: "primary", Value: replayHost},
+		{Key: "me", Value: replayHost},
+		{Key: "maxBsonObjectSize", Value: int32(16 * 1024 * 1024)},
+		{Key: "maxMessageSizeBytes", Value: int32(48000000)},
+		{Key: "maxWriteBatchSize", Value: int32(100000)},
+		{Key: "localTime", Value: time.Now()},
+		{Key: "logicalSessionTimeoutMinutes", Value: int32(30)},
+		{Key: "connectionId", Value: int32(1)},
+		{Key: "minWireVersion", Value: int32(0)},
+		{Key: "maxWireVersion", Value: int32(21)},
+		{Key: "readOnly", Value: false},
+		{Key: "ok", Value: 1.0},
+	})
+	return reply
+}
+
+func okReply() bson.Raw {
+	reply, _ := bson.Marshal(bson.D{{Key: "ok", Value: 1.0}})
+	return reply
+}
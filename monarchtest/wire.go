@@ -0,0 +1,175 @@
+package monarchtest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/x/bsonx/bsoncore"
+)
+
+// Opcodes of the MongoDB wire protocol used by the driver.
+const (
+	opReply = 1
+	opQuery = 2004
+	opMsg   = 2013
+)
+
+// OP_MSG flag bits.
+const (
+	flagChecksumPresent = 1 << 0
+	flagMoreToCome      = 1 << 1
+)
+
+var errMalformed = errors.New("monarchtest: malformed wire message")
+
+// readMessage reads a wire protocol message and returns its request ID, opcode and body.
+func readMessage(r io.Reader) (requestID, opCode int32, body []byte, err error) {
+	var header [16]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, 0, nil, err
+	}
+	length := int32(binary.LittleEndian.Uint32(header[0:]))
+	if length < 16 {
+		return 0, 0, nil, errMalformed
+	}
+	requestID = int32(binary.LittleEndian.Uint32(header[4:]))
+	opCode = int32(binary.LittleEndian.Uint32(header[12:]))
+	body = make([]byte, length-16)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, 0, nil, err
+	}
+	return requestID, opCode, body, nil
+}
+
+// writeMessage writes a wire protocol message replying to the request with the given ID.
+func writeMessage(w io.Writer, requestID, responseTo, opCode int32, body []byte) error {
+	msg := make([]byte, 16, 16+len(body))
+	binary.LittleEndian.PutUint32(msg[0:], uint32(16+len(body)))
+	binary.LittleEndian.PutUint32(msg[4:], uint32(requestID))
+	binary.LittleEndian.PutUint32(msg[8:], uint32(responseTo))
+	binary.LittleEndian.PutUint32(msg[12:], uint32(opCode))
+	_, err := w.Write(append(msg, body...))
+	return err
+}
+
+// parseMsg returns the command of an OP_MSG body, with its document sequences
+// appended to it as arrays, as reported to command monitors.
+func parseMsg(body []byte) (cmd bson.Raw, moreToCome bool, err error) {
+	if len(body) < 5 {
+		return nil, false, errMalformed
+	}
+	flags := binary.LittleEndian.Uint32(body)
+	end := len(body)
+	if flags&flagChecksumPresent != 0 {
+		end -= 4
+	}
+
+	type sequence struct {
+		identifier string
+		docs       []bsoncore.Document
+	}
+	var sequences []sequence
+	for pos := 4; pos < end; {
+		kind := body[pos]
+		pos++
+		switch kind {
+		case 0:
+			doc, _, ok := bsoncore.ReadDocument(body[pos:end])
+			if !ok {
+				return nil, false, errMalformed
+			}
+			cmd = bson.Raw(doc)
+			pos += len(doc)
+		case 1:
+			size, _, ok := bsoncore.ReadLength(body[pos:end])
+			if !ok || size < 4 || pos+int(size) > end {
+				return nil, false, errMalformed
+			}
+			section := body[pos+4 : pos+int(size)]
+			identifier, rest, ok := readCString(section)
+			if !ok {
+				return nil, false, errMalformed
+			}
+			seq := sequence{identifier: identifier}
+			for len(rest) > 0 {
+				doc, r, ok := bsoncore.ReadDocument(rest)
+				if !ok {
+					return nil, false, errMalformed
+				}
+				seq.docs = append(seq.docs, doc)
+				rest = r
+			}
+			sequences = append(sequences, seq)
+			pos += int(size)
+		default:
+			return nil, false, fmt.Errorf("monarchtest: unsupported OP_MSG section kind %d", kind)
+		}
+	}
+	if cmd == nil {
+		return nil, false, errMalformed
+	}
+	if len(sequences) == 0 {
+		return cmd, flags&flagMoreToCome != 0, nil
+	}
+
+	idx, out := bsoncore.AppendDocumentStart(nil)
+	elems, err := cmd.Elements()
+	if err != nil {
+		return nil, false, err
+	}
+	for _, elem := range elems {
+		out = append(out, elem...)
+	}
+	for _, seq := range sequences {
+		arrIdx, arr := bsoncore.AppendArrayElementStart(out, seq.identifier)
+		for i, doc := range seq.docs {
+			arr = bsoncore.AppendDocumentElement(arr, fmt.Sprint(i), doc)
+		}
+		out, _ = bsoncore.AppendArrayEnd(arr, arrIdx)
+	}
+	out, err = bsoncore.AppendDocumentEnd(out, idx)
+	return bson.Raw(out), flags&flagMoreToCome != 0, err
+}
+
+// parseQuery returns the query document of an OP_QUERY body, used by the driver
+// for the initial handshake.
+func parseQuery(body []byte) (bson.Raw, error) {
+	if len(body) < 4 {
+		return nil, errMalformed
+	}
+	_, rest, ok := readCString(body[4:])
+	if !ok || len(rest) < 8 {
+		return nil, errMalformed
+	}
+	doc, _, ok := bsoncore.ReadDocument(rest[8:])
+	if !ok {
+		return nil, errMalformed
+	}
+	return bson.Raw(doc), nil
+}
+
+// msgBody returns the body of an OP_MSG holding doc.
+func msgBody(doc bson.Raw) []byte {
+	body := make([]byte, 5, 5+len(doc))
+	return append(body, doc...)
+}
+
+// replyBody returns the body of an OP_REPLY holding doc.
+func replyBody(doc bson.Raw) []byte {
+	body := make([]byte, 20, 20+len(doc))
+	binary.LittleEndian.PutUint32(body[16:], 1) // numberReturned
+	return append(body, doc...)
+}
+
+// readCString reads a null-terminated string from src and returns it and the remaining bytes.
+func readCString(src []byte) (string, []byte, bool) {
+	i := bytes.IndexByte(src, 0)
+	if i < 0 {
+		return "", src, false
+	}
+	return string(src[:i]), src[i+1:], true
+}
@@ -0,0 +1,139 @@
+// Package outbox implements the transactional outbox pattern on top of monarch collections.
+//
+// Events are written to an outbox collection in the same transaction as the business
+// write, so an event is stored if and only if the write is committed. A Relay then
+// reads pending events and hands them to a Publisher, marking them delivered only
+// after publishing succeeds:
+//
+//	type OrderEvent struct {
+//	    OrderID string `bson:"orderId"`
+//	    Total   int    `bson:"total"`
+//	}
+//
+//	var Events outbox.Outbox[OrderEvent] = "outbox"
+//
+//	err := monarch.TxDo(ctx, func(ctx context.Context) error {
+//	    if _, err := Orders.InsertOne(ctx, order); err != nil {
+//	        return err
+//	    }
+//	    _, err := Events.Add(ctx, order.ID, "order.created", OrderEvent{OrderID: order.ID})
+//	    return err
+//	})
+//
+//	relay := &outbox.Relay[OrderEvent]{Outbox: Events, Publisher: publisher}
+//	go relay.Run(ctx)
+//
+// Delivery is at-least-once: an event may be published again if the process stops
+// after publishing but before marking it delivered, so publishers and consumers
+// should be idempotent. Events sharing a key are published in the order of their
+// sequence numbers, and an event is not published until all earlier events with its
+// key have been delivered.
+//
+// Sequence numbers are assigned per key by the server in a counter document that Add
+// updates in the caller's transaction. Concurrent transactions adding events with the
+// same key conflict on the counter and are serialized, so an event's sequence number
+// is only visible once all events with lower numbers have been committed.
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/eriicafes/monarch"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Event is a domain event stored in an outbox collection.
+type Event[P any] struct {
+	// ID identifies the event and orders events added by the same process.
+	ID bson.ObjectID `bson:"_id"`
+	// Key is the aggregate key. Events with the same key are published in order.
+	Key string `bson:"key"`
+	// Seq is the sequence number of the event among the events with the same key,
+	// starting at 1.
+	Seq int64 `bson:"seq"`
+	// Type is the event type, e.g. "order.created".
+	Type string `bson:"type"`
+	// Payload is the event data.
+	Payload P `bson:"payload"`
+	// CreatedAt is the time the event was added.
+	CreatedAt time.Time `bson:"createdAt"`
+	// Attempts is the number of failed attempts to publish the event.
+	Attempts int `bson:"attempts"`
+	// LastError is the error of the last failed attempt to publish the event.
+	LastError string `bson:"lastError,omitempty"`
+	// DeliveredAt is the time the event was published, or nil if it is pending.
+	DeliveredAt *time.Time `bson:"deliveredAt,omitempty"`
+}
+
+// Outbox represents an outbox collection of events with payloads of type P.
+//
+// Outboxes are defined as typed string constants representing the collection name.
+type Outbox[P any] string
+
+// Collection returns the collection storing the events of the outbox.
+func (o Outbox[P]) Collection() monarch.Collection[Event[P]] {
+	return monarch.Collection[Event[P]](o)
+}
+
+// sequence is the counter of the events added with a key.
+type sequence struct {
+	Key string `bson:"_id"`
+	Seq int64  `bson:"seq"`
+}
+
+// sequences returns the collection storing the sequence counters of the outbox,
+// named after the outbox with a _sequences suffix.
+func (o Outbox[P]) sequences() monarch.Collection[sequence] {
+	return monarch.Collection[sequence](string(o) + "_sequences")
+}
+
+// Add adds an event to the outbox and returns the stored event.
+//
+// Call Add with the context of the transaction performing the business write, such as the
+// context passed to the function given to monarch.Tx, so that the event is only stored if
+// the transaction commits. Within a transaction, Add locks the sequence counter of the key
+// until the transaction ends.
+func (o Outbox[P]) Add(ctx context.Context, key, typ string, payload P) (Event[P], error) {
+	seq, err := o.sequences().FindOneAndUpdate(ctx,
+		bson.D{{Key: "_id", Value: key}},
+		bson.D{{Key: "$inc", Value: bson.D{{Key: "seq", Value: 1}}}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	)
+	if err != nil {
+		return Event[P]{}, fmt.Errorf("outbox: assign sequence of key %q: %w", key, err)
+	}
+	event := Event[P]{
+		ID:        bson.NewObjectID(),
+		Key:       key,
+		Seq:       seq.Seq,
+		Type:      typ,
+		Payload:   payload,
+		CreatedAt: time.Now(),
+	}
+	_, err = o.Collection().InsertOne(ctx, event)
+	return event, err
+}
+
+// Pending returns up to limit events that have not been delivered, in the order they were added.
+//
+// Events with the same key are returned in the order of their _id, which may differ from
+// the order of their sequence numbers when they were added by different processes.
+func (o Outbox[P]) Pending(ctx context.Context, limit int64) ([]Event[P], error) {
+	return o.Collection().Find(ctx,
+		bson.D{{Key: "deliveredAt", Value: nil}},
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(limit),
+	)
+}
+
+// EnsureIndexes creates the indexes used to find pending events.
+func (o Outbox[P]) EnsureIndexes(ctx context.Context) error {
+	_, err := o.Collection().CreateIndexes(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "deliveredAt", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "key", Value: 1}, {Key: "seq", Value: 1}}},
+	})
+	return err
+}
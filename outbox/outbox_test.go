@@ -0,0 +1,286 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eriicafes/monarch"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// setupTestDB connects to MongoDB and returns a database instance for testing.
+// It skips the test if SKIP_INTEGRATION is set to any non-empty value, or if MONGO_URI
+// is not set and no server answers on localhost.
+// Returns the database and a cleanup function that should be deferred.
+func setupTestDB(t *testing.T) (*mongo.Database, func()) {
+	t.Helper()
+
+	if os.Getenv("SKIP_INTEGRATION") != "" {
+		t.Skip("skipping integration tests (SKIP_INTEGRATION is set)")
+	}
+
+	uri := os.Getenv("MONGO_URI")
+	if uri == "" {
+		uri = "mongodb://localhost:27017"
+	}
+	if err := pingServer(uri); err != nil {
+		if os.Getenv("MONGO_URI") == "" {
+			t.Skipf("skipping integration tests (no MongoDB at %s: %v)", uri, err)
+		}
+		t.Fatalf("failed to connect to MongoDB: %v", err)
+	}
+
+	client, err := mongo.Connect(options.Client().ApplyURI(uri).SetServerSelectionTimeout(5 * time.Second))
+	if err != nil {
+		t.Fatalf("failed to create MongoDB client: %v", err)
+	}
+
+	db := client.Database("monarch_outbox_test_db")
+
+	cleanup := func() {
+		ctx := context.Background()
+		db.Drop(ctx)
+		client.Disconnect(ctx)
+	}
+
+	return db, cleanup
+}
+
+var (
+	pingOnce sync.Once
+	pingErr  error
+)
+
+// pingServer pings the server at uri once per test binary, so that when no server
+// is running each test is skipped without waiting for server selection again.
+func pingServer(uri string) error {
+	pingOnce.Do(func() {
+		client, err := mongo.Connect(options.Client().ApplyURI(uri).SetServerSelectionTimeout(5 * time.Second))
+		if err != nil {
+			pingErr = err
+			return
+		}
+		defer client.Disconnect(context.Background())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		pingErr = client.Ping(ctx, nil)
+	})
+	return pingErr
+}
+
+// OrderEvent is the test event payload.
+type OrderEvent struct {
+	OrderID string `bson:"orderId"`
+}
+
+// Events is the test outbox.
+var Events Outbox[OrderEvent] = "outbox"
+
+// recorder is a Publisher that records published events and fails for keys in fail.
+type recorder struct {
+	published []string
+	fail      map[string]bool
+}
+
+func (r *recorder) Publish(ctx context.Context, event Event[OrderEvent]) error {
+	if r.fail[event.Key] {
+		return errors.New("broker unavailable")
+	}
+	r.published = append(r.published, event.Key+":"+event.Type)
+	return nil
+}
+
+func TestAddAndProcess(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := monarch.WithContext(context.Background(), db)
+
+	if err := Events.EnsureIndexes(ctx); err != nil {
+		t.Fatalf("EnsureIndexes failed: %v", err)
+	}
+
+	adds := []struct{ key, typ string }{
+		{"order1", "created"},
+		{"order2", "created"},
+		{"order1", "paid"},
+		{"order2", "paid"},
+	}
+	for _, a := range adds {
+		if _, err := Events.Add(ctx, a.key, a.typ, OrderEvent{OrderID: a.key}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	publisher := &recorder{fail: map[string]bool{"order2": true}}
+	var reported []error
+	relay := &Relay[OrderEvent]{
+		Outbox:    Events,
+		Publisher: publisher,
+		OnError:   func(err error) { reported = append(reported, err) },
+	}
+
+	delivered, err := relay.Process(ctx)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if delivered != 2 {
+		t.Errorf("expected 2 delivered, got %d", delivered)
+	}
+	if !slices.Equal(publisher.published, []string{"order1:created", "order1:paid"}) {
+		t.Errorf("expected order1 events in order, got %v", publisher.published)
+	}
+	if len(reported) != 1 {
+		t.Errorf("expected 1 reported error for the blocked key, got %d", len(reported))
+	}
+
+	pending, err := Events.Pending(ctx, 10)
+	if err != nil {
+		t.Fatalf("Pending failed: %v", err)
+	}
+	if len(pending) != 2 {
+		t.Fatalf("expected 2 pending events, got %d", len(pending))
+	}
+	if pending[0].Type != "created" || pending[0].Attempts != 1 || pending[0].LastError == "" {
+		t.Errorf("expected failed attempt recorded on order2:created, got %+v", pending[0])
+	}
+	if pending[1].Attempts != 0 {
+		t.Errorf("expected order2:paid to be skipped, got %d attempts", pending[1].Attempts)
+	}
+
+	publisher.fail = nil
+	delivered, err = relay.Process(ctx)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if delivered != 2 {
+		t.Errorf("expected 2 delivered, got %d", delivered)
+	}
+	if !slices.Equal(publisher.published[2:], []string{"order2:created", "order2:paid"}) {
+		t.Errorf("expected order2 events in order, got %v", publisher.published[2:])
+	}
+
+	count, err := Events.Collection().CountDocuments(ctx, bson.M{})
+	if err != nil {
+		t.Fatalf("CountDocuments failed: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("expected delivered events to be deleted, got %d", count)
+	}
+}
+
+func TestProcessWithRetention(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := monarch.WithContext(context.Background(), db)
+
+	if _, err := Events.Add(ctx, "order1", "created", OrderEvent{OrderID: "order1"}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	relay := &Relay[OrderEvent]{Outbox: Events, Publisher: &recorder{}, Retention: time.Hour}
+	if _, err := relay.Process(ctx); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+
+	events, err := Events.Collection().Find(ctx, bson.M{})
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if len(events) != 1 || events[0].DeliveredAt == nil {
+		t.Errorf("expected delivered event to be retained with deliveredAt, got %+v", events)
+	}
+}
+
+func TestRunStopsOnContextDone(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx, cancel := context.WithCancel(monarch.WithContext(context.Background(), db))
+
+	publisher := PublisherFunc[OrderEvent](func(ctx context.Context, event Event[OrderEvent]) error {
+		cancel()
+		return nil
+	})
+	if _, err := Events.Add(ctx, "order1", "created", OrderEvent{OrderID: "order1"}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	relay := &Relay[OrderEvent]{Outbox: Events, Publisher: publisher, PollInterval: 10 * time.Millisecond, ChangeStream: true}
+	if err := relay.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestOrderBySeq(t *testing.T) {
+	events := []Event[OrderEvent]{
+		{Key: "order1", Seq: 2},
+		{Key: "order2", Seq: 1},
+		{Key: "order1", Seq: 1},
+		{Key: "order1", Seq: 3},
+	}
+	first := orderBySeq(events)
+
+	var got []string
+	for _, event := range events {
+		got = append(got, event.Key+":"+strconv.FormatInt(event.Seq, 10))
+	}
+	if want := []string{"order1:1", "order2:1", "order1:2", "order1:3"}; !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if first["order1"] != 1 || first["order2"] != 1 {
+		t.Errorf("unexpected first sequence numbers %v", first)
+	}
+}
+
+func TestProcessOrdersBySeq(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := monarch.WithContext(context.Background(), db)
+
+	if err := Events.EnsureIndexes(ctx); err != nil {
+		t.Fatalf("EnsureIndexes failed: %v", err)
+	}
+	// Events committed by other processes can have _id values out of sequence order.
+	ids := []bson.ObjectID{bson.NewObjectID(), bson.NewObjectID(), bson.NewObjectID()}
+	_, err := Events.Collection().InsertMany(ctx, []Event[OrderEvent]{
+		{ID: ids[0], Key: "order1", Seq: 2, Type: "paid"},
+		{ID: ids[1], Key: "order1", Seq: 1, Type: "created"},
+		{ID: ids[2], Key: "order1", Seq: 3, Type: "shipped"},
+	})
+	if err != nil {
+		t.Fatalf("InsertMany failed: %v", err)
+	}
+
+	publisher := &recorder{}
+	relay := &Relay[OrderEvent]{Outbox: Events, Publisher: publisher, BatchSize: 1}
+	// The batch holds seq 2 while seq 1 is pending, so the key is skipped.
+	if delivered, err := relay.Process(ctx); err != nil || delivered != 0 {
+		t.Fatalf("expected key to be skipped, got %d, %v", delivered, err)
+	}
+
+	relay.BatchSize = 10
+	if _, err := relay.Process(ctx); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if want := []string{"order1:created", "order1:paid", "order1:shipped"}; !slices.Equal(publisher.published, want) {
+		t.Errorf("expected %v, got %v", want, publisher.published)
+	}
+
+	for i := range 2 {
+		event, err := Events.Add(ctx, "order2", "created", OrderEvent{OrderID: "order2"})
+		if err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		if event.Seq != int64(i+1) {
+			t.Errorf("expected sequence number %d, got %d", i+1, event.Seq)
+		}
+	}
+}
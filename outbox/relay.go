@@ -0,0 +1,216 @@
+package outbox
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Default values for unset Relay fields.
+const (
+	defaultBatchSize    = 100
+	defaultPollInterval = time.Second
+)
+
+// Publisher publishes events to a message broker or other external system.
+type Publisher[P any] interface {
+	// Publish publishes the event. A nil error marks the event as delivered.
+	// An event may be published more than once, so Publish should be idempotent.
+	Publish(ctx context.Context, event Event[P]) error
+}
+
+// PublisherFunc adapts a function to the Publisher interface.
+type PublisherFunc[P any] func(ctx context.Context, event Event[P]) error
+
+// Publish calls f(ctx, event).
+func (f PublisherFunc[P]) Publish(ctx context.Context, event Event[P]) error {
+	return f(ctx, event)
+}
+
+// Relay delivers pending events from an outbox to a publisher.
+//
+// Run a single relay per outbox, for example using a leader election, since
+// concurrent relays may publish the same event twice and out of order.
+type Relay[P any] struct {
+	// Outbox is the outbox to deliver events from.
+	Outbox Outbox[P]
+	// Publisher publishes the events.
+	Publisher Publisher[P]
+	// BatchSize is the maximum number of events read in each pass. Defaults to 100.
+	BatchSize int64
+	// PollInterval is the time between passes. Defaults to 1s.
+	PollInterval time.Duration
+	// ChangeStream, if true, starts a pass as soon as an event is added by watching
+	// the outbox collection, in addition to polling. Change streams require a
+	// replica set. If the change stream fails, the relay falls back to polling.
+	ChangeStream bool
+	// Retention is how long delivered events are kept. Zero deletes events as soon
+	// as they are delivered.
+	Retention time.Duration
+	// OnError, if set, is called with errors encountered while delivering events.
+	OnError func(error)
+}
+
+// Run delivers pending events until the context is done and then returns the context error.
+func (r *Relay[P]) Run(ctx context.Context) error {
+	interval := r.PollInterval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var wake <-chan struct{}
+	if r.ChangeStream {
+		wake = r.watch(ctx)
+	}
+
+	for {
+		if _, err := r.Process(ctx); err != nil && ctx.Err() == nil {
+			r.report(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		case <-wake:
+		}
+	}
+}
+
+// Process performs a single pass, publishing pending events in the order they were added
+// and returning the number of events delivered.
+//
+// Events with the same key are published in the order of their sequence numbers. A key is
+// skipped until the next pass if one of its earlier events is pending but not part of the
+// pass. If publishing an event fails, its attempt count and error are recorded and the
+// remaining events with the same key are skipped until the next pass. Delivered events
+// older than the retention period are deleted at the end of the pass.
+func (r *Relay[P]) Process(ctx context.Context) (int, error) {
+	batchSize := r.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	events, err := r.Outbox.Pending(ctx, batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	coll := r.Outbox.Collection()
+	delivered := 0
+	blocked, err := r.blockedKeys(ctx, orderBySeq(events))
+	if err != nil {
+		return 0, err
+	}
+	for _, event := range events {
+		if blocked[event.Key] {
+			continue
+		}
+		filter := bson.D{{Key: "_id", Value: event.ID}}
+		if publishErr := r.Publisher.Publish(ctx, event); publishErr != nil {
+			blocked[event.Key] = true
+			r.report(publishErr)
+			_, err = coll.UpdateOne(ctx, filter, bson.D{
+				{Key: "$inc", Value: bson.D{{Key: "attempts", Value: 1}}},
+				{Key: "$set", Value: bson.D{{Key: "lastError", Value: publishErr.Error()}}},
+			})
+			if err != nil {
+				return delivered, err
+			}
+			continue
+		}
+
+		if r.Retention > 0 {
+			_, err = coll.UpdateOne(ctx, filter, bson.D{
+				{Key: "$set", Value: bson.D{{Key: "deliveredAt", Value: time.Now()}}},
+			})
+		} else {
+			_, err = coll.DeleteOne(ctx, filter)
+		}
+		if err != nil {
+			return delivered, err
+		}
+		delivered++
+	}
+
+	if r.Retention > 0 {
+		_, err = coll.DeleteMany(ctx, bson.D{
+			{Key: "deliveredAt", Value: bson.D{{Key: "$lt", Value: time.Now().Add(-r.Retention)}}},
+		})
+		if err != nil {
+			return delivered, err
+		}
+	}
+	return delivered, nil
+}
+
+// orderBySeq reorders the events of each key by sequence number, keeping the positions
+// taken by the events of each key, and returns the lowest sequence number of each key.
+func orderBySeq[P any](events []Event[P]) map[string]int64 {
+	positions := make(map[string][]int)
+	for i, event := range events {
+		positions[event.Key] = append(positions[event.Key], i)
+	}
+	first := make(map[string]int64, len(positions))
+	for key, idx := range positions {
+		keyed := make([]Event[P], len(idx))
+		for i, j := range idx {
+			keyed[i] = events[j]
+		}
+		slices.SortStableFunc(keyed, func(a, b Event[P]) int { return cmp.Compare(a.Seq, b.Seq) })
+		for i, j := range idx {
+			events[j] = keyed[i]
+		}
+		first[key] = keyed[0].Seq
+	}
+	return first
+}
+
+// blockedKeys returns the keys with a pending event earlier than the first event of
+// the key in the pass, given by first.
+func (r *Relay[P]) blockedKeys(ctx context.Context, first map[string]int64) (map[string]bool, error) {
+	blocked := make(map[string]bool)
+	for key, seq := range first {
+		earlier, err := r.Outbox.Collection().Exists(ctx, bson.D{
+			{Key: "key", Value: key},
+			{Key: "seq", Value: bson.D{{Key: "$lt", Value: seq}}},
+			{Key: "deliveredAt", Value: nil},
+		})
+		if err != nil {
+			return nil, err
+		}
+		blocked[key] = earlier
+	}
+	return blocked, nil
+}
+
+// watch signals the returned channel whenever an event is added to the outbox.
+// The channel is never closed, so the relay keeps polling if the change stream fails.
+func (r *Relay[P]) watch(ctx context.Context) <-chan struct{} {
+	wake := make(chan struct{}, 1)
+	go func() {
+		pipeline := bson.A{bson.D{{Key: "$match", Value: bson.D{{Key: "operationType", Value: "insert"}}}}}
+		for _, err := range r.Outbox.Collection().Watch(ctx, pipeline) {
+			if err != nil {
+				if ctx.Err() == nil {
+					r.report(err)
+				}
+				return
+			}
+			select {
+			case wake <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return wake
+}
+
+func (r *Relay[P]) report(err error) {
+	if r.OnError != nil {
+		r.OnError(err)
+	}
+}
@@ -0,0 +1,329 @@
+package monarch
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Referable is implemented by every Collection and View, and identifies the target
+// of a Reference or the source of a View.
+type Referable interface {
+	collectionName() string
+	documentType() reflect.Type
+	findRaw(ctx context.Context, filter any) ([]bson.Raw, error)
+}
+
+func (c Collection[T]) collectionName() string {
+	return string(c)
+}
+
+func (c Collection[T]) documentType() reflect.Type {
+	return reflect.TypeFor[T]()
+}
+
+func (c Collection[T]) findRaw(ctx context.Context, filter any) ([]bson.Raw, error) {
+	return FindAs[bson.Raw](ctx, c, filter)
+}
+
+// Reference describes a field of documents of type T that holds the _id of
+// documents in another collection.
+//
+// References are declared with Collection.Ref and loaded with Populate.
+type Reference[T any] struct {
+	path   string
+	target Referable
+}
+
+// Ref declares a reference from the field at path to documents in the target collection.
+//
+// The path is the document key holding the referenced _id, and may be a dotted path
+// through embedded documents and arrays of embedded documents. The struct containing
+// the last path element must have a field tagged `monarch:"ref=<key>"` which receives
+// the referenced document. The field type may be the target document type or a pointer
+// to it, or a slice of either when the key holds an array of IDs:
+//
+//	type Order struct {
+//	    ID     string `bson:"_id"`
+//	    UserID string `bson:"userId"`
+//	    User   *User  `bson:"-" monarch:"ref=userId"`
+//	}
+//
+//	var Orders monarch.Collection[Order] = "orders"
+//
+//	err := monarch.Populate(ctx, orders, Orders.Ref("userId", Users))
+//
+// Populated fields are usually tagged `bson:"-"` so they are not stored.
+func (c Collection[T]) Ref(path string, target Referable) Reference[T] {
+	return Reference[T]{path: path, target: target}
+}
+
+// Populate loads referenced documents into the reference fields of docs.
+//
+// Each reference is loaded with a single find command that matches all referenced IDs
+// with $in, regardless of the number of documents, so populating does not cause N+1 queries.
+// Reference fields whose IDs have no matching document are set to their zero value, and
+// missing documents are omitted from slice reference fields. Pointer reference fields
+// pointing at the same document share a single decoded value.
+//
+// See [mongo.Collection.Find] for more details.
+func Populate[T any](ctx context.Context, docs []T, refs ...Reference[T]) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	v := reflect.ValueOf(docs)
+	for _, ref := range refs {
+		if err := ref.populate(ctx, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PopulateOne loads referenced documents into the reference fields of doc.
+//
+// See Populate for more details.
+func PopulateOne[T any](ctx context.Context, doc *T, refs ...Reference[T]) error {
+	v := reflect.ValueOf(doc)
+	for _, ref := range refs {
+		if err := ref.populate(ctx, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refSite is a single occurrence of a reference within a document.
+type refSite struct {
+	source reflect.Value
+	target reflect.Value
+}
+
+func (r Reference[T]) populate(ctx context.Context, v reflect.Value) error {
+	segs := strings.Split(r.path, ".")
+	if err := validateRef(reflect.TypeFor[T](), segs, r.target.documentType()); err != nil {
+		return fmt.Errorf("monarch: invalid reference %q: %w", r.path, err)
+	}
+
+	var sites []refSite
+	collectRefSites(v, segs, &sites)
+
+	seen := make(map[string]struct{})
+	var ids bson.A
+	for _, site := range sites {
+		for _, id := range site.ids() {
+			key, err := idKey(id)
+			if err != nil {
+				return fmt.Errorf("monarch: invalid reference %q: %w", r.path, err)
+			}
+			if _, ok := seen[key]; !ok {
+				seen[key] = struct{}{}
+				ids = append(ids, id)
+			}
+		}
+	}
+	docs := make(map[string]reflect.Value)
+	if len(ids) > 0 {
+		raws, err := r.target.findRaw(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}})
+		if err != nil {
+			return err
+		}
+		docType := r.target.documentType()
+		for _, raw := range raws {
+			id, err := raw.LookupErr("_id")
+			if err != nil {
+				return err
+			}
+			ptr := reflect.New(docType)
+			if err := decodeRaw(ctx, raw, ptr.Interface()); err != nil {
+				return err
+			}
+			docs[rawKey(id)] = ptr
+		}
+	}
+
+	for _, site := range sites {
+		if err := site.fill(docs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ids returns the referenced IDs held by the source field.
+func (s refSite) ids() []any {
+	if s.target.Kind() != reflect.Slice {
+		if id, ok := refID(s.source); ok {
+			return []any{id}
+		}
+		return nil
+	}
+	var ids []any
+	source := reflect.Indirect(s.source)
+	if !source.IsValid() {
+		return nil
+	}
+	for i := range source.Len() {
+		if id, ok := refID(source.Index(i)); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// fill sets the target field from the decoded documents keyed by ID.
+func (s refSite) fill(docs map[string]reflect.Value) error {
+	targetType := s.target.Type()
+	if targetType.Kind() != reflect.Slice {
+		s.target.SetZero()
+		id, ok := refID(s.source)
+		if !ok {
+			return nil
+		}
+		key, err := idKey(id)
+		if err != nil {
+			return err
+		}
+		if doc, ok := docs[key]; ok {
+			s.target.Set(refValue(doc, targetType))
+		}
+		return nil
+	}
+
+	ids := s.ids()
+	out := reflect.MakeSlice(targetType, 0, len(ids))
+	for _, id := range ids {
+		key, err := idKey(id)
+		if err != nil {
+			return err
+		}
+		if doc, ok := docs[key]; ok {
+			out = reflect.Append(out, refValue(doc, targetType.Elem()))
+		}
+	}
+	s.target.Set(out)
+	return nil
+}
+
+// refID returns the ID held by v, dereferencing pointers.
+// It reports false for nil pointers.
+func refID(v reflect.Value) (any, bool) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, false
+		}
+		v = v.Elem()
+	}
+	return v.Interface(), true
+}
+
+// refValue returns the decoded document pointer as a value assignable to type t.
+func refValue(doc reflect.Value, t reflect.Type) reflect.Value {
+	if t.Kind() == reflect.Pointer {
+		return doc
+	}
+	return doc.Elem()
+}
+
+// idKey returns a key identifying the BSON encoding of the ID value.
+func idKey(id any) (string, error) {
+	t, data, err := bson.MarshalValue(id)
+	if err != nil {
+		return "", err
+	}
+	return string([]byte{byte(t)}) + string(data), nil
+}
+
+// rawKey returns a key identifying the BSON encoding of the raw ID value.
+// Keys of equal IDs returned by idKey and rawKey are equal.
+func rawKey(id bson.RawValue) string {
+	return string([]byte{byte(id.Type)}) + string(id.Value)
+}
+
+// refStructType returns the struct type reached from t through pointers, slices and arrays.
+func refStructType(t reflect.Type) reflect.Type {
+	for {
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Array:
+			t = t.Elem()
+		default:
+			return t
+		}
+	}
+}
+
+// refTargetField returns the field of the struct type t tagged `monarch:"ref=<name>"`.
+func refTargetField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() {
+			continue
+		}
+		if ref, ok := parseTag(f.Tag.Get("monarch"))["ref"]; ok && ref == name {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// validateRef checks that the path segments resolve to a source field and a matching
+// reference field within t whose document type is docType.
+func validateRef(t reflect.Type, segs []string, docType reflect.Type) error {
+	t = refStructType(t)
+	if t.Kind() != reflect.Struct {
+		return fmt.Errorf("%s is not a struct", t)
+	}
+	source, ok := lookupField(t, segs[0])
+	if !ok {
+		return fmt.Errorf("field %q not found in %s", segs[0], t)
+	}
+	if len(segs) > 1 {
+		return validateRef(source.field.Type, segs[1:], docType)
+	}
+
+	target, ok := refTargetField(t, segs[0])
+	if !ok {
+		return fmt.Errorf(`no field tagged monarch:"ref=%s" in %s`, segs[0], t)
+	}
+	elem := target.Type
+	if elem.Kind() == reflect.Slice {
+		if indirectType(source.field.Type).Kind() != reflect.Slice {
+			return fmt.Errorf("field %s is a slice but %q does not hold an array", target.Name, segs[0])
+		}
+		elem = elem.Elem()
+	}
+	if elem.Kind() == reflect.Pointer {
+		elem = elem.Elem()
+	}
+	if elem != docType {
+		return fmt.Errorf("field %s has type %s, want %s or *%s", target.Name, target.Type, docType, docType)
+	}
+	return nil
+}
+
+// collectRefSites appends every occurrence of the reference path within v to sites.
+func collectRefSites(v reflect.Value, segs []string, sites *[]refSite) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			collectRefSites(v.Elem(), segs, sites)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			collectRefSites(v.Index(i), segs, sites)
+		}
+	case reflect.Struct:
+		source, _ := lookupField(v.Type(), segs[0])
+		if len(segs) > 1 {
+			collectRefSites(v.FieldByIndex(source.index), segs[1:], sites)
+			return
+		}
+		target, _ := refTargetField(v.Type(), segs[0])
+		*sites = append(*sites, refSite{
+			source: v.FieldByIndex(source.index),
+			target: v.FieldByIndex(target.Index),
+		})
+	}
+}
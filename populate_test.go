@@ -0,0 +1,223 @@
+package monarch
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Order is the test model for references to Users.
+type Order struct {
+	ID         string      `bson:"_id"`
+	UserID     string      `bson:"userId"`
+	User       *User       `bson:"-" monarch:"ref=userId"`
+	WatcherIDs []string    `bson:"watcherIds"`
+	Watchers   []User      `bson:"-" monarch:"ref=watcherIds"`
+	Items      []OrderItem `bson:"items"`
+}
+
+// OrderItem is an embedded document with a nested reference to Users.
+type OrderItem struct {
+	SellerID string `bson:"sellerId"`
+	Seller   *User  `bson:"-" monarch:"ref=sellerId"`
+}
+
+// Orders is the test collection referencing Users.
+var Orders Collection[Order] = "orders"
+
+func TestPopulateInvalidReference(t *testing.T) {
+	ctx := context.Background()
+	orders := []Order{{ID: "o1", UserID: "u1"}}
+
+	tests := []struct {
+		name string
+		ref  Reference[Order]
+	}{
+		{name: "unknown field", ref: Orders.Ref("customerId", Users)},
+		{name: "field without ref tag", ref: Orders.Ref("_id", Users)},
+		{name: "mismatched target type", ref: Orders.Ref("userId", Orders)},
+		{name: "unknown nested field", ref: Orders.Ref("items.buyerId", Users)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Populate(ctx, orders, tt.ref); err == nil {
+				t.Errorf("expected error for invalid reference, got nil")
+			}
+		})
+	}
+}
+
+func TestPopulate(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := WithContext(context.Background(), db)
+
+	cleanupCollection(t, ctx, Users)
+
+	users := []User{
+		{ID: "pu1", Name: "Alice"},
+		{ID: "pu2", Name: "Bob"},
+		{ID: "pu3", Name: "Charlie"},
+	}
+	_, err := Users.InsertMany(ctx, users)
+	if err != nil {
+		t.Fatalf("InsertMany failed: %v", err)
+	}
+
+	orders := []Order{
+		{
+			ID:         "po1",
+			UserID:     "pu1",
+			WatcherIDs: []string{"pu3", "missing", "pu2"},
+			Items:      []OrderItem{{SellerID: "pu2"}, {SellerID: "pu3"}},
+		},
+		{
+			ID:     "po2",
+			UserID: "missing",
+		},
+	}
+
+	err = Populate(ctx, orders,
+		Orders.Ref("userId", Users),
+		Orders.Ref("watcherIds", Users),
+		Orders.Ref("items.sellerId", Users),
+	)
+	if err != nil {
+		t.Fatalf("Populate failed: %v", err)
+	}
+
+	if orders[0].User == nil || orders[0].User.Name != "Alice" {
+		t.Errorf("expected order po1 user Alice, got %v", orders[0].User)
+	}
+	if orders[1].User != nil {
+		t.Errorf("expected order po2 user nil, got %v", orders[1].User)
+	}
+
+	watchers := make([]string, len(orders[0].Watchers))
+	for i, u := range orders[0].Watchers {
+		watchers[i] = u.Name
+	}
+	if !slices.Equal(watchers, []string{"Charlie", "Bob"}) {
+		t.Errorf("expected watchers [Charlie Bob], got %v", watchers)
+	}
+
+	sellers := make([]string, len(orders[0].Items))
+	for i, item := range orders[0].Items {
+		if item.Seller != nil {
+			sellers[i] = item.Seller.Name
+		}
+	}
+	if !slices.Equal(sellers, []string{"Bob", "Charlie"}) {
+		t.Errorf("expected sellers [Bob Charlie], got %v", sellers)
+	}
+
+	order := Order{ID: "po3", UserID: "pu2"}
+	if err := PopulateOne(ctx, &order, Orders.Ref("userId", Users)); err != nil {
+		t.Fatalf("PopulateOne failed: %v", err)
+	}
+	if order.User == nil || order.User.Name != "Bob" {
+		t.Errorf("expected order po3 user Bob, got %v", order.User)
+	}
+}
+
+// staticUsers is a Referable serving users from memory.
+type staticUsers []User
+
+func (s staticUsers) collectionName() string {
+	return "static_users"
+}
+
+func (s staticUsers) documentType() reflect.Type {
+	return reflect.TypeFor[User]()
+}
+
+func (s staticUsers) findRaw(ctx context.Context, filter any) ([]bson.Raw, error) {
+	var raws []bson.Raw
+	for _, u := range s {
+		raw, err := bson.Marshal(u)
+		if err != nil {
+			return nil, err
+		}
+		raws = append(raws, raw)
+	}
+	return raws, nil
+}
+
+func TestPopulateFillsReferences(t *testing.T) {
+	ctx := context.Background()
+	target := staticUsers{{ID: "u1", Name: "Alice"}, {ID: "u2", Name: "Bob"}}
+
+	orders := []Order{
+		{UserID: "u1", WatcherIDs: []string{"u2", "u3", "u1"}, Items: []OrderItem{{SellerID: "u2"}}},
+		{UserID: "u3", User: &User{Name: "Stale"}},
+	}
+	err := Populate(ctx, orders,
+		Orders.Ref("userId", target),
+		Orders.Ref("watcherIds", target),
+		Orders.Ref("items.sellerId", target),
+	)
+	if err != nil {
+		t.Fatalf("Populate failed: %v", err)
+	}
+
+	if orders[0].User == nil || orders[0].User.Name != "Alice" {
+		t.Errorf("expected user Alice, got %v", orders[0].User)
+	}
+	if orders[1].User != nil {
+		t.Errorf("expected missing user to reset field, got %v", orders[1].User)
+	}
+	if len(orders[0].Watchers) != 2 || orders[0].Watchers[0].Name != "Bob" || orders[0].Watchers[1].Name != "Alice" {
+		t.Errorf("expected watchers [Bob Alice], got %v", orders[0].Watchers)
+	}
+	if seller := orders[0].Items[0].Seller; seller == nil || seller.Name != "Bob" {
+		t.Errorf("expected seller Bob, got %v", seller)
+	}
+}
+
+// PatientVisit references Patients, which have encrypted fields.
+type PatientVisit struct {
+	PatientID string   `bson:"patientId"`
+	Patient   *Patient `bson:"-" monarch:"ref=patientId"`
+}
+
+var PatientVisits Collection[PatientVisit] = "patient_visits"
+
+// staticPatients is a Referable serving encrypted patients from memory.
+type staticPatients []Patient
+
+func (s staticPatients) collectionName() string {
+	return "static_patients"
+}
+
+func (s staticPatients) documentType() reflect.Type {
+	return reflect.TypeFor[Patient]()
+}
+
+func (s staticPatients) findRaw(ctx context.Context, filter any) ([]bson.Raw, error) {
+	var raws []bson.Raw
+	for _, p := range s {
+		doc, err := encryptDocument(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		raws = append(raws, doc.(bson.Raw))
+	}
+	return raws, nil
+}
+
+func TestPopulateDecryptsReferences(t *testing.T) {
+	ctx := WithEncryption(context.Background(), testKeys())
+	patient := Patient{ID: "p1", Name: "Ada", SSN: "123-45-6789", Contact: Contact{Phone: "555"}}
+
+	visits := []PatientVisit{{PatientID: "p1"}}
+	if err := Populate(ctx, visits, PatientVisits.Ref("patientId", staticPatients{patient})); err != nil {
+		t.Fatalf("Populate failed: %v", err)
+	}
+	if visits[0].Patient == nil || *visits[0].Patient != patient {
+		t.Errorf("expected decrypted patient %+v, got %+v", patient, visits[0].Patient)
+	}
+}
@@ -0,0 +1,305 @@
+// Package queue implements a durable job queue on top of monarch collections.
+//
+// Jobs are stored in a collection and leased atomically by workers with
+// FindOneAndUpdate. A leased job is hidden from other workers until its
+// visibility timeout expires, so a job whose worker crashes is leased again:
+//
+//	type Email struct {
+//	    To      string `bson:"to"`
+//	    Subject string `bson:"subject"`
+//	}
+//
+//	var Emails queue.Queue[Email] = "emails"
+//
+//	_, err := Emails.Enqueue(ctx, Email{To: "alice@example.com"}, queue.Priority(10))
+//
+//	worker := &queue.Worker[Email]{
+//	    Queue: Emails,
+//	    Handler: queue.HandlerFunc[Email](func(ctx context.Context, job queue.Job[Email]) error {
+//	        return send(ctx, job.Payload)
+//	    }),
+//	    Concurrency: 4,
+//	}
+//	err = worker.Run(ctx)
+//
+// Failed jobs are retried with backoff and moved to the dead letter state once
+// they reach their maximum attempts. Delivery is at-least-once, so handlers
+// should be idempotent.
+package queue
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/eriicafes/monarch"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// DefaultMaxAttempts is the maximum number of attempts of jobs enqueued without MaxAttempts.
+const DefaultMaxAttempts = 5
+
+// ErrEmpty is returned by Lease when no job is ready to run.
+var ErrEmpty = errors.New("queue: no job ready")
+
+// ErrLeaseLost is returned when a job is no longer leased by the caller,
+// because its visibility timeout expired and it was leased again.
+var ErrLeaseLost = errors.New("queue: job lease lost")
+
+// Status is the state of a job.
+type Status string
+
+const (
+	// StatusPending is the status of jobs waiting to run, running or waiting to be retried.
+	StatusPending Status = "pending"
+	// StatusDead is the status of jobs that failed their maximum attempts.
+	StatusDead Status = "dead"
+)
+
+// Job is a unit of work stored in a queue collection.
+type Job[P any] struct {
+	// ID identifies the job.
+	ID bson.ObjectID `bson:"_id"`
+	// Payload is the job data.
+	Payload P `bson:"payload"`
+	// Status is the state of the job.
+	Status Status `bson:"status"`
+	// Priority orders ready jobs. Jobs with higher priority are leased first.
+	Priority int `bson:"priority"`
+	// RunAt is the time the job becomes available to lease. While the job is leased,
+	// it is the time the lease expires, computed with the server time.
+	RunAt time.Time `bson:"runAt"`
+	// Attempts is the number of times the job was leased.
+	Attempts int `bson:"attempts"`
+	// MaxAttempts is the number of attempts after which a failing job is dead-lettered.
+	MaxAttempts int `bson:"maxAttempts"`
+	// LastError is the error of the last failed attempt.
+	LastError string `bson:"lastError,omitempty"`
+	// LeaseID identifies the current lease of the job. It is zero if the job is not leased.
+	LeaseID bson.ObjectID `bson:"leaseId,omitempty"`
+	// LeasedBy is the name of the worker holding the current lease.
+	LeasedBy string `bson:"leasedBy,omitempty"`
+	// CreatedAt is the time the job was enqueued.
+	CreatedAt time.Time `bson:"createdAt"`
+}
+
+// EnqueueOption configures a job added with Enqueue.
+type EnqueueOption func(*enqueueOptions)
+
+type enqueueOptions struct {
+	priority    int
+	runAt       time.Time
+	maxAttempts int
+}
+
+// Priority sets the priority of the job. Jobs with higher priority are leased first. Defaults to 0.
+func Priority(priority int) EnqueueOption {
+	return func(o *enqueueOptions) { o.priority = priority }
+}
+
+// RunAt sets the earliest time the job may run. Defaults to now.
+func RunAt(t time.Time) EnqueueOption {
+	return func(o *enqueueOptions) { o.runAt = t }
+}
+
+// Delay delays the job by d from now.
+func Delay(d time.Duration) EnqueueOption {
+	return func(o *enqueueOptions) { o.runAt = time.Now().Add(d) }
+}
+
+// MaxAttempts sets the number of attempts after which a failing job is dead-lettered.
+// Defaults to DefaultMaxAttempts.
+func MaxAttempts(n int) EnqueueOption {
+	return func(o *enqueueOptions) { o.maxAttempts = n }
+}
+
+// Queue represents a queue collection of jobs with payloads of type P.
+//
+// Queues are defined as typed string constants representing the collection name.
+// The indexes used to lease jobs are created automatically the first time a queue
+// is used with a database.
+type Queue[P any] string
+
+// Collection returns the collection storing the jobs of the queue.
+func (q Queue[P]) Collection() monarch.Collection[Job[P]] {
+	return monarch.Collection[Job[P]](q)
+}
+
+// Enqueue adds a job to the queue and returns the stored job.
+//
+// Call Enqueue with a transaction context to enqueue the job only if the transaction commits.
+func (q Queue[P]) Enqueue(ctx context.Context, payload P, opts ...EnqueueOption) (Job[P], error) {
+	if err := q.EnsureIndexes(ctx); err != nil {
+		return Job[P]{}, err
+	}
+
+	now := time.Now()
+	o := enqueueOptions{runAt: now, maxAttempts: DefaultMaxAttempts}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	job := Job[P]{
+		ID:          bson.NewObjectID(),
+		Payload:     payload,
+		Status:      StatusPending,
+		Priority:    o.priority,
+		RunAt:       o.runAt,
+		MaxAttempts: max(o.maxAttempts, 1),
+		CreatedAt:   now,
+	}
+	_, err := q.Collection().InsertOne(ctx, job)
+	return job, err
+}
+
+// Lease atomically leases the ready job with the highest priority, hiding it from other
+// workers for the visibility timeout, and increments its attempts.
+//
+// Returns ErrEmpty if no job is ready. The lease must be ended with Complete, Retry or
+// DeadLetter, and may be extended with Heartbeat.
+func (q Queue[P]) Lease(ctx context.Context, worker string, visibility time.Duration) (Job[P], error) {
+	if err := q.EnsureIndexes(ctx); err != nil {
+		return Job[P]{}, err
+	}
+
+	// Lease times are computed with the server time so that workers with skewed
+	// clocks agree on when a lease expires.
+	job, err := q.Collection().FindOneAndUpdate(ctx,
+		bson.D{
+			{Key: "status", Value: StatusPending},
+			{Key: "$expr", Value: bson.D{{Key: "$lte", Value: bson.A{"$runAt", "$$NOW"}}}},
+		},
+		bson.A{bson.D{{Key: "$set", Value: bson.D{
+			{Key: "runAt", Value: after(visibility)},
+			{Key: "leaseId", Value: bson.NewObjectID()},
+			{Key: "leasedBy", Value: literal(worker)},
+			{Key: "attempts", Value: bson.D{{Key: "$add", Value: bson.A{"$attempts", 1}}}},
+		}}}},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "priority", Value: -1}, {Key: "runAt", Value: 1}}).
+			SetReturnDocument(options.After),
+	)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return job, ErrEmpty
+	}
+	return job, err
+}
+
+// Heartbeat extends the lease of a job so that it stays hidden for the visibility
+// timeout from now. Returns ErrLeaseLost if the job is no longer leased by job.LeaseID.
+func (q Queue[P]) Heartbeat(ctx context.Context, job Job[P], visibility time.Duration) error {
+	return q.updateLeased(ctx, job, bson.A{
+		bson.D{{Key: "$set", Value: bson.D{{Key: "runAt", Value: after(visibility)}}}},
+	})
+}
+
+// Complete removes a successfully processed job from the queue.
+// Returns ErrLeaseLost if the job is no longer leased by job.LeaseID.
+func (q Queue[P]) Complete(ctx context.Context, job Job[P]) error {
+	result, err := q.Collection().DeleteOne(ctx, leaseFilter(job))
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// Retry releases the lease of a failed job, recording the cause, and makes the job
+// available again after delay. Returns ErrLeaseLost if the job is no longer leased
+// by job.LeaseID.
+func (q Queue[P]) Retry(ctx context.Context, job Job[P], cause error, delay time.Duration) error {
+	return q.updateLeased(ctx, job, bson.A{
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "runAt", Value: after(delay)},
+			{Key: "lastError", Value: literal(errorString(cause))},
+		}}},
+		bson.D{{Key: "$unset", Value: bson.A{"leaseId", "leasedBy"}}},
+	})
+}
+
+// DeadLetter releases the lease of a failed job, recording the cause, and moves the job
+// to the dead letter state where it is no longer leased. Returns ErrLeaseLost if the job
+// is no longer leased by job.LeaseID.
+func (q Queue[P]) DeadLetter(ctx context.Context, job Job[P], cause error) error {
+	return q.updateLeased(ctx, job, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "status", Value: StatusDead},
+			{Key: "lastError", Value: errorString(cause)},
+		}},
+		{Key: "$unset", Value: bson.D{{Key: "leaseId", Value: ""}, {Key: "leasedBy", Value: ""}}},
+	})
+}
+
+// Dead returns up to limit dead-lettered jobs, most recently enqueued first.
+func (q Queue[P]) Dead(ctx context.Context, limit int64) ([]Job[P], error) {
+	return q.Collection().Find(ctx,
+		bson.D{{Key: "status", Value: StatusDead}},
+		options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(limit),
+	)
+}
+
+// Requeue moves a dead-lettered job back to the queue with its attempts reset,
+// making it available to lease immediately.
+//
+// Returns mongo.ErrNoDocuments if no dead-lettered job has the given ID.
+func (q Queue[P]) Requeue(ctx context.Context, id bson.ObjectID) (Job[P], error) {
+	return q.Collection().FindOneAndUpdate(ctx,
+		bson.D{{Key: "_id", Value: id}, {Key: "status", Value: StatusDead}},
+		bson.A{bson.D{{Key: "$set", Value: bson.D{
+			{Key: "status", Value: StatusPending},
+			{Key: "runAt", Value: "$$NOW"},
+			{Key: "attempts", Value: 0},
+		}}}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	)
+}
+
+// indexes are the indexes used to lease jobs.
+var indexes = []mongo.IndexModel{
+	{Keys: bson.D{{Key: "status", Value: 1}, {Key: "priority", Value: -1}, {Key: "runAt", Value: 1}}},
+}
+
+// EnsureIndexes creates the indexes used to lease jobs once per database.
+//
+// It is called automatically by Enqueue, Lease and Worker.Run, so calling it
+// directly is only needed to create the indexes ahead of time.
+func (q Queue[P]) EnsureIndexes(ctx context.Context) error {
+	return q.Collection().EnsureIndexes(ctx, indexes)
+}
+
+// updateLeased applies update to the job if it is still leased by job.LeaseID.
+func (q Queue[P]) updateLeased(ctx context.Context, job Job[P], update any) error {
+	result, err := q.Collection().UpdateOne(ctx, leaseFilter(job), update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// leaseFilter matches the job while it is held by the lease of job.
+func leaseFilter[P any](job Job[P]) bson.D {
+	return bson.D{{Key: "_id", Value: job.ID}, {Key: "leaseId", Value: job.LeaseID}}
+}
+
+// after returns an aggregation expression for the server time after d.
+func after(d time.Duration) bson.D {
+	return bson.D{{Key: "$add", Value: bson.A{"$$NOW", d.Milliseconds()}}}
+}
+
+// literal returns an aggregation expression for s, which is not parsed as a field path.
+func literal(s string) bson.D {
+	return bson.D{{Key: "$literal", Value: s}}
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
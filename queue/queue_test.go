@@ -0,0 +1,265 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eriicafes/monarch"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// setupTestDB connects to MongoDB and returns a database instance for testing.
+// It skips the test if SKIP_INTEGRATION is set to any non-empty value, or if MONGO_URI
+// is not set and no server answers on localhost.
+// Returns the database and a cleanup function that should be deferred.
+func setupTestDB(t *testing.T) (*mongo.Database, func()) {
+	t.Helper()
+
+	if os.Getenv("SKIP_INTEGRATION") != "" {
+		t.Skip("skipping integration tests (SKIP_INTEGRATION is set)")
+	}
+
+	uri := os.Getenv("MONGO_URI")
+	if uri == "" {
+		uri = "mongodb://localhost:27017"
+	}
+	if err := pingServer(uri); err != nil {
+		if os.Getenv("MONGO_URI") == "" {
+			t.Skipf("skipping integration tests (no MongoDB at %s: %v)", uri, err)
+		}
+		t.Fatalf("failed to connect to MongoDB: %v", err)
+	}
+
+	client, err := mongo.Connect(options.Client().ApplyURI(uri).SetServerSelectionTimeout(5 * time.Second))
+	if err != nil {
+		t.Fatalf("failed to create MongoDB client: %v", err)
+	}
+
+	db := client.Database("monarch_queue_test_db")
+
+	cleanup := func() {
+		ctx := context.Background()
+		db.Drop(ctx)
+		client.Disconnect(ctx)
+	}
+
+	return db, cleanup
+}
+
+var (
+	pingOnce sync.Once
+	pingErr  error
+)
+
+// pingServer pings the server at uri once per test binary, so that when no server
+// is running each test is skipped without waiting for server selection again.
+func pingServer(uri string) error {
+	pingOnce.Do(func() {
+		client, err := mongo.Connect(options.Client().ApplyURI(uri).SetServerSelectionTimeout(5 * time.Second))
+		if err != nil {
+			pingErr = err
+			return
+		}
+		defer client.Disconnect(context.Background())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		pingErr = client.Ping(ctx, nil)
+	})
+	return pingErr
+}
+
+// Email is the test job payload.
+type Email struct {
+	To string `bson:"to"`
+}
+
+// Emails is the test queue.
+var Emails Queue[Email] = "emails"
+
+func TestDefaultBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{5, 16 * time.Second},
+		{13, time.Hour},
+		{100, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := defaultBackoff(tt.attempt); got != tt.want {
+			t.Errorf("defaultBackoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestNoDatabase(t *testing.T) {
+	ctx := context.Background()
+	if _, err := Emails.Enqueue(ctx, Email{}); !errors.Is(err, monarch.ErrNoDatabase) {
+		t.Errorf("Enqueue: expected ErrNoDatabase, got %v", err)
+	}
+	if _, err := Emails.Lease(ctx, "worker", time.Second); !errors.Is(err, monarch.ErrNoDatabase) {
+		t.Errorf("Lease: expected ErrNoDatabase, got %v", err)
+	}
+	worker := &Worker[Email]{Queue: Emails}
+	if err := worker.Run(ctx); !errors.Is(err, monarch.ErrNoDatabase) {
+		t.Errorf("Run: expected ErrNoDatabase, got %v", err)
+	}
+}
+
+func TestLeaseOrder(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := monarch.WithContext(context.Background(), db)
+
+	if _, err := Emails.Enqueue(ctx, Email{To: "low"}); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	if _, err := Emails.Enqueue(ctx, Email{To: "high"}, Priority(10)); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	if _, err := Emails.Enqueue(ctx, Email{To: "later"}, Priority(20), Delay(time.Hour)); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+
+	for _, want := range []string{"high", "low"} {
+		job, err := Emails.Lease(ctx, "worker", time.Minute)
+		if err != nil {
+			t.Fatalf("Lease failed: %v", err)
+		}
+		if job.Payload.To != want || job.Attempts != 1 || job.LeasedBy != "worker" {
+			t.Errorf("expected leased %q on attempt 1, got %+v", want, job)
+		}
+	}
+
+	if _, err := Emails.Lease(ctx, "worker", time.Minute); !errors.Is(err, ErrEmpty) {
+		t.Errorf("expected ErrEmpty, got %v", err)
+	}
+}
+
+func TestLeaseLifecycle(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := monarch.WithContext(context.Background(), db)
+
+	enqueued, err := Emails.Enqueue(ctx, Email{To: "alice"}, MaxAttempts(2))
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+
+	// An expired lease makes the job available again and invalidates the old lease.
+	first, err := Emails.Lease(ctx, "worker1", -time.Second)
+	if err != nil {
+		t.Fatalf("Lease failed: %v", err)
+	}
+	second, err := Emails.Lease(ctx, "worker2", time.Minute)
+	if err != nil {
+		t.Fatalf("Lease failed: %v", err)
+	}
+	if second.ID != enqueued.ID || second.Attempts != 2 {
+		t.Errorf("expected job leased again on attempt 2, got %+v", second)
+	}
+	if err := Emails.Complete(ctx, first); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("expected ErrLeaseLost for stale lease, got %v", err)
+	}
+	if err := Emails.Heartbeat(ctx, second, time.Minute); err != nil {
+		t.Errorf("Heartbeat failed: %v", err)
+	}
+
+	// Errors are stored as is, even if they look like field paths.
+	if err := Emails.Retry(ctx, second, errors.New("$smtp busy"), -time.Second); err != nil {
+		t.Fatalf("Retry failed: %v", err)
+	}
+	third, err := Emails.Lease(ctx, "worker3", time.Minute)
+	if err != nil {
+		t.Fatalf("Lease after Retry failed: %v", err)
+	}
+	if third.LastError != "$smtp busy" || third.LeasedBy != "worker3" || third.Attempts != 3 {
+		t.Errorf("expected retried job leased on attempt 3, got %+v", third)
+	}
+	if !third.RunAt.After(time.Now()) {
+		t.Errorf("expected lease to expire in the future, got %v", third.RunAt)
+	}
+
+	if err := Emails.DeadLetter(ctx, third, errors.New("smtp failed")); err != nil {
+		t.Fatalf("DeadLetter failed: %v", err)
+	}
+	dead, err := Emails.Dead(ctx, 10)
+	if err != nil {
+		t.Fatalf("Dead failed: %v", err)
+	}
+	if len(dead) != 1 || dead[0].LastError != "smtp failed" || !dead[0].LeaseID.IsZero() {
+		t.Fatalf("expected dead-lettered job, got %+v", dead)
+	}
+
+	requeued, err := Emails.Requeue(ctx, enqueued.ID)
+	if err != nil {
+		t.Fatalf("Requeue failed: %v", err)
+	}
+	if requeued.Status != StatusPending || requeued.Attempts != 0 {
+		t.Errorf("expected pending job with attempts reset, got %+v", requeued)
+	}
+	if _, err := Emails.Requeue(ctx, enqueued.ID); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Errorf("expected ErrNoDocuments requeueing a pending job, got %v", err)
+	}
+}
+
+func TestWorker(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx, cancel := context.WithTimeout(monarch.WithContext(context.Background(), db), 10*time.Second)
+	defer cancel()
+
+	for _, to := range []string{"ok", "flaky", "broken"} {
+		if _, err := Emails.Enqueue(ctx, Email{To: to}, MaxAttempts(2)); err != nil {
+			t.Fatalf("Enqueue failed: %v", err)
+		}
+	}
+
+	var processed atomic.Int32
+	worker := &Worker[Email]{
+		Queue: Emails,
+		Handler: HandlerFunc[Email](func(ctx context.Context, job Job[Email]) error {
+			processed.Add(1)
+			switch {
+			case job.Payload.To == "flaky" && job.Attempts == 1:
+				return errors.New("temporary failure")
+			case job.Payload.To == "broken":
+				panic("boom")
+			}
+			return nil
+		}),
+		Concurrency:  2,
+		PollInterval: 10 * time.Millisecond,
+		Backoff:      func(attempt int) time.Duration { return 0 },
+	}
+
+	runCtx, stop := context.WithCancel(ctx)
+	result := make(chan error, 1)
+	go func() { result <- worker.Run(runCtx) }()
+
+	for processed.Load() < 5 && ctx.Err() == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+	stop()
+	if err := <-result; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	jobs, err := Emails.Collection().Find(ctx, bson.M{})
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if len(jobs) != 1 || jobs[0].Payload.To != "broken" || jobs[0].Status != StatusDead {
+		t.Errorf("expected only the broken job to remain dead-lettered, got %+v", jobs)
+	}
+}
@@ -0,0 +1,206 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Default values for unset Worker fields.
+const (
+	defaultVisibility   = 30 * time.Second
+	defaultPollInterval = time.Second
+	defaultMinBackoff   = time.Second
+	defaultMaxBackoff   = time.Hour
+)
+
+// Handler processes jobs leased by a worker.
+type Handler[P any] interface {
+	// Handle processes the job. A nil error completes the job, and a non-nil error
+	// retries it or dead-letters it once it reaches its maximum attempts.
+	// The context is canceled if the lease of the job is lost.
+	Handle(ctx context.Context, job Job[P]) error
+}
+
+// HandlerFunc adapts a function to the Handler interface.
+type HandlerFunc[P any] func(ctx context.Context, job Job[P]) error
+
+// Handle calls f(ctx, job).
+func (f HandlerFunc[P]) Handle(ctx context.Context, job Job[P]) error {
+	return f(ctx, job)
+}
+
+// Worker leases jobs from a queue and processes them with a handler.
+type Worker[P any] struct {
+	// Queue is the queue to lease jobs from.
+	Queue Queue[P]
+	// Handler processes the jobs.
+	Handler Handler[P]
+	// Name identifies the worker in leased jobs. Defaults to the host name and process ID.
+	Name string
+	// Concurrency is the number of jobs processed at the same time. Defaults to 1.
+	Concurrency int
+	// Visibility is how long a leased job is hidden from other workers. The lease is
+	// extended periodically while the job is being processed. Defaults to 30s.
+	Visibility time.Duration
+	// PollInterval is the time to wait before leasing again when no job is ready. Defaults to 1s.
+	PollInterval time.Duration
+	// Backoff returns the delay before retrying a job after the given failed attempt,
+	// starting at 1. Defaults to an exponential backoff from 1s up to 1h.
+	Backoff func(attempt int) time.Duration
+	// OnError, if set, is called with errors encountered while processing jobs,
+	// including errors returned by the handler.
+	OnError func(error)
+}
+
+// Run leases and processes jobs until the context is done.
+//
+// When the context is done, Run stops leasing new jobs, waits for the jobs being
+// processed to finish and then returns the context error. Handlers are not canceled
+// on shutdown, so they should return promptly or respect their own timeouts.
+func (w *Worker[P]) Run(ctx context.Context) error {
+	if err := w.Queue.EnsureIndexes(ctx); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for range max(w.Concurrency, 1) {
+		wg.Go(func() { w.loop(ctx) })
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// loop leases and processes one job at a time until the context is done.
+func (w *Worker[P]) loop(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, err := w.Queue.Lease(ctx, w.name(), w.visibility())
+		if err == nil {
+			w.process(context.WithoutCancel(ctx), job)
+			continue
+		}
+		if !errors.Is(err, ErrEmpty) && ctx.Err() == nil {
+			w.report(err)
+		}
+
+		interval := w.PollInterval
+		if interval <= 0 {
+			interval = defaultPollInterval
+		}
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// process runs the handler for a leased job while extending its lease, and then
+// completes, retries or dead-letters the job.
+func (w *Worker[P]) process(ctx context.Context, job Job[P]) {
+	if job.Attempts > job.MaxAttempts {
+		// The lease expired on the last attempt, e.g. because the worker crashed.
+		w.report(w.Queue.DeadLetter(ctx, job, fmt.Errorf("queue: lease expired after %d attempts", job.MaxAttempts)))
+		return
+	}
+
+	handlerCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	done := make(chan struct{})
+	go w.heartbeat(handlerCtx, cancel, job, done)
+
+	err := w.handle(handlerCtx, job)
+	close(done)
+	if errors.Is(context.Cause(handlerCtx), ErrLeaseLost) {
+		w.report(ErrLeaseLost)
+		return
+	}
+
+	switch {
+	case err == nil:
+		w.report(w.Queue.Complete(ctx, job))
+	case job.Attempts >= job.MaxAttempts:
+		w.report(err)
+		w.report(w.Queue.DeadLetter(ctx, job, err))
+	default:
+		w.report(err)
+		w.report(w.Queue.Retry(ctx, job, err, w.backoff(job.Attempts)))
+	}
+}
+
+// handle calls the handler, converting a panic into an error.
+func (w *Worker[P]) handle(ctx context.Context, job Job[P]) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("queue: handler panicked: %v", r)
+		}
+	}()
+	return w.Handler.Handle(ctx, job)
+}
+
+// heartbeat extends the lease of the job until done is closed. If the lease is lost,
+// it cancels the handler context with ErrLeaseLost and returns.
+func (w *Worker[P]) heartbeat(ctx context.Context, cancel context.CancelCauseFunc, job Job[P], done <-chan struct{}) {
+	visibility := w.visibility()
+	ticker := time.NewTicker(visibility / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			err := w.Queue.Heartbeat(ctx, job, visibility)
+			if errors.Is(err, ErrLeaseLost) {
+				cancel(err)
+				return
+			}
+			if err != nil && ctx.Err() == nil {
+				w.report(err)
+			}
+		}
+	}
+}
+
+func (w *Worker[P]) name() string {
+	if w.Name != "" {
+		return w.Name
+	}
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+func (w *Worker[P]) visibility() time.Duration {
+	if w.Visibility <= 0 {
+		return defaultVisibility
+	}
+	return w.Visibility
+}
+
+func (w *Worker[P]) backoff(attempt int) time.Duration {
+	if w.Backoff != nil {
+		return w.Backoff(attempt)
+	}
+	return defaultBackoff(attempt)
+}
+
+// defaultBackoff doubles the delay after each attempt, from 1s up to 1h.
+func defaultBackoff(attempt int) time.Duration {
+	delay := defaultMinBackoff
+	for range attempt - 1 {
+		delay *= 2
+		if delay >= defaultMaxBackoff {
+			return defaultMaxBackoff
+		}
+	}
+	return delay
+}
+
+func (w *Worker[P]) report(err error) {
+	if err != nil && w.OnError != nil {
+		w.OnError(err)
+	}
+}
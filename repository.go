@@ -0,0 +1,109 @@
+package monarch
+
+import (
+	"context"
+	"iter"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Repository is the interface of the operations of a Collection of documents of type T.
+//
+// Depend on Repository rather than Collection in code that should be testable without
+// a database, and substitute a fake such as monarchmock.Repository in tests:
+//
+//	type UserService struct {
+//	    users monarch.Repository[User]
+//	}
+//
+//	service := UserService{users: Users}
+type Repository[T any] interface {
+	Find(ctx context.Context, filter any, opts ...options.Lister[options.FindOptions]) ([]T, error)
+	FindSeq(ctx context.Context, filter any, opts ...options.Lister[options.FindOptions]) iter.Seq2[T, error]
+	FindOne(ctx context.Context, filter any, opts ...options.Lister[options.FindOneOptions]) (T, error)
+	FindOneAndUpdate(ctx context.Context, filter any, update any, opts ...options.Lister[options.FindOneAndUpdateOptions]) (T, error)
+	FindOneAndReplace(ctx context.Context, filter any, replacement T, opts ...options.Lister[options.FindOneAndReplaceOptions]) (T, error)
+	FindOneAndDelete(ctx context.Context, filter any, opts ...options.Lister[options.FindOneAndDeleteOptions]) (T, error)
+	InsertOne(ctx context.Context, value T, opts ...options.Lister[options.InsertOneOptions]) (*mongo.InsertOneResult, error)
+	InsertMany(ctx context.Context, values []T, opts ...options.Lister[options.InsertManyOptions]) (*mongo.InsertManyResult, error)
+	UpdateOne(ctx context.Context, filter any, update any, opts ...options.Lister[options.UpdateOneOptions]) (*mongo.UpdateResult, error)
+	UpdateMany(ctx context.Context, filter any, update any, opts ...options.Lister[options.UpdateManyOptions]) (*mongo.UpdateResult, error)
+	ReplaceOne(ctx context.Context, filter any, replacement T, opts ...options.Lister[options.ReplaceOptions]) (*mongo.UpdateResult, error)
+	DeleteOne(ctx context.Context, filter any, opts ...options.Lister[options.DeleteOneOptions]) (*mongo.DeleteResult, error)
+	DeleteMany(ctx context.Context, filter any, opts ...options.Lister[options.DeleteManyOptions]) (*mongo.DeleteResult, error)
+	Upsert(ctx context.Context, filter any, replacement T) (doc T, created bool, err error)
+	UpsertWith(ctx context.Context, filter any, update any) (doc T, created bool, err error)
+	UpsertMany(ctx context.Context, values []T, keys []string, opts ...options.Lister[options.BulkWriteOptions]) (*mongo.BulkWriteResult, error)
+	CountDocuments(ctx context.Context, filter any, opts ...options.Lister[options.CountOptions]) (int64, error)
+	EstimatedCount(ctx context.Context, opts ...options.Lister[options.EstimatedDocumentCountOptions]) (int64, error)
+	Exists(ctx context.Context, filter any) (bool, error)
+	Aggregate(ctx context.Context, pipeline any, opts ...options.Lister[options.AggregateOptions]) ([]T, error)
+	ExplainFind(ctx context.Context, verbosity ExplainVerbosity, filter any, opts ...options.Lister[options.FindOptions]) (*ExplainResult, error)
+	ExplainAggregate(ctx context.Context, verbosity ExplainVerbosity, pipeline any, opts ...options.Lister[options.AggregateOptions]) (*ExplainResult, error)
+	CreateIndexes(ctx context.Context, models []mongo.IndexModel, opts ...options.Lister[options.CreateIndexesOptions]) ([]string, error)
+	EnsureIndexes(ctx context.Context, models []mongo.IndexModel) error
+	EnsureTimeSeries(ctx context.Context, ts TimeSeries) error
+	EnsureCapped(ctx context.Context, capped Capped) error
+	Tail(ctx context.Context, filter any) iter.Seq2[T, error]
+	Watch(ctx context.Context, pipeline any, opts ...options.Lister[options.ChangeStreamOptions]) iter.Seq2[ChangeEvent[T], error]
+	Invalidate(ctx context.Context)
+	InvalidateOnChange(ctx context.Context) error
+	History(ctx context.Context, id any) ([]Version[T], error)
+	AsOf(ctx context.Context, id any, t time.Time) (T, error)
+	Revert(ctx context.Context, id any, version int) (T, error)
+}
+
+var _ Repository[any] = Collection[any]("")
+
+// Projector is the interface of the operations of a Projection returning documents of type R.
+type Projector[R any] interface {
+	Find(ctx context.Context, filter any, opts ...options.Lister[options.FindOptions]) ([]R, error)
+	FindSeq(ctx context.Context, filter any, opts ...options.Lister[options.FindOptions]) iter.Seq2[R, error]
+	FindOne(ctx context.Context, filter any, opts ...options.Lister[options.FindOneOptions]) (R, error)
+	Aggregate(ctx context.Context, pipeline any, opts ...options.Lister[options.AggregateOptions]) ([]R, error)
+	Distinct(ctx context.Context, field string, filter any, opts ...options.Lister[options.DistinctOptions]) ([]R, error)
+}
+
+var _ Projector[any] = Projection[any, any]{}
+
+// Projection is a view of a Collection of documents of type T returning results of type R.
+//
+// Its methods are the *As functions, so a Projection can be substituted through
+// the Projector interface:
+//
+//	stats, err := monarch.As[UserStats](Users).Aggregate(ctx, pipeline)
+type Projection[R, T any] struct {
+	collection Collection[T]
+}
+
+// As returns the projection of c returning results of type R.
+func As[R, T any](c Collection[T]) Projection[R, T] {
+	return Projection[R, T]{collection: c}
+}
+
+// Find is equivalent to FindAs.
+func (p Projection[R, T]) Find(ctx context.Context, filter any, opts ...options.Lister[options.FindOptions]) ([]R, error) {
+	return FindAs[R](ctx, p.collection, filter, opts...)
+}
+
+// FindSeq is equivalent to FindSeqAs.
+func (p Projection[R, T]) FindSeq(ctx context.Context, filter any, opts ...options.Lister[options.FindOptions]) iter.Seq2[R, error] {
+	return FindSeqAs[R](ctx, p.collection, filter, opts...)
+}
+
+// FindOne is equivalent to FindOneAs.
+func (p Projection[R, T]) FindOne(ctx context.Context, filter any, opts ...options.Lister[options.FindOneOptions]) (R, error) {
+	return FindOneAs[R](ctx, p.collection, filter, opts...)
+}
+
+// Aggregate is equivalent to AggregateAs.
+func (p Projection[R, T]) Aggregate(ctx context.Context, pipeline any, opts ...options.Lister[options.AggregateOptions]) ([]R, error) {
+	return AggregateAs[R](ctx, p.collection, pipeline, opts...)
+}
+
+// Distinct is equivalent to DistinctAs.
+func (p Projection[R, T]) Distinct(ctx context.Context, field string, filter any, opts ...options.Lister[options.DistinctOptions]) ([]R, error) {
+	return DistinctAs[R](ctx, p.collection, field, filter, opts...)
+}
@@ -0,0 +1,44 @@
+package monarch
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+func TestProjection(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := WithContext(context.Background(), db)
+
+	cleanupCollection(t, ctx, Users)
+
+	_, err := Users.InsertMany(ctx, []User{
+		{ID: "pr1", Name: "Alice", Age: 20},
+		{ID: "pr2", Name: "Bob", Age: 30},
+	})
+	if err != nil {
+		t.Fatalf("InsertMany failed: %v", err)
+	}
+
+	type NameOnly struct {
+		Name string `bson:"name"`
+	}
+	var names Projector[NameOnly] = As[NameOnly](Users)
+
+	results, err := names.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil || len(results) != 2 || results[0].Name != "Alice" {
+		t.Errorf("expected Alice and Bob, got %+v, %v", results, err)
+	}
+	result, err := names.FindOne(ctx, bson.M{"_id": "pr2"})
+	if err != nil || result.Name != "Bob" {
+		t.Errorf("expected Bob, got %+v, %v", result, err)
+	}
+
+	ages, err := As[int](Users).Distinct(ctx, "age", bson.M{})
+	if err != nil || len(ages) != 2 {
+		t.Errorf("expected 2 ages, got %v, %v", ages, err)
+	}
+}
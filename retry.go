@@ -0,0 +1,155 @@
+package monarch
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// opKind classifies operations to decide which errors may be retried.
+type opKind int
+
+const (
+	// opRead is an operation that does not modify documents and is safe to retry.
+	opRead opKind = iota
+	// opWrite is an operation that modifies documents and is only retried when
+	// the policy opts in to retrying writes and the server reports a retryable write error.
+	opWrite
+)
+
+// Default values for unset RetryPolicy fields.
+const (
+	defaultInitialBackoff = 100 * time.Millisecond
+	defaultMaxBackoff     = 5 * time.Second
+	defaultMultiplier     = 2
+)
+
+// RetryPolicy configures automatic retries of collection operations that fail with transient errors.
+//
+// Reads are retried on any error classified by IsRetryable. Writes are not retried unless
+// RetryWrites is set. Operations running inside a transaction are never retried
+// individually, since the transaction as a whole must be retried instead.
+//
+// Retries happen in addition to the single retry performed by the driver for retryable
+// reads and writes.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first.
+	// Values less than 2 disable retries.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. Defaults to 100ms.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries. Defaults to 5s.
+	MaxBackoff time.Duration
+	// Multiplier is the factor the delay grows by after each retry. Defaults to 2.
+	Multiplier float64
+	// MaxElapsed bounds the total time spent on an operation including delays.
+	// No retry is attempted if its delay would exceed it. Zero means no limit.
+	MaxElapsed time.Duration
+	// RetryWrites enables retries of writes failing with errors labeled RetryableWriteError.
+	//
+	// Retrying a write runs it again as a new operation after the driver's own retry gave
+	// up, so a write that was applied before the error was reported is applied twice.
+	// Only enable it for idempotent writes, such as replacing or setting fields of a
+	// document by _id. Increments, pushes, and inserts of documents without an _id are
+	// not idempotent.
+	RetryWrites bool
+	// OnRetry, if set, is called before waiting for each retry.
+	OnRetry func(RetryEvent)
+}
+
+// RetryEvent describes a retry about to be performed.
+type RetryEvent struct {
+	// Collection is the name of the collection the operation was executed on.
+	Collection string
+	// Op is the name of the monarch operation, e.g. "FindOne".
+	Op string
+	// Attempt is the number of the attempt that failed, starting at 1.
+	Attempt int
+	// Err is the error returned by the failed attempt.
+	Err error
+	// Delay is the time that will be waited before the next attempt.
+	Delay time.Duration
+}
+
+type retryKey struct{}
+
+// WithRetry returns a new context with the retry policy attached.
+//
+// The policy applies to every collection operation using the returned context.
+func WithRetry(ctx context.Context, policy RetryPolicy) context.Context {
+	return context.WithValue(ctx, retryKey{}, policy)
+}
+
+// backoff returns the jittered delay before the given retry, starting at 1.
+// The delay is chosen uniformly between half and all of the exponential backoff.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	initial, maxBackoff, multiplier := p.InitialBackoff, p.MaxBackoff, p.Multiplier
+	if initial <= 0 {
+		initial = defaultInitialBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+	if multiplier < 1 {
+		multiplier = defaultMultiplier
+	}
+
+	delay := float64(initial)
+	for range retry - 1 {
+		delay *= multiplier
+		if delay >= float64(maxBackoff) {
+			break
+		}
+	}
+	delay = min(delay, float64(maxBackoff))
+	return time.Duration(delay/2 + rand.Float64()*delay/2)
+}
+
+// inTransaction reports whether ctx carries a session with a running transaction.
+func inTransaction(ctx context.Context) bool {
+	sess := mongo.SessionFromContext(ctx)
+	return sess != nil && sess.ClientSession().TransactionRunning()
+}
+
+// retryable reports whether an operation of the given kind may be retried after err.
+func (p RetryPolicy) retryable(kind opKind, err error) bool {
+	if kind == opWrite {
+		return p.RetryWrites && hasLabel(err, "RetryableWriteError")
+	}
+	return IsRetryable(err)
+}
+
+// retry runs fn, retrying it according to the retry policy in ctx.
+// The error of the last attempt is returned.
+func retry(ctx context.Context, collection, op string, kind opKind, fn func() error) error {
+	policy, ok := ctx.Value(retryKey{}).(RetryPolicy)
+	if !ok || policy.MaxAttempts < 2 || inTransaction(ctx) {
+		return fn()
+	}
+
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= policy.MaxAttempts || !policy.retryable(kind, err) {
+			return err
+		}
+
+		delay := policy.backoff(attempt)
+		if policy.MaxElapsed > 0 && time.Since(start)+delay > policy.MaxElapsed {
+			return err
+		}
+		if policy.OnRetry != nil {
+			policy.OnRetry(RetryEvent{Collection: collection, Op: op, Attempt: attempt, Err: err, Delay: delay})
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package monarch
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Transient errors are pointers so they can be compared with errors.Is.
+var (
+	errElection       = &mongo.CommandError{Code: 10107, Message: "not primary"}
+	errNetwork        = &mongo.CommandError{Labels: []string{"NetworkError"}}
+	errRetryableWrite = &mongo.WriteException{Labels: []string{"RetryableWriteError"}}
+	errBadValue       = &mongo.CommandError{Code: 2, Message: "bad value"}
+)
+
+// failing returns a function that fails with errs in order and then succeeds,
+// counting its calls in calls.
+func failing(calls *int, errs ...error) func() error {
+	return func() error {
+		*calls++
+		if *calls <= len(errs) {
+			return errs[*calls-1]
+		}
+		return nil
+	}
+}
+
+func TestRetry(t *testing.T) {
+	fast := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	tests := []struct {
+		name          string
+		ctx           context.Context
+		kind          opKind
+		errs          []error
+		expectedCalls int
+		expectedErr   error
+	}{
+		{
+			name:          "no policy runs once",
+			ctx:           context.Background(),
+			kind:          opRead,
+			errs:          []error{errElection},
+			expectedCalls: 1,
+			expectedErr:   errElection,
+		},
+		{
+			name:          "read retried until success",
+			ctx:           WithRetry(context.Background(), fast),
+			kind:          opRead,
+			errs:          []error{errElection, errNetwork},
+			expectedCalls: 3,
+		},
+		{
+			name:          "read stops after max attempts",
+			ctx:           WithRetry(context.Background(), fast),
+			kind:          opRead,
+			errs:          []error{errElection, errElection, errNetwork},
+			expectedCalls: 3,
+			expectedErr:   errNetwork,
+		},
+		{
+			name:          "write not retried without retryable label",
+			ctx:           WithRetry(context.Background(), RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, RetryWrites: true}),
+			kind:          opWrite,
+			errs:          []error{errNetwork},
+			expectedCalls: 1,
+			expectedErr:   errNetwork,
+		},
+		{
+			name:          "write not retried without opt in",
+			ctx:           WithRetry(context.Background(), fast),
+			kind:          opWrite,
+			errs:          []error{errRetryableWrite},
+			expectedCalls: 1,
+			expectedErr:   errRetryableWrite,
+		},
+		{
+			name:          "write retried with retryable label",
+			ctx:           WithRetry(context.Background(), RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, RetryWrites: true}),
+			kind:          opWrite,
+			errs:          []error{errRetryableWrite},
+			expectedCalls: 2,
+		},
+		{
+			name:          "non-transient error not retried",
+			ctx:           WithRetry(context.Background(), fast),
+			kind:          opRead,
+			errs:          []error{errBadValue},
+			expectedCalls: 1,
+			expectedErr:   errBadValue,
+		},
+		{
+			name:          "max elapsed prevents retry",
+			ctx:           WithRetry(context.Background(), RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour, MaxElapsed: time.Second}),
+			kind:          opRead,
+			errs:          []error{errElection},
+			expectedCalls: 1,
+			expectedErr:   errElection,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := retry(tt.ctx, "users", "Find", tt.kind, failing(&calls, tt.errs...))
+			if calls != tt.expectedCalls {
+				t.Errorf("expected %d calls, got %d", tt.expectedCalls, calls)
+			}
+			if tt.expectedErr == nil && err != nil {
+				t.Errorf("expected success, got %v", err)
+			}
+			if tt.expectedErr != nil && !errors.Is(err, tt.expectedErr) {
+				t.Errorf("expected %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestRetryHooks(t *testing.T) {
+	var events []RetryEvent
+	ctx := WithRetry(context.Background(), RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		OnRetry:        func(e RetryEvent) { events = append(events, e) },
+	})
+
+	calls := 0
+	if err := retry(ctx, "users", "FindOne", opRead, failing(&calls, errElection, errNetwork)); err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 retry events, got %d", len(events))
+	}
+	for i, e := range events {
+		if e.Collection != "users" || e.Op != "FindOne" || e.Attempt != i+1 {
+			t.Errorf("unexpected event %+v", e)
+		}
+	}
+	if !errors.Is(events[1].Err, errNetwork) {
+		t.Errorf("expected second event error %v, got %v", errNetwork, events[1].Err)
+	}
+}
+
+func TestRetryStopsOnContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(WithRetry(context.Background(), RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Hour}))
+	cancel()
+
+	calls := 0
+	err := retry(ctx, "users", "Find", opRead, failing(&calls, errElection, errElection))
+	if calls != 1 {
+		t.Errorf("expected 1 call, got %d", calls)
+	}
+	if !errors.Is(err, errElection) {
+		t.Errorf("expected %v, got %v", errElection, err)
+	}
+}
+
+func TestRetrySkipsTransactions(t *testing.T) {
+	client, err := mongo.Connect(options.Client())
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	defer client.Disconnect(context.Background())
+
+	session, err := client.StartSession()
+	if err != nil {
+		t.Fatalf("StartSession failed: %v", err)
+	}
+	defer session.EndSession(context.Background())
+	if err := session.StartTransaction(); err != nil {
+		t.Fatalf("StartTransaction failed: %v", err)
+	}
+
+	ctx := WithRetry(context.Background(), RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+	ctx = mongo.NewSessionContext(ctx, session)
+
+	calls := 0
+	retry(ctx, "users", "Find", opRead, failing(&calls, errElection))
+	if calls != 1 {
+		t.Errorf("expected no retries inside a transaction, got %d calls", calls)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 2}
+
+	tests := []struct {
+		retry int
+		max   time.Duration
+	}{
+		{retry: 1, max: 100 * time.Millisecond},
+		{retry: 2, max: 200 * time.Millisecond},
+		{retry: 3, max: 400 * time.Millisecond},
+		{retry: 10, max: time.Second},
+	}
+
+	for _, tt := range tests {
+		for range 20 {
+			delay := policy.backoff(tt.retry)
+			if delay < tt.max/2 || delay > tt.max {
+				t.Errorf("retry %d: expected delay in [%v, %v], got %v", tt.retry, tt.max/2, tt.max, delay)
+			}
+		}
+	}
+}
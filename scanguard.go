@@ -0,0 +1,167 @@
+package monarch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// ErrUnindexedQuery is matched by the errors of queries rejected by the scan guard.
+var ErrUnindexedQuery = errors.New("monarch: unindexed query")
+
+// ScanGuardMode selects what the scan guard does with unindexed queries.
+type ScanGuardMode int
+
+const (
+	// ScanGuardError fails unindexed queries with a *ScanError before they run.
+	ScanGuardError ScanGuardMode = iota
+	// ScanGuardWarn logs unindexed queries and runs them.
+	ScanGuardWarn
+	// ScanGuardOff disables the scan guard, including when enabled by the environment.
+	ScanGuardOff
+)
+
+// ScanGuardConfig configures the scan guard enabled with WithScanGuard.
+type ScanGuardConfig struct {
+	// Mode selects whether unindexed queries fail or are logged. Defaults to ScanGuardError.
+	Mode ScanGuardMode
+	// MaxExaminedRatio, if positive, also reports queries examining more than this
+	// many documents per document returned. Checking it runs each query twice, once
+	// to collect execution statistics. Zero only reports collection scans.
+	MaxExaminedRatio float64
+	// Exempt are the names of collections that are not checked, such as small lookup
+	// collections that are expected to be scanned.
+	Exempt []string
+	// Logger receives the warnings of ScanGuardWarn and the failures to explain a
+	// query. Defaults to slog.Default().
+	Logger *slog.Logger
+}
+
+// ScanError reports a query rejected by the scan guard.
+type ScanError struct {
+	// Collection is the name of the collection the operation was executed on.
+	Collection string
+	// Op is the name of the monarch operation, e.g. "Find".
+	Op string
+	// Filter is the filter of the operation, as sent to the server.
+	Filter bson.Raw
+	// Plan is the explained plan of the query.
+	Plan *ExplainResult
+}
+
+func (e *ScanError) Error() string {
+	if e.Plan.CollectionScan {
+		return fmt.Sprintf("monarch: %s.%s: query scans the collection, filter %s", e.Collection, e.Op, e.Filter)
+	}
+	return fmt.Sprintf("monarch: %s.%s: query examined %d documents for %d returned, filter %s",
+		e.Collection, e.Op, e.Plan.DocsExamined, e.Plan.DocsReturned, e.Filter)
+}
+
+func (e *ScanError) Is(target error) bool {
+	return target == ErrUnindexedQuery
+}
+
+type scanGuardKey struct{}
+
+// WithScanGuard returns a new context with the scan guard attached.
+//
+// The scan guard is a development aid that catches missing indexes: before running
+// Find, FindSeq, FindOne, the FindOneAnd operations, Exists, CountDocuments and the
+// update, replace and delete operations, it explains a find with the same filter and
+// reports queries whose plan scans the whole collection, or examines too many
+// documents per document returned. Queries with an empty filter, which are expected
+// to scan, and operations inside a transaction, which cannot be explained, are not
+// checked.
+//
+// The scan guard can also be enabled for every context without one by setting the
+// MONARCH_SCAN_GUARD environment variable to "error" or "warn". When it is disabled,
+// operations only pay for a context lookup.
+func WithScanGuard(ctx context.Context, config ScanGuardConfig) context.Context {
+	return context.WithValue(ctx, scanGuardKey{}, config)
+}
+
+// envScanGuard returns the scan guard configured by the MONARCH_SCAN_GUARD environment variable.
+var envScanGuard = sync.OnceValue(func() ScanGuardConfig {
+	switch strings.ToLower(os.Getenv("MONARCH_SCAN_GUARD")) {
+	case "error", "1", "true":
+		return ScanGuardConfig{Mode: ScanGuardError}
+	case "warn":
+		return ScanGuardConfig{Mode: ScanGuardWarn}
+	default:
+		return ScanGuardConfig{Mode: ScanGuardOff}
+	}
+})
+
+// scanGuardFor returns the scan guard configuration in ctx or the environment, or
+// false if the collection is not checked.
+func scanGuardFor(ctx context.Context, collection string) (ScanGuardConfig, bool) {
+	config, ok := ctx.Value(scanGuardKey{}).(ScanGuardConfig)
+	if !ok {
+		config = envScanGuard()
+	}
+	if config.Mode == ScanGuardOff || slices.Contains(config.Exempt, collection) {
+		return config, false
+	}
+	return config, true
+}
+
+// guardFilter prepares filter like prepareFilter, and checks the query with the scan
+// guard in the context. Operations on at most one document are explained with a limit
+// of one, so that stopping at the first match is not reported as a scan.
+func (c Collection[T]) guardFilter(ctx context.Context, op string, filter any, single bool) (any, error) {
+	prepared, err := c.prepareFilter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	config, ok := scanGuardFor(ctx, string(c))
+	if !ok || inTransaction(ctx) {
+		return prepared, nil
+	}
+	if filter == nil {
+		return prepared, nil
+	}
+	if raw, err := bson.Marshal(filter); err == nil && len(raw) <= 5 {
+		// An empty document is expected to scan the collection.
+		return prepared, nil
+	}
+
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	verbosity := ExplainQueryPlanner
+	if config.MaxExaminedRatio > 0 {
+		verbosity = ExplainExecutionStats
+	}
+	cmd := bson.D{{Key: "find", Value: string(c)}, {Key: "filter", Value: prepared}}
+	if single {
+		cmd = append(cmd, bson.E{Key: "limit", Value: int64(1)})
+	}
+	plan, err := c.explain(ctx, "ScanGuard", verbosity, cmd)
+	if err != nil {
+		logger.WarnContext(ctx, "monarch: scan guard cannot explain query", "collection", string(c), "op", op, "error", err)
+		return prepared, nil
+	}
+
+	unindexed := plan.CollectionScan
+	if config.MaxExaminedRatio > 0 && plan.Executed {
+		unindexed = unindexed || float64(plan.DocsExamined) > config.MaxExaminedRatio*float64(max(plan.DocsReturned, 1))
+	}
+	if !unindexed {
+		return prepared, nil
+	}
+	raw, _ := bson.Marshal(prepared)
+	scanErr := &ScanError{Collection: string(c), Op: op, Filter: raw, Plan: plan}
+	if config.Mode == ScanGuardWarn {
+		logger.WarnContext(ctx, scanErr.Error(), "collection", string(c), "op", op, "filter", bson.Raw(raw).String())
+		return prepared, nil
+	}
+	return nil, scanErr
+}
@@ -0,0 +1,112 @@
+package monarch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+func TestScanGuardFor(t *testing.T) {
+	if _, ok := scanGuardFor(context.Background(), "users"); ok && envScanGuard().Mode == ScanGuardOff {
+		t.Error("expected scan guard to be disabled without configuration")
+	}
+
+	ctx := WithScanGuard(context.Background(), ScanGuardConfig{Exempt: []string{"settings"}})
+	if config, ok := scanGuardFor(ctx, "users"); !ok || config.Mode != ScanGuardError {
+		t.Errorf("expected scan guard in error mode, got %+v, %v", config, ok)
+	}
+	if _, ok := scanGuardFor(ctx, "settings"); ok {
+		t.Error("expected exempt collection not to be checked")
+	}
+	if _, ok := scanGuardFor(WithScanGuard(ctx, ScanGuardConfig{Mode: ScanGuardOff}), "users"); ok {
+		t.Error("expected ScanGuardOff to disable the scan guard")
+	}
+}
+
+func TestScanError(t *testing.T) {
+	filter := mustMarshal(t, bson.D{{Key: "age", Value: 30}})
+	err := error(&ScanError{Collection: "users", Op: "Find", Filter: filter, Plan: &ExplainResult{CollectionScan: true}})
+	if !errors.Is(err, ErrUnindexedQuery) {
+		t.Error("expected ScanError to match ErrUnindexedQuery")
+	}
+	if msg := err.Error(); !strings.Contains(msg, "users.Find: query scans the collection") || !strings.Contains(msg, `"age"`) {
+		t.Errorf("unexpected message %q", msg)
+	}
+
+	err = &ScanError{Collection: "users", Op: "Find", Filter: filter, Plan: &ExplainResult{DocsExamined: 100, DocsReturned: 1}}
+	if msg := err.Error(); !strings.Contains(msg, "examined 100 documents for 1 returned") {
+		t.Errorf("unexpected message %q", msg)
+	}
+}
+
+func TestScanGuard(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := WithContext(context.Background(), db)
+
+	cleanupCollection(t, ctx, Users)
+
+	users := []User{{ID: "1", Name: "Alice", Age: 30}, {ID: "2", Name: "Bob", Age: 25}, {ID: "3", Name: "Carol", Age: 25}}
+	if _, err := Users.InsertMany(ctx, users); err != nil {
+		t.Fatalf("InsertMany failed: %v", err)
+	}
+	if _, err := Users.CreateIndexes(ctx, []mongo.IndexModel{{
+		Keys:    bson.D{{Key: "name", Value: 1}},
+		Options: options.Index().SetName("scan_guard_name"),
+	}}); err != nil {
+		t.Fatalf("CreateIndexes failed: %v", err)
+	}
+
+	t.Run("error mode rejects collection scans", func(t *testing.T) {
+		ctx := WithScanGuard(ctx, ScanGuardConfig{})
+
+		if _, err := Users.Find(ctx, bson.M{"name": "Alice"}); err != nil {
+			t.Errorf("expected indexed query to run, got %v", err)
+		}
+		if _, err := Users.Find(ctx, bson.M{}); err != nil {
+			t.Errorf("expected query without filter to run, got %v", err)
+		}
+
+		_, err := Users.Find(ctx, bson.M{"age": 25})
+		var scanErr *ScanError
+		if !errors.As(err, &scanErr) || scanErr.Op != "Find" || !scanErr.Plan.CollectionScan {
+			t.Fatalf("expected ScanError, got %v", err)
+		}
+		if _, err := Users.DeleteMany(ctx, bson.M{"age": 99}); !errors.Is(err, ErrUnindexedQuery) {
+			t.Errorf("expected DeleteMany to be rejected, got %v", err)
+		}
+		if _, err := Users.CountDocuments(ctx, bson.M{"age": 25}); !errors.Is(err, ErrUnindexedQuery) {
+			t.Errorf("expected CountDocuments to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("warn mode logs and runs", func(t *testing.T) {
+		var logs bytes.Buffer
+		ctx := WithScanGuard(ctx, ScanGuardConfig{Mode: ScanGuardWarn, Logger: slog.New(slog.NewTextHandler(&logs, nil))})
+
+		found, err := Users.Find(ctx, bson.M{"age": 25})
+		if err != nil || len(found) != 2 {
+			t.Fatalf("expected query to run, got %v, %v", found, err)
+		}
+		if !strings.Contains(logs.String(), "query scans the collection") {
+			t.Errorf("expected warning, got %q", logs.String())
+		}
+	})
+
+	t.Run("examined ratio", func(t *testing.T) {
+		ctx := WithScanGuard(ctx, ScanGuardConfig{MaxExaminedRatio: 2})
+		if _, err := Users.Find(ctx, bson.M{"name": bson.M{"$gte": "A"}, "age": 30}); !errors.Is(err, ErrUnindexedQuery) {
+			t.Errorf("expected query examining 3 documents for 1 to be rejected, got %v", err)
+		}
+		if _, err := Users.FindOne(ctx, bson.M{"name": "Alice", "age": 30}); err != nil {
+			t.Errorf("expected selective query to run, got %v", err)
+		}
+	})
+}
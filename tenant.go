@@ -0,0 +1,331 @@
+package monarch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// ErrNoTenant is returned by operations on tenant-scoped collections when the context
+// has no tenant and is not marked with CrossTenant.
+var ErrNoTenant = errors.New("monarch: tenant not found in context")
+
+type tenantKey struct{}
+
+type crossTenantKey struct{}
+
+// WithTenant returns a new context with the tenant attached, such as the ID of the
+// organization of the authenticated user.
+//
+// Collections of document types with a field tagged `monarch:"tenant"` are tenant-scoped:
+//
+//	type Project struct {
+//	    ID       bson.ObjectID `bson:"_id"`
+//	    TenantID string        `bson:"tenantId" monarch:"tenant"`
+//	    Name     string        `bson:"name"`
+//	}
+//
+// Operations on tenant-scoped collections only match documents of the tenant in the
+// context: the tenant condition is ANDed into filters and into the first $match stage of
+// aggregation pipelines, and the tenant field of inserted and replaced documents is set
+// to the tenant. Updates may not change the tenant field. Change streams opened by Watch
+// only return the events of documents of the tenant. Versions stored by WithHistory and
+// records stored by WithAudit hold the tenant of their document, and History, AsOf,
+// Revert and AuditHistory only return those of the tenant. They return ErrNoTenant if
+// the context has no tenant, so that a missing tenant cannot expose the documents of
+// every tenant.
+//
+// The tenant must be assignable to the tenant field. EstimatedCount cannot be scoped
+// and returns an error on tenant-scoped collections unless the context is marked
+// with CrossTenant.
+func WithTenant(ctx context.Context, tenant any) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// Tenant returns the tenant attached to the context with WithTenant, or false.
+func Tenant(ctx context.Context) (any, bool) {
+	tenant := ctx.Value(tenantKey{})
+	return tenant, tenant != nil
+}
+
+// CrossTenant returns a new context in which operations on tenant-scoped collections
+// are not scoped, for administrative and background work spanning tenants. Documents
+// are then written with the tenant field they hold.
+func CrossTenant(ctx context.Context) context.Context {
+	return context.WithValue(ctx, crossTenantKey{}, true)
+}
+
+func isCrossTenant(ctx context.Context) bool {
+	cross, _ := ctx.Value(crossTenantKey{}).(bool)
+	return cross
+}
+
+// tenantField returns the field of the struct type t tagged `monarch:"tenant"`, or false.
+func tenantField(t reflect.Type) (structField, bool) {
+	t = indirectType(t)
+	if t.Kind() != reflect.Struct {
+		return structField{}, false
+	}
+	for _, f := range structFields(t) {
+		if _, ok := f.tag["tenant"]; ok {
+			return f, true
+		}
+	}
+	return structField{}, false
+}
+
+// tenant returns the tenant field of T and the tenant in ctx, or false if the
+// collection is not tenant-scoped or ctx is marked with CrossTenant.
+func (c Collection[T]) tenant(ctx context.Context) (structField, any, bool, error) {
+	field, ok := tenantField(reflect.TypeFor[T]())
+	if !ok || isCrossTenant(ctx) {
+		return field, nil, false, nil
+	}
+	tenant, ok := Tenant(ctx)
+	if !ok {
+		return field, nil, false, fmt.Errorf("%w: collection %q is tenant-scoped", ErrNoTenant, string(c))
+	}
+	return field, tenant, true, nil
+}
+
+// tenantCondition returns the filter matching the documents of the tenant in ctx,
+// or nil if the collection is not scoped.
+func (c Collection[T]) tenantCondition(ctx context.Context) (bson.D, error) {
+	field, tenant, ok, err := c.tenant(ctx)
+	if !ok {
+		return nil, err
+	}
+	return bson.D{{Key: field.name, Value: tenant}}, nil
+}
+
+// scopeRecords returns filter with a condition on the tenant of the records stored
+// for the history and audit trail of c, which have no tenant field of their own.
+func (c Collection[T]) scopeRecords(ctx context.Context, filter bson.D) (bson.D, error) {
+	_, tenant, ok, err := c.tenant(ctx)
+	if !ok {
+		return filter, err
+	}
+	return append(filter, bson.E{Key: "tenant", Value: tenant}), nil
+}
+
+// documentTenant returns the tenant field of the raw document, or the zero value if
+// the collection is not tenant-scoped or doc has no tenant.
+func (c Collection[T]) documentTenant(doc bson.Raw) bson.RawValue {
+	field, ok := tenantField(reflect.TypeFor[T]())
+	if !ok || doc == nil {
+		return bson.RawValue{}
+	}
+	tenant, _ := doc.LookupErr(strings.Split(field.name, ".")...)
+	return tenant
+}
+
+// scopeWatch returns the change stream pipeline with a first $match stage matching
+// the events of documents of the tenant in ctx, by their full document, pre-image
+// or document key.
+func (c Collection[T]) scopeWatch(ctx context.Context, pipeline any) (any, error) {
+	field, tenant, ok, err := c.tenant(ctx)
+	if !ok {
+		return pipeline, err
+	}
+	stages, err := pipelineStages(pipeline)
+	if err != nil {
+		return nil, err
+	}
+	match := bson.D{{Key: "$match", Value: bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "fullDocument." + field.name, Value: tenant}},
+		bson.D{{Key: "fullDocumentBeforeChange." + field.name, Value: tenant}},
+		bson.D{{Key: "documentKey." + field.name, Value: tenant}},
+	}}}}}
+	return append(bson.A{match}, stages...), nil
+}
+
+// checkTenantUpdate returns an error if update may write the tenant field with another
+// value than the tenant in ctx, which would move documents out of the tenant.
+// The tenant field may only be set to the tenant by $set and $setOnInsert.
+func (c Collection[T]) checkTenantUpdate(ctx context.Context, update any) error {
+	field, tenant, ok, err := c.tenant(ctx)
+	if !ok {
+		return err
+	}
+	typ, data, err := bson.MarshalValue(tenant)
+	if err != nil {
+		return err
+	}
+	want := bson.RawValue{Type: typ, Value: data}
+	moved := fmt.Errorf("monarch: update cannot change tenant field %q of tenant-scoped collection %q", field.name, string(c))
+
+	check := func(op string, fields bson.RawValue) error {
+		if op == "$unset" && fields.Type != bson.TypeEmbeddedDocument {
+			// The $unset stage of pipelines lists field names.
+			names := []bson.RawValue{fields}
+			if arr, ok := fields.ArrayOK(); ok {
+				names, _ = arr.Values()
+			}
+			for _, name := range names {
+				if path, ok := name.StringValueOK(); ok && pathsOverlap(path, field.name) {
+					return moved
+				}
+			}
+			return nil
+		}
+		doc, ok := fields.DocumentOK()
+		if !ok {
+			return nil
+		}
+		elems, err := doc.Elements()
+		if err != nil {
+			return err
+		}
+		for _, elem := range elems {
+			path, value := elem.Key(), elem.Value()
+			if target, ok := value.StringValueOK(); ok && op == "$rename" && pathsOverlap(target, field.name) {
+				return moved
+			}
+			if !pathsOverlap(path, field.name) {
+				continue
+			}
+			setsTenant := op == "$set" || op == "$setOnInsert" || op == "$addFields"
+			if !setsTenant || path != field.name || !value.Equal(want) {
+				return moved
+			}
+		}
+		return nil
+	}
+
+	if isPipeline(update) {
+		stages, err := pipelineStages(update)
+		if err != nil {
+			return err
+		}
+		for _, stage := range stages {
+			raw, err := bson.Marshal(stage)
+			if err != nil {
+				return err
+			}
+			elems, err := bson.Raw(raw).Elements()
+			if err != nil {
+				return err
+			}
+			for _, elem := range elems {
+				switch op := elem.Key(); op {
+				case "$set", "$addFields", "$unset":
+					if err := check(op, elem.Value()); err != nil {
+						return err
+					}
+				default:
+					// $project, $replaceRoot and $replaceWith may write any field.
+					return moved
+				}
+			}
+		}
+		return nil
+	}
+
+	raw, err := bson.Marshal(update)
+	if err != nil {
+		return err
+	}
+	elems, err := bson.Raw(raw).Elements()
+	if err != nil {
+		return err
+	}
+	for _, elem := range elems {
+		if err := check(elem.Key(), elem.Value()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scopeFilter returns filter ANDed with the tenant condition.
+func (c Collection[T]) scopeFilter(ctx context.Context, filter any) (any, error) {
+	cond, err := c.tenantCondition(ctx)
+	if cond == nil || err != nil {
+		return filter, err
+	}
+	if filter == nil {
+		return cond, nil
+	}
+	return bson.D{{Key: "$and", Value: bson.A{cond, filter}}}, nil
+}
+
+// firstStages are the aggregation stages that must be the first stage of a pipeline.
+var firstStages = map[string]bool{
+	"$geoNear":      true,
+	"$search":       true,
+	"$searchMeta":   true,
+	"$vectorSearch": true,
+	"$collStats":    true,
+	"$indexStats":   true,
+	"$documents":    true,
+}
+
+// scopePipeline returns pipeline with the tenant condition ANDed into its first $match
+// stage, or in a new $match stage placed first, after any stage that must come first.
+func (c Collection[T]) scopePipeline(ctx context.Context, pipeline any) (any, error) {
+	cond, err := c.tenantCondition(ctx)
+	if cond == nil || err != nil {
+		return pipeline, err
+	}
+	v := reflect.Indirect(reflect.ValueOf(pipeline))
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("monarch: cannot scope pipeline of type %T to the tenant", pipeline)
+	}
+	stages := make(bson.A, 0, v.Len()+1)
+	for i := range v.Len() {
+		stages = append(stages, v.Index(i).Interface())
+	}
+
+	pos := 0
+	if len(stages) > 0 {
+		raw, err := bson.Marshal(stages[0])
+		if err != nil {
+			return nil, err
+		}
+		elem, err := bson.Raw(raw).IndexErr(0)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case elem.Key() == "$match":
+			stages[0] = bson.D{{Key: "$match", Value: bson.D{{Key: "$and", Value: bson.A{cond, elem.Value()}}}}}
+			return stages, nil
+		case firstStages[elem.Key()]:
+			pos = 1
+		}
+	}
+	return slices.Insert(stages, pos, any(bson.D{{Key: "$match", Value: cond}})), nil
+}
+
+// stampTenant returns doc with its tenant field set to the tenant in ctx.
+// Documents are returned unchanged if the collection is not scoped.
+func (c Collection[T]) stampTenant(ctx context.Context, doc T) (T, error) {
+	field, tenant, ok, err := c.tenant(ctx)
+	if !ok {
+		return doc, err
+	}
+
+	v := reflect.ValueOf(&doc).Elem()
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return doc, nil
+		}
+		// Stamp a copy so that the caller's document is not modified.
+		copied := reflect.New(v.Type().Elem())
+		copied.Elem().Set(v.Elem())
+		v.Set(copied)
+		v = copied.Elem()
+	}
+	f := v.FieldByIndex(field.index)
+	tv := reflect.ValueOf(tenant)
+	if !tv.Type().AssignableTo(f.Type()) {
+		return doc, fmt.Errorf("monarch: tenant of type %T cannot be assigned to field %q of type %s", tenant, field.name, f.Type())
+	}
+	f.Set(tv)
+	return doc, nil
+}
@@ -0,0 +1,257 @@
+package monarch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type Project struct {
+	ID       string `bson:"_id"`
+	TenantID string `bson:"tenantId" monarch:"tenant"`
+	Name     string `bson:"name"`
+}
+
+func (p Project) DocumentID() string { return p.ID }
+
+var Projects Collection[Project] = "projects"
+
+func TestScopeFilter(t *testing.T) {
+	ctx := WithTenant(context.Background(), "acme")
+
+	filter, err := Projects.scopeFilter(ctx, bson.M{"name": "x"})
+	if err != nil {
+		t.Fatalf("scopeFilter failed: %v", err)
+	}
+	raw := mustMarshal(t, filter)
+	if got := raw.Lookup("$and", "0", "tenantId").StringValue(); got != "acme" {
+		t.Errorf("expected tenant condition, got %v", raw)
+	}
+	if got := raw.Lookup("$and", "1", "name").StringValue(); got != "x" {
+		t.Errorf("expected original filter, got %v", raw)
+	}
+
+	if _, err := Projects.scopeFilter(context.Background(), bson.M{}); !errors.Is(err, ErrNoTenant) {
+		t.Errorf("expected ErrNoTenant, got %v", err)
+	}
+	if filter, err := Projects.scopeFilter(CrossTenant(context.Background()), bson.M{}); err != nil || len(filter.(bson.M)) != 0 {
+		t.Errorf("expected unscoped filter across tenants, got %v, %v", filter, err)
+	}
+	if filter, err := Users.scopeFilter(context.Background(), bson.M{}); err != nil || len(filter.(bson.M)) != 0 {
+		t.Errorf("expected collection without tenant field to be unscoped, got %v, %v", filter, err)
+	}
+}
+
+func TestScopePipeline(t *testing.T) {
+	ctx := WithTenant(context.Background(), "acme")
+
+	tests := []struct {
+		name     string
+		pipeline bson.A
+		stage    int
+		stages   int
+	}{
+		{"empty", bson.A{}, 0, 1},
+		{"match", bson.A{bson.D{{Key: "$match", Value: bson.D{{Key: "name", Value: "x"}}}}}, 0, 1},
+		{"other", bson.A{bson.D{{Key: "$sort", Value: bson.D{{Key: "name", Value: 1}}}}}, 0, 2},
+		{"first stage", bson.A{bson.D{{Key: "$geoNear", Value: bson.D{}}}}, 1, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline, err := Projects.scopePipeline(ctx, tt.pipeline)
+			if err != nil {
+				t.Fatalf("scopePipeline failed: %v", err)
+			}
+			stages := pipeline.(bson.A)
+			if len(stages) != tt.stages {
+				t.Fatalf("expected %d stages, got %d", tt.stages, len(stages))
+			}
+			raw := mustMarshal(t, stages[tt.stage])
+			match := raw.Lookup("$match")
+			if v, err := match.Document().LookupErr("tenantId"); err == nil && v.StringValue() == "acme" {
+				return
+			}
+			if v := match.Document().Lookup("$and", "0", "tenantId"); v.StringValue() != "acme" {
+				t.Errorf("expected tenant condition in stage %d, got %v", tt.stage, raw)
+			}
+		})
+	}
+}
+
+func TestCheckTenantUpdate(t *testing.T) {
+	ctx := WithTenant(context.Background(), "acme")
+
+	for name, update := range map[string]any{
+		"$set other tenant": bson.D{{Key: "$set", Value: bson.D{{Key: "tenantId", Value: "globex"}}}},
+		"$unset":            bson.D{{Key: "$unset", Value: bson.D{{Key: "tenantId", Value: ""}}}},
+		"$rename from":      bson.D{{Key: "$rename", Value: bson.D{{Key: "tenantId", Value: "owner"}}}},
+		"$rename to":        bson.D{{Key: "$rename", Value: bson.D{{Key: "owner", Value: "tenantId"}}}},
+		"pipeline $set":     bson.A{bson.D{{Key: "$set", Value: bson.D{{Key: "tenantId", Value: "$name"}}}}},
+		"pipeline $unset":   bson.A{bson.D{{Key: "$unset", Value: bson.A{"name", "tenantId"}}}},
+		"replaceWith":       bson.A{bson.D{{Key: "$replaceWith", Value: "$$ROOT"}}},
+	} {
+		if err := Projects.checkTenantUpdate(ctx, update); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	for name, update := range map[string]any{
+		"other field":  bson.D{{Key: "$set", Value: bson.D{{Key: "name", Value: "x"}}}},
+		"same tenant":  bson.D{{Key: "$set", Value: bson.D{{Key: "tenantId", Value: "acme"}, {Key: "name", Value: "x"}}}},
+		"pipeline":     bson.A{bson.D{{Key: "$set", Value: bson.D{{Key: "name", Value: "x"}}}}, bson.D{{Key: "$unset", Value: "name"}}},
+		"setOnInsert":  bson.M{"$setOnInsert": bson.M{"tenantId": "acme"}},
+		"$inc sibling": bson.M{"$inc": bson.M{"tenantIdCount": 1}},
+	} {
+		if err := Projects.checkTenantUpdate(ctx, update); err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		}
+	}
+
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "tenantId", Value: "globex"}}}}
+	if err := Projects.checkTenantUpdate(CrossTenant(ctx), update); err != nil {
+		t.Errorf("expected tenant changes across tenants, got %v", err)
+	}
+}
+
+func TestScopeWatch(t *testing.T) {
+	ctx := WithTenant(context.Background(), "acme")
+
+	pipeline, err := Projects.scopeWatch(ctx, bson.A{bson.D{{Key: "$match", Value: bson.D{{Key: "operationType", Value: "insert"}}}}})
+	if err != nil {
+		t.Fatalf("scopeWatch failed: %v", err)
+	}
+	stages := pipeline.(bson.A)
+	if len(stages) != 2 {
+		t.Fatalf("expected 2 stages, got %d", len(stages))
+	}
+	raw := mustMarshal(t, stages[0])
+	for i, path := range []string{"fullDocument.tenantId", "fullDocumentBeforeChange.tenantId", "documentKey.tenantId"} {
+		if got := raw.Lookup("$match", "$or", fmt.Sprint(i), path).StringValue(); got != "acme" {
+			t.Errorf("expected %s condition, got %v", path, raw)
+		}
+	}
+
+	if _, err := Projects.scopeWatch(context.Background(), bson.A{}); !errors.Is(err, ErrNoTenant) {
+		t.Errorf("expected ErrNoTenant, got %v", err)
+	}
+	if pipeline, err := Users.scopeWatch(ctx, bson.A{}); err != nil || len(pipeline.(bson.A)) != 0 {
+		t.Errorf("expected collection without tenant field to be unscoped, got %v, %v", pipeline, err)
+	}
+}
+
+func TestStampTenant(t *testing.T) {
+	ctx := WithTenant(context.Background(), "acme")
+
+	project, err := Projects.stampTenant(ctx, Project{ID: "p1", TenantID: "other"})
+	if err != nil || project.TenantID != "acme" {
+		t.Errorf("expected tenant acme, got %+v, %v", project, err)
+	}
+
+	original := &Project{ID: "p1"}
+	stamped, err := Collection[*Project]("projects").stampTenant(ctx, original)
+	if err != nil || stamped.TenantID != "acme" || original.TenantID != "" {
+		t.Errorf("expected a stamped copy, got %+v, %+v, %v", stamped, original, err)
+	}
+
+	if _, err := Projects.stampTenant(WithTenant(context.Background(), 1), Project{}); err == nil {
+		t.Errorf("expected error for tenant of the wrong type")
+	}
+	if project, err := Projects.stampTenant(CrossTenant(context.Background()), Project{TenantID: "other"}); err != nil || project.TenantID != "other" {
+		t.Errorf("expected tenant unchanged across tenants, got %+v, %v", project, err)
+	}
+}
+
+func TestTenant(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := WithContext(context.Background(), db)
+
+	cleanupCollection(t, ctx, Projects)
+
+	acme, globex := WithTenant(ctx, "acme"), WithTenant(ctx, "globex")
+	if _, err := Projects.InsertOne(acme, Project{ID: "p1", Name: "rockets"}); err != nil {
+		t.Fatalf("InsertOne failed: %v", err)
+	}
+	if _, err := Projects.InsertMany(globex, []Project{{ID: "p2", Name: "rockets"}, {ID: "p3", Name: "magnets"}}); err != nil {
+		t.Fatalf("InsertMany failed: %v", err)
+	}
+
+	projects, err := Projects.Find(acme, bson.M{"name": "rockets"})
+	if err != nil || len(projects) != 1 || projects[0].ID != "p1" || projects[0].TenantID != "acme" {
+		t.Errorf("expected only the acme project, got %+v, %v", projects, err)
+	}
+	if count, err := Projects.CountDocuments(globex, bson.M{}); err != nil || count != 2 {
+		t.Errorf("expected 2 globex projects, got %d, %v", count, err)
+	}
+	if _, err := Projects.FindOne(acme, bson.M{"_id": "p2"}); err == nil {
+		t.Errorf("expected globex project to be invisible to acme")
+	}
+
+	if result, err := Projects.UpdateMany(acme, bson.M{}, bson.M{"$set": bson.M{"name": "updated"}}); err != nil || result.ModifiedCount != 1 {
+		t.Errorf("expected to update 1 acme project, got %+v, %v", result, err)
+	}
+	if result, err := Projects.DeleteMany(acme, bson.M{"_id": "p2"}); err != nil || result.DeletedCount != 0 {
+		t.Errorf("expected not to delete the globex project, got %+v, %v", result, err)
+	}
+
+	type byName struct {
+		Name  string `bson:"_id"`
+		Count int    `bson:"count"`
+	}
+	groups, err := AggregateAs[byName](globex, Projects, bson.A{
+		bson.D{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$name"}, {Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}}}}},
+	})
+	if err != nil || len(groups) != 2 {
+		t.Errorf("expected 2 globex groups, got %+v, %v", groups, err)
+	}
+
+	if _, err := Projects.Find(ctx, bson.M{}); !errors.Is(err, ErrNoTenant) {
+		t.Errorf("expected ErrNoTenant, got %v", err)
+	}
+	if count, err := Projects.CountDocuments(CrossTenant(ctx), bson.M{}); err != nil || count != 3 {
+		t.Errorf("expected 3 projects across tenants, got %d, %v", count, err)
+	}
+	if _, err := Projects.UpdateOne(acme, bson.M{"_id": "p1"}, bson.M{"$set": bson.M{"tenantId": "globex"}}); err == nil {
+		t.Errorf("expected moving a project to another tenant to fail")
+	}
+}
+
+func TestTenantHistory(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := WithContext(context.Background(), db)
+	ctx = WithHistory(ctx, HistoryConfig{Collections: []string{string(Projects)}})
+	ctx = WithAudit(ctx, AuditConfig{Collection: "projects_audit", Collections: []string{string(Projects)}})
+
+	cleanupCollection(t, ctx, Projects)
+	cleanupCollection(t, ctx, Projects.history())
+	cleanupCollection(t, ctx, Collection[AuditRecord]("projects_audit"))
+
+	acme, globex := WithTenant(ctx, "acme"), WithTenant(ctx, "globex")
+	if _, err := Projects.InsertOne(acme, Project{ID: "shared", Name: "v1"}); err != nil {
+		t.Fatalf("InsertOne failed: %v", err)
+	}
+	if _, err := Projects.UpdateOne(acme, bson.M{"_id": "shared"}, bson.M{"$set": bson.M{"name": "v2"}}); err != nil {
+		t.Fatalf("UpdateOne failed: %v", err)
+	}
+
+	if versions, err := Projects.History(acme, "shared"); err != nil || len(versions) != 1 || versions[0].Tenant.StringValue() != "acme" {
+		t.Errorf("expected 1 acme version, got %+v, %v", versions, err)
+	}
+	if versions, err := Projects.History(globex, "shared"); err != nil || len(versions) != 0 {
+		t.Errorf("expected no globex versions, got %+v, %v", versions, err)
+	}
+	if _, err := Projects.Revert(globex, "shared", 1); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Errorf("expected globex not to revert acme versions, got %v", err)
+	}
+	if records, err := AuditHistory(acme, Projects, "shared"); err != nil || len(records) != 2 {
+		t.Errorf("expected 2 acme audit records, got %+v, %v", records, err)
+	}
+	if records, err := AuditHistory(globex, Projects, "shared"); err != nil || len(records) != 0 {
+		t.Errorf("expected no globex audit records, got %+v, %v", records, err)
+	}
+}
@@ -0,0 +1,424 @@
+package monarch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Granularity is the expected interval between measurements of a time-series
+// collection with the same meta field value, used to size its internal buckets.
+type Granularity string
+
+const (
+	GranularitySeconds Granularity = "seconds"
+	GranularityMinutes Granularity = "minutes"
+	GranularityHours   Granularity = "hours"
+)
+
+// TimeSeries declares a time-series collection.
+//
+//	type Measurement struct {
+//	    Time   time.Time `bson:"ts"`
+//	    Sensor string    `bson:"sensor"`
+//	    Value  float64   `bson:"value"`
+//	}
+//
+//	var Measurements monarch.Collection[Measurement] = "measurements"
+//
+//	var MeasurementSeries = monarch.TimeSeries{
+//	    TimeField:   "ts",
+//	    MetaField:   "sensor",
+//	    Granularity: monarch.GranularityMinutes,
+//	    ExpireAfter: 30 * 24 * time.Hour,
+//	}
+type TimeSeries struct {
+	// TimeField is the field holding the time of each measurement. It must be a
+	// time.Time or bson.DateTime field of the document type.
+	TimeField string
+	// MetaField is the field holding the metadata identifying the series of a
+	// measurement, such as a sensor ID. It must be a field of the document type.
+	// Optional.
+	MetaField string
+	// Granularity sizes buckets for the interval between measurements of a series.
+	// It cannot be combined with BucketMaxSpan.
+	Granularity Granularity
+	// BucketMaxSpan is the maximum time span of a bucket, for custom bucketing.
+	// Buckets are also rounded down to it. Requires MongoDB 6.3 or later.
+	BucketMaxSpan time.Duration
+	// ExpireAfter, if positive, removes measurements once they are older than it.
+	ExpireAfter time.Duration
+}
+
+// validate checks the time-series declaration against the struct fields of t.
+func (ts TimeSeries) validate(t reflect.Type) error {
+	if ts.TimeField == "" {
+		return errors.New("monarch: time-series collection requires a time field")
+	}
+	if ts.Granularity != "" && ts.BucketMaxSpan != 0 {
+		return errors.New("monarch: time-series granularity cannot be combined with a bucket max span")
+	}
+	t = indirectType(t)
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	field, ok := lookupField(t, ts.TimeField)
+	if !ok {
+		return fmt.Errorf("monarch: time field %q not found in %s", ts.TimeField, t)
+	}
+	if ft := indirectType(field.field.Type); ft != reflect.TypeFor[time.Time]() && ft != reflect.TypeFor[bson.DateTime]() {
+		return fmt.Errorf("monarch: time field %q has type %s, want time.Time or bson.DateTime", ts.TimeField, field.field.Type)
+	}
+	if ts.MetaField == "" {
+		return nil
+	}
+	if ts.MetaField == ts.TimeField || ts.MetaField == "_id" {
+		return fmt.Errorf("monarch: meta field %q cannot be the time field or _id", ts.MetaField)
+	}
+	if _, ok := lookupField(t, ts.MetaField); !ok {
+		return fmt.Errorf("monarch: meta field %q not found in %s", ts.MetaField, t)
+	}
+	return nil
+}
+
+// EnsureTimeSeries creates the collection as a time-series collection declared by ts,
+// after validating ts against the fields of T.
+//
+// If the collection exists, it must be a time-series collection with the same time and
+// meta fields. Its granularity and expiration are updated to match ts, which the server
+// only allows for increasing granularities. Call it once on startup.
+func (c Collection[T]) EnsureTimeSeries(ctx context.Context, ts TimeSeries) error {
+	if err := ts.validate(reflect.TypeFor[T]()); err != nil {
+		return err
+	}
+	return c.exec(ctx, "EnsureTimeSeries", opWrite, func(collection *mongo.Collection) error {
+		db := collection.Database()
+		tso := options.TimeSeries().SetTimeField(ts.TimeField)
+		if ts.MetaField != "" {
+			tso.SetMetaField(ts.MetaField)
+		}
+		if ts.Granularity != "" {
+			tso.SetGranularity(string(ts.Granularity))
+		}
+		if ts.BucketMaxSpan != 0 {
+			tso.SetBucketMaxSpan(ts.BucketMaxSpan).SetBucketRounding(ts.BucketMaxSpan)
+		}
+		opts := options.CreateCollection().SetTimeSeriesOptions(tso)
+		if ts.ExpireAfter > 0 {
+			opts.SetExpireAfterSeconds(int64(ts.ExpireAfter / time.Second))
+		}
+		err := db.CreateCollection(ctx, string(c), opts)
+		if !isNamespaceExists(err) {
+			return err
+		}
+		return c.updateTimeSeries(ctx, db, ts)
+	})
+}
+
+// isNamespaceExists reports whether err reports that the collection already exists.
+func isNamespaceExists(err error) bool {
+	var cmdErr mongo.CommandError
+	return errors.As(err, &cmdErr) && cmdErr.Code == codeNamespaceExists
+}
+
+// codeNamespaceExists is the server error code of creating an existing collection.
+const codeNamespaceExists = 48
+
+// updateTimeSeries checks that the existing collection is the time-series collection
+// declared by ts, and updates its granularity and expiration.
+func (c Collection[T]) updateTimeSeries(ctx context.Context, db *mongo.Database, ts TimeSeries) error {
+	specs, err := db.ListCollectionSpecifications(ctx, bson.D{{Key: "name", Value: string(c)}})
+	if err != nil {
+		return err
+	}
+	if len(specs) == 0 || specs[0].Type != "timeseries" {
+		return fmt.Errorf("monarch: collection %q exists and is not a time-series collection", string(c))
+	}
+	existing := specs[0].Options
+	timeField, _ := existing.Lookup("timeseries", "timeField").StringValueOK()
+	metaField, _ := existing.Lookup("timeseries", "metaField").StringValueOK()
+	if timeField != ts.TimeField || metaField != ts.MetaField {
+		return fmt.Errorf("monarch: time-series collection %q has time field %q and meta field %q, want %q and %q",
+			string(c), timeField, metaField, ts.TimeField, ts.MetaField)
+	}
+
+	mod := bson.D{{Key: "collMod", Value: string(c)}}
+	granularity, _ := existing.Lookup("timeseries", "granularity").StringValueOK()
+	if ts.Granularity != "" && string(ts.Granularity) != granularity {
+		mod = append(mod, bson.E{Key: "timeseries", Value: bson.D{{Key: "granularity", Value: string(ts.Granularity)}}})
+	}
+	span := rawInt(existing.Lookup("timeseries", "bucketMaxSpanSeconds"))
+	if ts.BucketMaxSpan != 0 && span != int64(ts.BucketMaxSpan/time.Second) {
+		seconds := int64(ts.BucketMaxSpan / time.Second)
+		mod = append(mod, bson.E{Key: "timeseries", Value: bson.D{
+			{Key: "bucketMaxSpanSeconds", Value: seconds},
+			{Key: "bucketRoundingSeconds", Value: seconds},
+		}})
+	}
+	expire := rawInt(existing.Lookup("expireAfterSeconds"))
+	if want := int64(ts.ExpireAfter / time.Second); want != expire {
+		if want > 0 {
+			mod = append(mod, bson.E{Key: "expireAfterSeconds", Value: want})
+		} else {
+			mod = append(mod, bson.E{Key: "expireAfterSeconds", Value: "off"})
+		}
+	}
+	if len(mod) == 1 {
+		return nil
+	}
+	return db.RunCommand(ctx, mod).Err()
+}
+
+// TimeUnit is a unit of time of date expressions such as $dateTrunc.
+type TimeUnit string
+
+const (
+	UnitMillisecond TimeUnit = "millisecond"
+	UnitSecond      TimeUnit = "second"
+	UnitMinute      TimeUnit = "minute"
+	UnitHour        TimeUnit = "hour"
+	UnitDay         TimeUnit = "day"
+	UnitWeek        TimeUnit = "week"
+	UnitMonth       TimeUnit = "month"
+	UnitQuarter     TimeUnit = "quarter"
+	UnitYear        TimeUnit = "year"
+)
+
+// Bucket is a time window of a series returned by Buckets.
+type Bucket[M, V any] struct {
+	// Start is the start of the window.
+	Start time.Time `bson:"start"`
+	// Meta is the meta field value identifying the series.
+	Meta M `bson:"meta"`
+	// Values holds the accumulated and window fields of the bucket. Values of windows
+	// without measurements added by Densify are zero unless filled.
+	Values V `bson:"values"`
+}
+
+// Fill is how a missing value of a bucket is filled.
+type Fill struct {
+	method string
+	value  any
+}
+
+var (
+	// FillLinear fills missing values by linear interpolation between the surrounding buckets.
+	FillLinear = Fill{method: "linear"}
+	// FillLocf fills missing values with the last value observed in an earlier bucket.
+	FillLocf = Fill{method: "locf"}
+)
+
+// FillValue fills missing values with v.
+func FillValue(v any) Fill {
+	return Fill{value: v}
+}
+
+// BucketQuery selects the measurements and time windows of Buckets.
+type BucketQuery struct {
+	// Filter selects the measurements, like the filter of Find. Optional.
+	Filter any
+	// Unit and BinSize set the width of the windows, e.g. 15 and UnitMinute.
+	// BinSize defaults to 1.
+	Unit    TimeUnit
+	BinSize int
+	// Accumulators are the values computed for each window, keyed by their name in
+	// Bucket.Values, e.g. {{"avg", bson.M{"$avg": "$value"}}}.
+	Accumulators bson.D
+	// Densify adds the windows without measurements between the first and last
+	// window of each series, or between From and To if set.
+	Densify bool
+	// From and To bound the windows added by Densify, from the window containing
+	// From up to the window containing To, exclusive. Optional, but must be set
+	// together.
+	From, To time.Time
+	// Fill fills the missing values of windows added by Densify, keyed by name.
+	Fill map[string]Fill
+	// Window computes values over neighboring windows of each series with
+	// $setWindowFields, keyed by their name in Bucket.Values. Expressions refer to
+	// bucket values as "$values.<name>", e.g. a moving average:
+	//
+	//	{{"avg3", bson.M{"$avg": "$values.avg", "window": bson.M{"documents": bson.A{-2, 0}}}}}
+	Window bson.D
+}
+
+// Buckets groups the measurements of a time-series collection into time windows of
+// each series, and returns the windows in order of series and start.
+//
+// The type parameter M is the type of the meta field of ts, and V the type of the
+// values of the windows, usually a struct with a field for each accumulator and
+// window output. The pipeline groups with $dateTrunc, then adds missing windows with
+// $densify, fills them with $fill and computes window fields with $setWindowFields:
+//
+//	type Stats struct {
+//	    Avg float64 `bson:"avg"`
+//	    Max float64 `bson:"max"`
+//	}
+//
+//	buckets, err := monarch.Buckets[string, Stats](ctx, Measurements, MeasurementSeries, monarch.BucketQuery{
+//	    Filter:       bson.M{"ts": bson.M{"$gte": since}},
+//	    Unit:         monarch.UnitHour,
+//	    Accumulators: bson.D{{"avg", bson.M{"$avg": "$value"}}, {"max", bson.M{"$max": "$value"}}},
+//	    Densify:      true,
+//	    Fill:         map[string]monarch.Fill{"avg": monarch.FillLinear, "max": monarch.FillValue(0)},
+//	})
+func Buckets[M, V, T any](ctx context.Context, c Collection[T], ts TimeSeries, query BucketQuery, opts ...options.Lister[options.AggregateOptions]) ([]Bucket[M, V], error) {
+	pipeline, err := bucketPipeline(reflect.TypeFor[T](), ts, query)
+	if err != nil {
+		return nil, err
+	}
+	return AggregateAs[Bucket[M, V]](ctx, c, pipeline, opts...)
+}
+
+// truncReference is the reference date of $dateTrunc, from which windows of binSize
+// units are counted.
+var truncReference = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// truncateTime returns the start of the window of binSize units containing t in UTC,
+// like $dateTrunc. Weeks start on Sunday.
+func truncateTime(t time.Time, unit TimeUnit, binSize int) time.Time {
+	t = t.UTC()
+	var months int
+	switch unit {
+	case UnitMonth:
+		months = 1
+	case UnitQuarter:
+		months = 3
+	case UnitYear:
+		months = 12
+	}
+	if months > 0 {
+		elapsed := (t.Year()-truncReference.Year())*12 + int(t.Month()-truncReference.Month())
+		bin := months * binSize
+		elapsed -= ((elapsed % bin) + bin) % bin
+		return truncReference.AddDate(0, elapsed, 0)
+	}
+
+	reference, width := truncReference, time.Duration(binSize)
+	switch unit {
+	case UnitMillisecond:
+		width *= time.Millisecond
+	case UnitSecond:
+		width *= time.Second
+	case UnitMinute:
+		width *= time.Minute
+	case UnitHour:
+		width *= time.Hour
+	case UnitDay:
+		width *= 24 * time.Hour
+	case UnitWeek:
+		// The first Sunday on or after the reference date.
+		reference = reference.AddDate(0, 0, (7-int(reference.Weekday()))%7)
+		width *= 7 * 24 * time.Hour
+	default:
+		return t
+	}
+	offset := t.Sub(reference) % width
+	if offset < 0 {
+		offset += width
+	}
+	return t.Add(-offset)
+}
+
+// bucketPipeline returns the aggregation pipeline of Buckets.
+func bucketPipeline(t reflect.Type, ts TimeSeries, query BucketQuery) (bson.A, error) {
+	if err := ts.validate(t); err != nil {
+		return nil, err
+	}
+	if query.Unit == "" {
+		return nil, errors.New("monarch: bucket query requires a unit")
+	}
+	binSize := max(query.BinSize, 1)
+	var meta any
+	if ts.MetaField != "" {
+		meta = "$" + ts.MetaField
+	}
+
+	filter := query.Filter
+	if filter == nil {
+		filter = bson.D{}
+	}
+	group := bson.D{{Key: "_id", Value: bson.D{
+		{Key: "meta", Value: meta},
+		{Key: "start", Value: bson.D{{Key: "$dateTrunc", Value: bson.D{
+			{Key: "date", Value: "$" + ts.TimeField},
+			{Key: "unit", Value: string(query.Unit)},
+			{Key: "binSize", Value: binSize},
+		}}}},
+	}}}
+	values := bson.D{}
+	for _, acc := range query.Accumulators {
+		group = append(group, acc)
+		values = append(values, bson.E{Key: acc.Key, Value: "$" + acc.Key})
+	}
+	pipeline := bson.A{
+		bson.D{{Key: "$match", Value: filter}},
+		bson.D{{Key: "$group", Value: group}},
+		bson.D{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 0},
+			{Key: "start", Value: "$_id.start"},
+			{Key: "meta", Value: "$_id.meta"},
+			{Key: "values", Value: values},
+		}}},
+	}
+
+	if query.Densify {
+		if query.From.IsZero() != query.To.IsZero() {
+			return nil, errors.New("monarch: bucket query densify bounds require both From and To")
+		}
+		var bounds any = "partition"
+		if !query.From.IsZero() {
+			// Densified windows start at the lower bound, so it must be the start of
+			// a window for them to align with the windows of $dateTrunc.
+			bounds = bson.A{truncateTime(query.From, query.Unit, binSize), truncateTime(query.To, query.Unit, binSize)}
+		}
+		pipeline = append(pipeline, bson.D{{Key: "$densify", Value: bson.D{
+			{Key: "field", Value: "start"},
+			{Key: "partitionByFields", Value: bson.A{"meta"}},
+			{Key: "range", Value: bson.D{
+				{Key: "step", Value: binSize},
+				{Key: "unit", Value: string(query.Unit)},
+				{Key: "bounds", Value: bounds},
+			}},
+		}}})
+	}
+	if len(query.Fill) > 0 {
+		output := bson.D{}
+		for _, acc := range query.Accumulators {
+			fill, ok := query.Fill[acc.Key]
+			if !ok {
+				continue
+			}
+			if fill.method != "" {
+				output = append(output, bson.E{Key: "values." + acc.Key, Value: bson.D{{Key: "method", Value: fill.method}}})
+			} else {
+				output = append(output, bson.E{Key: "values." + acc.Key, Value: bson.D{{Key: "value", Value: fill.value}}})
+			}
+		}
+		if len(output) != len(query.Fill) {
+			return nil, errors.New("monarch: bucket query fills a value that is not an accumulator")
+		}
+		pipeline = append(pipeline, bson.D{{Key: "$fill", Value: bson.D{
+			{Key: "partitionByFields", Value: bson.A{"meta"}},
+			{Key: "sortBy", Value: bson.D{{Key: "start", Value: 1}}},
+			{Key: "output", Value: output},
+		}}})
+	}
+	if len(query.Window) > 0 {
+		output := bson.D{}
+		for _, w := range query.Window {
+			output = append(output, bson.E{Key: "values." + w.Key, Value: w.Value})
+		}
+		pipeline = append(pipeline, bson.D{{Key: "$setWindowFields", Value: bson.D{
+			{Key: "partitionBy", Value: "$meta"},
+			{Key: "sortBy", Value: bson.D{{Key: "start", Value: 1}}},
+			{Key: "output", Value: output},
+		}}})
+	}
+	return append(pipeline, bson.D{{Key: "$sort", Value: bson.D{{Key: "meta", Value: 1}, {Key: "start", Value: 1}}}}), nil
+}
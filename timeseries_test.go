@@ -0,0 +1,213 @@
+package monarch
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type Measurement struct {
+	ID     bson.ObjectID `bson:"_id,omitempty"`
+	Time   time.Time     `bson:"ts"`
+	Sensor string        `bson:"sensor"`
+	Value  float64       `bson:"value"`
+}
+
+var Measurements Collection[Measurement] = "measurements"
+
+var measurementSeries = TimeSeries{TimeField: "ts", MetaField: "sensor", Granularity: GranularityMinutes}
+
+type MeasurementStats struct {
+	Avg  float64 `bson:"avg"`
+	Max  float64 `bson:"max"`
+	Avg2 float64 `bson:"avg2"`
+}
+
+func TestTimeSeriesValidate(t *testing.T) {
+	typ := reflect.TypeFor[Measurement]()
+	if err := measurementSeries.validate(typ); err != nil {
+		t.Errorf("expected valid declaration, got %v", err)
+	}
+
+	tests := []struct {
+		name string
+		ts   TimeSeries
+		want string
+	}{
+		{"missing time field", TimeSeries{}, "requires a time field"},
+		{"unknown time field", TimeSeries{TimeField: "time"}, `time field "time" not found`},
+		{"time field not a time", TimeSeries{TimeField: "value"}, "want time.Time or bson.DateTime"},
+		{"unknown meta field", TimeSeries{TimeField: "ts", MetaField: "device"}, `meta field "device" not found`},
+		{"meta field is _id", TimeSeries{TimeField: "ts", MetaField: "_id"}, "cannot be the time field or _id"},
+		{"granularity and span", TimeSeries{TimeField: "ts", Granularity: GranularityHours, BucketMaxSpan: time.Hour}, "cannot be combined"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.ts.validate(typ)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestBucketPipeline(t *testing.T) {
+	pipeline, err := bucketPipeline(reflect.TypeFor[Measurement](), measurementSeries, BucketQuery{
+		Unit:         UnitMinute,
+		BinSize:      5,
+		Accumulators: bson.D{{Key: "avg", Value: bson.M{"$avg": "$value"}}},
+		Densify:      true,
+		Fill:         map[string]Fill{"avg": FillLinear},
+		Window:       bson.D{{Key: "avg2", Value: bson.M{"$avg": "$values.avg"}}},
+	})
+	if err != nil {
+		t.Fatalf("bucketPipeline failed: %v", err)
+	}
+	raw := mustMarshal(t, bson.D{{Key: "pipeline", Value: pipeline}})
+
+	var stages []string
+	for i := range len(pipeline) {
+		stages = append(stages, raw.Lookup("pipeline", strconv.Itoa(i)).Document().Index(0).Key())
+	}
+	want := []string{"$match", "$group", "$project", "$densify", "$fill", "$setWindowFields", "$sort"}
+	if !reflect.DeepEqual(stages, want) {
+		t.Errorf("expected stages %v, got %v", want, stages)
+	}
+	if got := raw.Lookup("pipeline", "1", "$group", "_id", "start", "$dateTrunc", "date").StringValue(); got != "$ts" {
+		t.Errorf("expected windows of the time field, got %q", got)
+	}
+	if got := raw.Lookup("pipeline", "4", "$fill", "output", "values.avg", "method").StringValue(); got != "linear" {
+		t.Errorf("expected linear fill, got %q", got)
+	}
+
+	if _, err := bucketPipeline(reflect.TypeFor[Measurement](), measurementSeries, BucketQuery{Unit: UnitMinute, Fill: map[string]Fill{"avg": FillLocf}}); err == nil {
+		t.Error("expected fill of an unknown accumulator to fail")
+	}
+	if _, err := bucketPipeline(reflect.TypeFor[Measurement](), measurementSeries, BucketQuery{}); err == nil {
+		t.Error("expected missing unit to fail")
+	}
+	if _, err := bucketPipeline(reflect.TypeFor[Measurement](), measurementSeries, BucketQuery{Unit: UnitMinute, Densify: true, From: time.Now()}); err == nil {
+		t.Error("expected densify with only From to fail")
+	}
+}
+
+func TestBucketPipelineBounds(t *testing.T) {
+	from := time.Date(2025, time.March, 4, 10, 7, 31, 0, time.UTC)
+	to := time.Date(2025, time.March, 4, 11, 2, 0, 0, time.UTC)
+	pipeline, err := bucketPipeline(reflect.TypeFor[Measurement](), measurementSeries, BucketQuery{
+		Unit:         UnitMinute,
+		BinSize:      15,
+		Accumulators: bson.D{{Key: "avg", Value: bson.M{"$avg": "$value"}}},
+		Densify:      true,
+		From:         from,
+		To:           to,
+	})
+	if err != nil {
+		t.Fatalf("bucketPipeline failed: %v", err)
+	}
+	raw := mustMarshal(t, bson.D{{Key: "pipeline", Value: pipeline}})
+	bounds := raw.Lookup("pipeline", "3", "$densify", "range", "bounds")
+	if got := bounds.Array().Index(0).Time(); !got.Equal(time.Date(2025, time.March, 4, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected lower bound truncated to 10:00, got %v", got)
+	}
+	if got := bounds.Array().Index(1).Time(); !got.Equal(time.Date(2025, time.March, 4, 11, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected upper bound truncated to 11:00, got %v", got)
+	}
+}
+
+func TestTruncateTime(t *testing.T) {
+	at := time.Date(2025, time.May, 14, 13, 47, 31, 500, time.UTC)
+	tests := []struct {
+		unit    TimeUnit
+		binSize int
+		want    time.Time
+	}{
+		{UnitSecond, 10, time.Date(2025, time.May, 14, 13, 47, 30, 0, time.UTC)},
+		{UnitMinute, 15, time.Date(2025, time.May, 14, 13, 45, 0, 0, time.UTC)},
+		{UnitHour, 6, time.Date(2025, time.May, 14, 12, 0, 0, 0, time.UTC)},
+		{UnitDay, 1, time.Date(2025, time.May, 14, 0, 0, 0, 0, time.UTC)},
+		{UnitWeek, 1, time.Date(2025, time.May, 11, 0, 0, 0, 0, time.UTC)},
+		{UnitMonth, 1, time.Date(2025, time.May, 1, 0, 0, 0, 0, time.UTC)},
+		{UnitMonth, 4, time.Date(2025, time.May, 1, 0, 0, 0, 0, time.UTC)},
+		{UnitQuarter, 1, time.Date(2025, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		{UnitYear, 10, time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := truncateTime(at, tt.unit, tt.binSize); !got.Equal(tt.want) {
+			t.Errorf("%d %s: expected %v, got %v", tt.binSize, tt.unit, tt.want, got)
+		}
+	}
+	before := time.Date(1999, time.December, 31, 23, 0, 0, 0, time.UTC)
+	if got := truncateTime(before, UnitMonth, 5); !got.Equal(time.Date(1999, time.August, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected windows before the reference date to align, got %v", got)
+	}
+}
+
+func TestTimeSeries(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := WithContext(context.Background(), db)
+
+	db.Collection(string(Measurements)).Drop(ctx)
+
+	if err := Measurements.EnsureTimeSeries(ctx, measurementSeries); err != nil {
+		t.Fatalf("EnsureTimeSeries failed: %v", err)
+	}
+	// Ensuring again updates the existing collection.
+	ts := measurementSeries
+	ts.Granularity = GranularityHours
+	ts.ExpireAfter = 24 * time.Hour
+	if err := Measurements.EnsureTimeSeries(ctx, ts); err != nil {
+		t.Fatalf("EnsureTimeSeries of existing collection failed: %v", err)
+	}
+	if err := Measurements.EnsureTimeSeries(ctx, TimeSeries{TimeField: "ts"}); err == nil {
+		t.Error("expected EnsureTimeSeries with another meta field to fail")
+	}
+	if err := Users.EnsureTimeSeries(ctx, TimeSeries{TimeField: "ts"}); err == nil {
+		t.Error("expected EnsureTimeSeries to validate the document type")
+	}
+
+	start := time.Now().UTC().Truncate(time.Hour).Add(-3 * time.Hour)
+	_, err := Measurements.InsertMany(ctx, []Measurement{
+		{Time: start, Sensor: "a", Value: 1},
+		{Time: start.Add(10 * time.Minute), Sensor: "a", Value: 3},
+		{Time: start.Add(2 * time.Hour), Sensor: "a", Value: 6},
+		{Time: start, Sensor: "b", Value: 10},
+	})
+	if err != nil {
+		t.Fatalf("InsertMany failed: %v", err)
+	}
+
+	buckets, err := Buckets[string, MeasurementStats](ctx, Measurements, ts, BucketQuery{
+		Filter:       bson.M{"ts": bson.M{"$gte": start}},
+		Unit:         UnitHour,
+		Accumulators: bson.D{{Key: "avg", Value: bson.M{"$avg": "$value"}}, {Key: "max", Value: bson.M{"$max": "$value"}}},
+		Densify:      true,
+		Fill:         map[string]Fill{"avg": FillLinear, "max": FillValue(0)},
+		Window:       bson.D{{Key: "avg2", Value: bson.M{"$avg": "$values.avg", "window": bson.M{"documents": bson.A{-1, 0}}}}},
+	})
+	if err != nil {
+		t.Fatalf("Buckets failed: %v", err)
+	}
+	if len(buckets) != 4 {
+		t.Fatalf("expected 3 buckets of a and 1 of b, got %+v", buckets)
+	}
+	first, filled, last := buckets[0], buckets[1], buckets[2]
+	if first.Meta != "a" || !first.Start.Equal(start) || first.Values.Avg != 2 || first.Values.Max != 3 {
+		t.Errorf("unexpected first bucket %+v", first)
+	}
+	if !filled.Start.Equal(start.Add(time.Hour)) || filled.Values.Avg != 4 || filled.Values.Max != 0 {
+		t.Errorf("expected densified bucket filled by interpolation, got %+v", filled)
+	}
+	if last.Values.Avg != 6 || last.Values.Avg2 != 5 {
+		t.Errorf("expected moving average over the last two buckets, got %+v", last)
+	}
+	if buckets[3].Meta != "b" || buckets[3].Values.Avg != 10 {
+		t.Errorf("unexpected bucket of b %+v", buckets[3])
+	}
+}
@@ -0,0 +1,128 @@
+package monarch
+
+import (
+	"bytes"
+	"context"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// track runs write and, if the collection is audited or versioned, records its effect
+// on the first document matching filter in the order of sort, which is the document
+// written by single document writes given the same sort. A nil filter denotes an insert.
+// After a successful write, insertedID, if not nil, returns the _id of a document
+// inserted by the write, if any.
+func (c Collection[T]) track(ctx context.Context, op string, filter, sort any, write func(ctx context.Context) error, insertedID func() any) error {
+	auditConfig, audited := auditFor(ctx, string(c))
+	historyConfig, versioned := historyFor(ctx, string(c))
+	if !audited && !versioned {
+		return write(ctx)
+	}
+	// Indexes are not created inside a transaction, so they are created before one
+	// is started for the write.
+	if versioned {
+		if err := c.history().EnsureIndexes(ctx, historyIndexes); err != nil {
+			return err
+		}
+	}
+	if audited {
+		if err := Collection[AuditRecord](auditConfig.collection()).EnsureIndexes(ctx, auditIndexes); err != nil {
+			return err
+		}
+	}
+
+	run := func(ctx context.Context) error {
+		var before bson.Raw
+		if filter != nil {
+			var err error
+			if before, err = c.findOneRaw(ctx, filter, sort); err != nil && err != mongo.ErrNoDocuments {
+				return err
+			}
+		}
+
+		if err := write(ctx); err != nil {
+			return err
+		}
+
+		var id bson.RawValue
+		if before != nil {
+			id = before.Lookup("_id")
+		} else if insertedID != nil {
+			if inserted := insertedID(); inserted != nil {
+				typ, data, err := bson.MarshalValue(inserted)
+				if err != nil {
+					return err
+				}
+				id = bson.RawValue{Type: typ, Value: data}
+			}
+		}
+		if id.IsZero() {
+			// Nothing matched and nothing was inserted.
+			return nil
+		}
+
+		after, err := c.findOneRaw(ctx, bson.D{{Key: "_id", Value: id}}, nil)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return err
+		}
+		tenant := c.documentTenant(before)
+		if tenant.IsZero() {
+			tenant = c.documentTenant(after)
+		}
+		if versioned && (before != nil || after != nil) && !bytes.Equal(before, after) {
+			if err := c.snapshot(ctx, op, id, tenant, before); err != nil {
+				return err
+			}
+		}
+		if audited {
+			return c.record(ctx, auditConfig, op, filter, id, tenant, before, after)
+		}
+		return nil
+	}
+
+	if audited && auditConfig.Transactional || versioned && historyConfig.Transactional {
+		return TxDo(ctx, run)
+	}
+	return run(ctx)
+}
+
+// findOneRaw returns the first raw document matching filter in the order of sort,
+// if not nil, bypassing the cache.
+func (c Collection[T]) findOneRaw(ctx context.Context, filter, sort any) (bson.Raw, error) {
+	opts := options.FindOne()
+	if sort != nil {
+		opts.SetSort(sort)
+	}
+	var raw bson.Raw
+	err := c.exec(ctx, "FindOne", opRead, func(collection *mongo.Collection) (err error) {
+		raw, err = collection.FindOne(ctx, filter, opts).Raw()
+		return err
+	})
+	return raw, err
+}
+
+// listedOption returns the option set by opts, read from the options with get.
+func listedOption[O any](opts []options.Lister[O], get func(*O) any) any {
+	var o O
+	for _, opt := range opts {
+		for _, set := range opt.List() {
+			set(&o)
+		}
+	}
+	return get(&o)
+}
+
+// documentID returns the _id of doc, or nil if it has none.
+func documentID(doc any) any {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return nil
+	}
+	id, err := bson.Raw(raw).LookupErr("_id")
+	if err != nil {
+		return nil
+	}
+	return id
+}
@@ -0,0 +1,49 @@
+package monarch
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Tx executes fn in a transaction with a typed return value.
+//
+// A session is started on the client of the database in the context and ended when
+// the transaction completes. The transaction is run with the driver's retry loop, so
+// fn may be called more than once and must be safe to retry. Use the context passed
+// to fn for all operations that should be part of the transaction.
+//
+// If the context already carries a running transaction, such as when Tx is called
+// from within another transaction, fn is executed directly as part of the outer
+// transaction and opts are ignored.
+//
+// See [mongo.Session.WithTransaction] for more details.
+func Tx[T any](ctx context.Context, fn func(ctx context.Context) (T, error), opts ...options.Lister[options.TransactionOptions]) (T, error) {
+	if inTransaction(ctx) {
+		return fn(ctx)
+	}
+
+	db, err := getDB(ctx)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	session, err := db.Client().StartSession()
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	defer session.EndSession(context.WithoutCancel(ctx))
+
+	return WithTransaction(ctx, session, fn, opts...)
+}
+
+// TxDo executes fn in a transaction without a return value.
+//
+// See Tx for more details.
+func TxDo(ctx context.Context, fn func(ctx context.Context) error, opts ...options.Lister[options.TransactionOptions]) error {
+	_, err := Tx(ctx, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	}, opts...)
+	return err
+}
@@ -0,0 +1,119 @@
+package monarch
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+func TestTxNoDatabase(t *testing.T) {
+	_, err := Tx(context.Background(), func(ctx context.Context) (int, error) {
+		return 1, nil
+	})
+	if !errors.Is(err, ErrNoDatabase) {
+		t.Errorf("expected ErrNoDatabase, got %v", err)
+	}
+}
+
+func TestTxReusesOuterTransaction(t *testing.T) {
+	client, err := mongo.Connect(options.Client())
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	defer client.Disconnect(context.Background())
+
+	session, err := client.StartSession()
+	if err != nil {
+		t.Fatalf("StartSession failed: %v", err)
+	}
+	defer session.EndSession(context.Background())
+	if err := session.StartTransaction(); err != nil {
+		t.Fatalf("StartTransaction failed: %v", err)
+	}
+	outer := mongo.NewSessionContext(context.Background(), session)
+
+	// No database is in the context, so this only succeeds if the outer transaction is reused.
+	val, err := Tx(outer, func(ctx context.Context) (string, error) {
+		if mongo.SessionFromContext(ctx) != session {
+			t.Errorf("expected nested transaction to reuse the outer session")
+		}
+		return "nested", nil
+	})
+	if err != nil {
+		t.Fatalf("nested Tx failed: %v", err)
+	}
+	if val != "nested" {
+		t.Errorf("expected 'nested', got '%s'", val)
+	}
+
+	cause := errors.New("rollback")
+	if err := TxDo(outer, func(ctx context.Context) error { return cause }); !errors.Is(err, cause) {
+		t.Errorf("expected nested TxDo error %v, got %v", cause, err)
+	}
+}
+
+func TestTx(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := WithContext(context.Background(), db)
+
+	cleanupCollection(t, ctx, Users)
+
+	res, err := Tx(ctx, func(ctx context.Context) (string, error) {
+		if _, err := Users.InsertOne(ctx, User{ID: "tx1", Name: "Outer"}); err != nil {
+			return "", err
+		}
+		err := TxDo(ctx, func(ctx context.Context) error {
+			_, err := Users.InsertOne(ctx, User{ID: "tx2", Name: "Nested"})
+			return err
+		})
+		return "done", err
+	})
+
+	if err != nil {
+		t.Logf("transaction failed (expected on standalone): %v", err)
+		return
+	}
+	if res != "done" {
+		t.Errorf("expected 'done', got '%s'", res)
+	}
+	count, err := Users.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		t.Fatalf("CountDocuments failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 committed documents, got %d", count)
+	}
+}
+
+func TestTxAbortsOnError(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := WithContext(context.Background(), db)
+
+	cleanupCollection(t, ctx, Users)
+
+	cause := errors.New("rollback")
+	err := TxDo(ctx, func(ctx context.Context) error {
+		if _, err := Users.InsertOne(ctx, User{ID: "txa1", Name: "Aborted"}); err != nil {
+			return err
+		}
+		return cause
+	})
+	if !errors.Is(err, cause) {
+		t.Logf("transaction failed (expected on standalone): %v", err)
+		return
+	}
+
+	exists, err := Users.Exists(ctx, bson.M{"_id": "txa1"})
+	if err != nil {
+		t.Fatalf("Exists failed: %v", err)
+	}
+	if exists {
+		t.Errorf("expected aborted insert to be rolled back")
+	}
+}
@@ -0,0 +1,139 @@
+package monarch
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// findAndModifyResult is the reply of a findAndModify command.
+type findAndModifyResult struct {
+	LastErrorObject struct {
+		UpdatedExisting bool `bson:"updatedExisting"`
+	} `bson:"lastErrorObject"`
+	Value bson.Raw `bson:"value"`
+}
+
+// Upsert replaces the document matching the filter, or inserts it if no document matches.
+//
+// This executes a findAndModify command with upsert enabled and returns the stored
+// document after the write. The created result reports whether a new document was
+// inserted rather than an existing document replaced.
+//
+// The replacement document must not contain update operators. Use UpsertWith
+// if you want to use update operators.
+//
+// See [mongo.Collection.FindOneAndReplace] for more details.
+func (c Collection[T]) Upsert(ctx context.Context, filter any, replacement T) (doc T, created bool, err error) {
+	encrypted, err := c.prepareDocument(ctx, replacement)
+	if err != nil {
+		return doc, false, err
+	}
+	return c.upsert(ctx, "Upsert", filter, encrypted)
+}
+
+// UpsertWith updates the document matching the filter, or inserts a new document if no document matches.
+//
+// This executes a findAndModify command with upsert enabled and returns the stored
+// document after the write. The created result reports whether a new document was
+// inserted rather than an existing document updated. When a document is inserted,
+// equality conditions in the filter are applied to the new document before the update.
+//
+// The update parameter must contain update operators (e.g., $set, $setOnInsert).
+//
+// See [mongo.Collection.FindOneAndUpdate] for more details.
+func (c Collection[T]) UpsertWith(ctx context.Context, filter any, update any) (doc T, created bool, err error) {
+	if update, err = c.prepareUpdate(ctx, update); err != nil {
+		return doc, false, err
+	}
+	return c.upsert(ctx, "UpsertWith", filter, update)
+}
+
+// upsert executes a findAndModify command with upsert and new enabled.
+// The driver does not expose the lastErrorObject of findAndModify, so the
+// command is run directly to report whether the document was inserted.
+func (c Collection[T]) upsert(ctx context.Context, op string, filter any, update any) (T, bool, error) {
+	var doc T
+	filter, err := c.guardFilter(ctx, op, filter, true)
+	if err != nil {
+		return doc, false, err
+	}
+	var result findAndModifyResult
+	cmd := bson.D{
+		{Key: "findAndModify", Value: string(c)},
+		{Key: "query", Value: filter},
+		{Key: "update", Value: update},
+		{Key: "upsert", Value: true},
+		{Key: "new", Value: true},
+	}
+	err = c.track(ctx, op, filter, nil, func(ctx context.Context) error {
+		return c.exec(ctx, op, opWrite, func(collection *mongo.Collection) error {
+			return collection.Database().RunCommand(ctx, cmd).Decode(&result)
+		})
+	}, func() any { return documentID(result.Value) })
+	if err != nil {
+		return doc, false, err
+	}
+	if err := decodeRaw(ctx, result.Value, &doc); err != nil {
+		return doc, false, err
+	}
+	return doc, !result.LastErrorObject.UpdatedExisting, nil
+}
+
+// UpsertMany replaces or inserts each document, matching existing documents on the given key fields.
+//
+// A filter is built for each document from the values of its key fields, so keys must
+// uniquely identify documents for the operation to be idempotent. Keys may be dotted
+// paths into embedded documents. An error is returned without writing anything if a
+// document is missing any key field.
+//
+// All writes are sent in a single bulk write. Use options.BulkWrite().SetOrdered(false)
+// to continue writing the remaining documents after a failure.
+//
+// See [mongo.Collection.BulkWrite] for more details.
+func (c Collection[T]) UpsertMany(ctx context.Context, values []T, keys []string, opts ...options.Lister[options.BulkWriteOptions]) (*mongo.BulkWriteResult, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("monarch: upsert many requires at least one key field")
+	}
+	// Documents are matched within the tenant, so keys only need to be unique per tenant.
+	field, _, ok, err := c.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if ok && !slices.Contains(keys, field.name) {
+		keys = append(slices.Clip(keys), field.name)
+	}
+
+	models := make([]mongo.WriteModel, len(values))
+	for i, v := range values {
+		doc, err := c.prepareDocument(ctx, v)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := bson.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		filter := make(bson.D, len(keys))
+		for j, key := range keys {
+			value, err := bson.Raw(raw).LookupErr(strings.Split(key, ".")...)
+			if err != nil {
+				return nil, fmt.Errorf("monarch: upsert key %q missing from document at index %d", key, i)
+			}
+			filter[j] = bson.E{Key: key, Value: value}
+		}
+		models[i] = mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(bson.Raw(raw)).SetUpsert(true)
+	}
+
+	var result *mongo.BulkWriteResult
+	err = c.exec(ctx, "UpsertMany", opWrite, func(collection *mongo.Collection) (err error) {
+		result, err = collection.BulkWrite(ctx, models, opts...)
+		return err
+	})
+	return result, err
+}
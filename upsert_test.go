@@ -0,0 +1,125 @@
+package monarch
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestUpsert(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := WithContext(context.Background(), db)
+
+	cleanupCollection(t, ctx, Users)
+
+	doc, created, err := Users.Upsert(ctx, bson.M{"_id": "up1"}, User{ID: "up1", Name: "Inserted", Age: 20})
+	if err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+	if !created {
+		t.Errorf("expected document to be created")
+	}
+	if doc.Name != "Inserted" {
+		t.Errorf("expected Inserted, got %s", doc.Name)
+	}
+
+	doc, created, err = Users.Upsert(ctx, bson.M{"_id": "up1"}, User{ID: "up1", Name: "Replaced", Age: 30})
+	if err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+	if created {
+		t.Errorf("expected existing document to be replaced")
+	}
+	if doc.Name != "Replaced" || doc.Age != 30 {
+		t.Errorf("expected Replaced/30, got %s/%d", doc.Name, doc.Age)
+	}
+}
+
+func TestUpsertWith(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := WithContext(context.Background(), db)
+
+	cleanupCollection(t, ctx, Users)
+
+	update := bson.M{
+		"$inc":         bson.M{"age": 1},
+		"$setOnInsert": bson.M{"name": "Counter"},
+	}
+
+	doc, created, err := Users.UpsertWith(ctx, bson.M{"_id": "upw1"}, update)
+	if err != nil {
+		t.Fatalf("UpsertWith failed: %v", err)
+	}
+	if !created {
+		t.Errorf("expected document to be created")
+	}
+	if doc.ID != "upw1" || doc.Name != "Counter" || doc.Age != 1 {
+		t.Errorf("expected upw1/Counter/1, got %s/%s/%d", doc.ID, doc.Name, doc.Age)
+	}
+
+	doc, created, err = Users.UpsertWith(ctx, bson.M{"_id": "upw1"}, update)
+	if err != nil {
+		t.Fatalf("UpsertWith failed: %v", err)
+	}
+	if created {
+		t.Errorf("expected existing document to be updated")
+	}
+	if doc.Age != 2 {
+		t.Errorf("expected age 2, got %d", doc.Age)
+	}
+}
+
+func TestUpsertMany(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := WithContext(context.Background(), db)
+
+	cleanupCollection(t, ctx, Users)
+
+	_, err := Users.InsertOne(ctx, User{ID: "um1", Name: "Alice", Age: 20})
+	if err != nil {
+		t.Fatalf("InsertOne failed: %v", err)
+	}
+
+	users := []User{
+		{ID: "um1", Name: "Alice", Age: 21},
+		{ID: "um2", Name: "Bob", Age: 30},
+	}
+	res, err := Users.UpsertMany(ctx, users, []string{"_id"})
+	if err != nil {
+		t.Fatalf("UpsertMany failed: %v", err)
+	}
+	if res.MatchedCount != 1 || res.UpsertedCount != 1 {
+		t.Errorf("expected 1 matched and 1 upserted, got %d and %d", res.MatchedCount, res.UpsertedCount)
+	}
+
+	// Running the same sync again is idempotent.
+	res, err = Users.UpsertMany(ctx, users, []string{"_id"})
+	if err != nil {
+		t.Fatalf("UpsertMany failed: %v", err)
+	}
+	if res.MatchedCount != 2 || res.UpsertedCount != 0 || res.ModifiedCount != 0 {
+		t.Errorf("expected 2 matched and nothing written, got %d matched %d upserted %d modified",
+			res.MatchedCount, res.UpsertedCount, res.ModifiedCount)
+	}
+
+	count, err := Users.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		t.Fatalf("CountDocuments failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 documents, got %d", count)
+	}
+}
+
+func TestUpsertManyMissingKey(t *testing.T) {
+	ctx := WithContext(context.Background(), nil)
+
+	_, err := Users.UpsertMany(ctx, []User{{Name: "NoEmail"}}, []string{"email"})
+	if err == nil {
+		t.Fatal("expected error for missing key field, got nil")
+	}
+}
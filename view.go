@@ -0,0 +1,129 @@
+package monarch
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// View represents a read-only MongoDB view with documents of type T.
+//
+// A view is defined by an aggregation pipeline over a source collection or view,
+// and is declared like a Collection, but only exposes read operations:
+//
+//	type ActiveUser struct {
+//	    ID    string `bson:"_id"`
+//	    Name  string `bson:"name"`
+//	    Posts int    `bson:"posts"`
+//	}
+//
+//	var ActiveUsers monarch.View[ActiveUser] = "active_users"
+//
+//	err := ActiveUsers.Ensure(ctx, Users, bson.A{
+//	    bson.M{"$match": bson.M{"active": true}},
+//	    bson.M{"$project": bson.M{"name": 1, "posts": bson.M{"$size": "$posts"}}},
+//	})
+//	users, err := ActiveUsers.Find(ctx, bson.M{"posts": bson.M{"$gt": 10}})
+//
+// Read operations behave as on a Collection, including tenant scoping and decryption
+// of the fields of T. Use ViewAs for results of another type.
+type View[T any] string
+
+// collection returns the collection of the same name, used to run the read operations.
+func (v View[T]) collection() Collection[T] {
+	return Collection[T](v)
+}
+
+// Ensure creates the view over source with the given pipeline, or updates the
+// definition of the existing view with a collMod command if it differs.
+//
+// Returns an error if a collection that is not a view exists with the same name.
+// Call it once on startup.
+func (v View[T]) Ensure(ctx context.Context, source Referable, pipeline any) error {
+	if pipeline == nil {
+		pipeline = bson.A{}
+	}
+	return v.collection().exec(ctx, "Ensure", opWrite, func(collection *mongo.Collection) error {
+		db := collection.Database()
+		err := db.CreateView(ctx, string(v), source.collectionName(), pipeline)
+		if !isNamespaceExists(err) {
+			return err
+		}
+
+		specs, err := db.ListCollectionSpecifications(ctx, bson.D{{Key: "name", Value: string(v)}})
+		if err != nil {
+			return err
+		}
+		if len(specs) == 0 || specs[0].Type != "view" {
+			return fmt.Errorf("monarch: collection %q exists and is not a view", string(v))
+		}
+		existing := specs[0].Options
+		want, err := bson.Marshal(bson.D{{Key: "pipeline", Value: pipeline}})
+		if err != nil {
+			return err
+		}
+		viewOn, _ := existing.Lookup("viewOn").StringValueOK()
+		if viewOn == source.collectionName() && existing.Lookup("pipeline").Equal(bson.Raw(want).Lookup("pipeline")) {
+			return nil
+		}
+		return db.RunCommand(ctx, bson.D{
+			{Key: "collMod", Value: string(v)},
+			{Key: "viewOn", Value: source.collectionName()},
+			{Key: "pipeline", Value: pipeline},
+		}).Err()
+	})
+}
+
+// Find executes a find command on the view. See Collection.Find.
+func (v View[T]) Find(ctx context.Context, filter any, opts ...options.Lister[options.FindOptions]) ([]T, error) {
+	return v.collection().Find(ctx, filter, opts...)
+}
+
+// FindSeq executes a find command on the view and returns an iterator. See Collection.FindSeq.
+func (v View[T]) FindSeq(ctx context.Context, filter any, opts ...options.Lister[options.FindOptions]) iter.Seq2[T, error] {
+	return v.collection().FindSeq(ctx, filter, opts...)
+}
+
+// FindOne executes a find command on the view and returns a single document. See Collection.FindOne.
+func (v View[T]) FindOne(ctx context.Context, filter any, opts ...options.Lister[options.FindOneOptions]) (T, error) {
+	return v.collection().FindOne(ctx, filter, opts...)
+}
+
+// CountDocuments returns the number of documents of the view matching the filter.
+// See Collection.CountDocuments.
+func (v View[T]) CountDocuments(ctx context.Context, filter any, opts ...options.Lister[options.CountOptions]) (int64, error) {
+	return v.collection().CountDocuments(ctx, filter, opts...)
+}
+
+// Exists reports whether a document of the view matches the filter. See Collection.Exists.
+func (v View[T]) Exists(ctx context.Context, filter any) (bool, error) {
+	return v.collection().Exists(ctx, filter)
+}
+
+// Aggregate executes an aggregation pipeline on the view. See Collection.Aggregate.
+func (v View[T]) Aggregate(ctx context.Context, pipeline any, opts ...options.Lister[options.AggregateOptions]) ([]T, error) {
+	return v.collection().Aggregate(ctx, pipeline, opts...)
+}
+
+// ViewAs returns the projection of v returning results of type R, whose methods
+// are the *As functions run on the view.
+func ViewAs[R, T any](v View[T]) Projection[R, T] {
+	return As[R](v.collection())
+}
+
+func (v View[T]) collectionName() string {
+	return string(v)
+}
+
+func (v View[T]) documentType() reflect.Type {
+	return reflect.TypeFor[T]()
+}
+
+func (v View[T]) findRaw(ctx context.Context, filter any) ([]bson.Raw, error) {
+	return v.collection().findRaw(ctx, filter)
+}
@@ -0,0 +1,84 @@
+package monarch
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type UserName struct {
+	ID   string `bson:"_id"`
+	Name string `bson:"name"`
+}
+
+var Adults View[User] = "adults"
+
+func TestViewWithoutDatabase(t *testing.T) {
+	if _, err := Adults.Find(context.Background(), bson.M{}); !errors.Is(err, ErrNoDatabase) {
+		t.Errorf("expected ErrNoDatabase, got %v", err)
+	}
+	if err := Adults.Ensure(context.Background(), Users, bson.A{}); !errors.Is(err, ErrNoDatabase) {
+		t.Errorf("expected ErrNoDatabase, got %v", err)
+	}
+}
+
+func TestView(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := WithContext(context.Background(), db)
+
+	cleanupCollection(t, ctx, Users)
+	db.Collection(string(Adults)).Drop(ctx)
+
+	_, err := Users.InsertMany(ctx, []User{
+		{ID: "1", Name: "Alice", Age: 30},
+		{ID: "2", Name: "Bob", Age: 17},
+		{ID: "3", Name: "Carol", Age: 45},
+	})
+	if err != nil {
+		t.Fatalf("InsertMany failed: %v", err)
+	}
+
+	if err := Adults.Ensure(ctx, Users, bson.A{bson.M{"$match": bson.M{"age": bson.M{"$gte": 21}}}}); err != nil {
+		t.Fatalf("Ensure failed: %v", err)
+	}
+	users, err := Adults.Find(ctx, bson.M{})
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if len(users) != 2 {
+		t.Errorf("expected 2 adults, got %+v", users)
+	}
+
+	// Ensuring again with another pipeline updates the view.
+	if err := Adults.Ensure(ctx, Users, bson.A{bson.M{"$match": bson.M{"age": bson.M{"$gte": 18}}}}); err != nil {
+		t.Fatalf("Ensure of existing view failed: %v", err)
+	}
+	count, err := Adults.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		t.Fatalf("CountDocuments failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 adults, got %d", count)
+	}
+	if err := Adults.Ensure(ctx, Users, bson.A{bson.M{"$match": bson.M{"age": bson.M{"$gte": 10}}}}); err != nil {
+		t.Fatalf("Ensure of existing view failed: %v", err)
+	}
+	if count, _ := Adults.CountDocuments(ctx, bson.M{}); count != 3 {
+		t.Errorf("expected updated view to include all users, got %d", count)
+	}
+
+	names, err := ViewAs[UserName](Adults).Find(ctx, bson.M{"name": "Carol"})
+	if err != nil {
+		t.Fatalf("ViewAs Find failed: %v", err)
+	}
+	if len(names) != 1 || names[0].Name != "Carol" {
+		t.Errorf("unexpected names %+v", names)
+	}
+
+	if err := View[User](Users).Ensure(ctx, Users, nil); err == nil {
+		t.Error("expected Ensure over a collection that is not a view to fail")
+	}
+}